@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/superchalupa/go-redfish/src/log"
+	"github.com/superchalupa/go-redfish/src/ocp/certauth"
+)
+
+// clientAuth works out how the https listeners check client certificates for
+// the tls.client_auth mode, and the CAs they are checked against. In
+// "optional" mode a CA bundle that can't be used is logged and client
+// certificates are turned off. Anything wrong in "required" mode is an error,
+// the server must not start up accepting what it was told to refuse.
+func clientAuth(logger log.Logger, mode, caFile, userName string) (tls.ClientAuthType, *x509.CertPool, error) {
+	switch mode {
+	case "", "off":
+		return tls.NoClientCert, nil, nil
+	case "optional", "required":
+	default:
+		return tls.NoClientCert, nil, fmt.Errorf("unknown tls.client_auth %q, must be off, optional or required", mode)
+	}
+	switch userName {
+	case "", certauth.CommonName, certauth.DNSName, certauth.EmailName:
+	default:
+		return tls.NoClientCert, nil, fmt.Errorf("unknown tls.client_username %q, must be %s, %s or %s", userName, certauth.CommonName, certauth.DNSName, certauth.EmailName)
+	}
+
+	clientCAs := x509.NewCertPool()
+	bundle, err := ioutil.ReadFile(caFile)
+	if err == nil && !clientCAs.AppendCertsFromPEM(bundle) {
+		err = fmt.Errorf("no certificates found in %s", caFile)
+	}
+	if err != nil {
+		if mode == "required" {
+			return tls.NoClientCert, nil, err
+		}
+		logger.Crit("Could not load the client CA bundle, client certificates will not be accepted", "file", caFile, "err", err)
+		return tls.NoClientCert, nil, nil
+	}
+
+	if mode == "required" {
+		return tls.RequireAndVerifyClientCert, clientCAs, nil
+	}
+	return tls.VerifyClientCertIfGiven, clientCAs, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/superchalupa/go-redfish/src/log"
+)
+
+// testLogger keeps what was logged
+type testLogger struct{ lines *[]string }
+
+func (l testLogger) log(level, msg string, ctx ...interface{}) {
+	*l.lines = append(*l.lines, fmt.Sprintln(level, msg, ctx))
+}
+func (l testLogger) New(ctx ...interface{}) log.Logger    { return l }
+func (l testLogger) Debug(msg string, ctx ...interface{}) { l.log("DEBUG", msg, ctx...) }
+func (l testLogger) Info(msg string, ctx ...interface{})  { l.log("INFO", msg, ctx...) }
+func (l testLogger) Warn(msg string, ctx ...interface{})  { l.log("WARN", msg, ctx...) }
+func (l testLogger) Error(msg string, ctx ...interface{}) { l.log("ERROR", msg, ctx...) }
+func (l testLogger) Crit(msg string, ctx ...interface{})  { l.log("CRIT", msg, ctx...) }
+
+// writeCA writes a self signed CA certificate to dir/ca.crt
+func writeCA(t *testing.T, dir string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := writeCA(t, dir)
+	empty := filepath.Join(dir, "empty.crt")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.crt")
+
+	tests := []struct {
+		name     string
+		mode     string
+		caFile   string
+		userName string
+		auth     tls.ClientAuthType
+		pool     bool
+		err      bool
+		logged   bool
+	}{
+		{"off", "off", missing, "", tls.NoClientCert, false, false, false},
+		{"not set", "", missing, "", tls.NoClientCert, false, false, false},
+		{"optional", "optional", ca, "cn", tls.VerifyClientCertIfGiven, true, false, false},
+		{"required", "required", ca, "dns", tls.RequireAndVerifyClientCert, true, false, false},
+		// only optional carries on without client certificates
+		{"optional, missing CA", "optional", missing, "", tls.NoClientCert, false, false, true},
+		{"optional, empty CA", "optional", empty, "", tls.NoClientCert, false, false, true},
+		{"required, missing CA", "required", missing, "", tls.NoClientCert, false, true, false},
+		{"required, empty CA", "required", empty, "", tls.NoClientCert, false, true, false},
+		{"unknown mode", "requierd", ca, "", tls.NoClientCert, false, true, false},
+		{"unknown user name", "required", ca, "upn", tls.NoClientCert, false, true, false},
+	}
+	for _, tc := range tests {
+		logger := testLogger{lines: &[]string{}}
+		auth, pool, err := clientAuth(logger, tc.mode, tc.caFile, tc.userName)
+		if auth != tc.auth || (pool != nil) != tc.pool || (err != nil) != tc.err {
+			t.Errorf("%s: auth %v, pool %v, err %v", tc.name, auth, pool != nil, err)
+		}
+		if logged := strings.Contains(strings.Join(*logger.lines, ""), "CRIT"); logged != tc.logged {
+			t.Errorf("%s: logged %v", tc.name, *logger.lines)
+		}
+	}
+}
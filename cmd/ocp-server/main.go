@@ -22,6 +22,8 @@ import (
 	log "github.com/superchalupa/go-redfish/src/log"
 
 	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+	"github.com/superchalupa/go-redfish/src/registries"
+	"github.com/superchalupa/go-redfish/src/schema"
 
 	// cert gen
 	"github.com/superchalupa/go-redfish/src/tlscert"
@@ -33,6 +35,7 @@ import (
 
 	// load openbmc plugins
 	"github.com/superchalupa/go-redfish/src/obmc"
+	"github.com/superchalupa/go-redfish/src/ocp/accounts"
 )
 
 func main() {
@@ -76,6 +79,44 @@ func Run(listen string, intr chan struct{}) {
 		cfgMgr.SetDefault("listen", []string{listen})
 	}
 	cfgMgr.SetDefault("session.timeout", 10)
+	cfgMgr.SetDefault("session.key_file", "session_keys.json")
+	cfgMgr.SetDefault("session.key_rotation", 86400)   // seconds, 0 for never
+	cfgMgr.SetDefault("session.key_grace", 86400)      // seconds, keep at least token_lifetime
+	cfgMgr.SetDefault("session.token_lifetime", 86400) // seconds
+	cfgMgr.SetDefault("session.max_sessions", 64)      // 0 for no limit
+	cfgMgr.SetDefault("session.max_sessions_per_user", 8)
+	cfgMgr.SetDefault("accounts.file", "accounts.json")
+	cfgMgr.SetDefault("accounts.min_password_length", 8)
+	cfgMgr.SetDefault("accounts.lockout_threshold", 5)    // failed logins, 0 for no lockout
+	cfgMgr.SetDefault("accounts.lockout_duration", 30)    // seconds, 0 for no lockout
+	cfgMgr.SetDefault("accounts.lockout_reset_after", 30) // seconds after the last failed login
+	cfgMgr.SetDefault("accounts.auth_failure_logging_threshold", 3)
+	cfgMgr.SetDefault("accounts.initial_password", "") // random, and written to initial_password next to the accounts file, if not set
+	// the LDAP and ActiveDirectory settings, the CA bundle for ldaps (the
+	// system roots if not set), how many seconds directory logins are cached
+	// and whether a RemoteGroup can be a group's CN rather than its whole DN
+	cfgMgr.SetDefault("accounts.directory_file", "directories.json")
+	cfgMgr.SetDefault("accounts.directory_ca", "")
+	cfgMgr.SetDefault("accounts.directory_cache_time", 60)
+	cfgMgr.SetDefault("accounts.directory_group_cn", false)
+	cfgMgr.SetDefault("oauth.issuer", "") // bearer tokens are refused until issuer and audience are set
+	cfgMgr.SetDefault("oauth.audience", "")
+	cfgMgr.SetDefault("oauth.jwks_url", "")  // bearer tokens are ignored without jwks_url or jwks_file
+	cfgMgr.SetDefault("oauth.jwks_file", "") // used instead of jwks_url if set
+	cfgMgr.SetDefault("oauth.jwks_refresh", 3600)
+	cfgMgr.SetDefault("oauth.user_claim", "preferred_username")
+	cfgMgr.SetDefault("oauth.role_claim", "groups")
+	cfgMgr.SetDefault("oauth.remote_role_mapping", []interface{}{}) // list of remote_group: local_role:
+	cfgMgr.SetDefault("collection.pagesize", 100)
+	cfgMgr.SetDefault("delete.childpolicy", "refuse")
+	cfgMgr.SetDefault("task.threshold", 5)
+	cfgMgr.SetDefault("task.timeout", 600)
+	cfgMgr.SetDefault("task.retention", 600)
+	cfgMgr.SetDefault("schema.dir", "v1/schemas")
+	cfgMgr.SetDefault("schema.validation", "off") // off, log, or strict (slow, for development)
+	cfgMgr.SetDefault("tls.client_auth", "off")   // off, optional, or required
+	cfgMgr.SetDefault("tls.client_ca", "ca.crt")
+	cfgMgr.SetDefault("tls.client_username", "cn") // cn, dns, or email: where certauth finds the account name
 
 	//flag.Parse()
 
@@ -84,17 +125,41 @@ func Run(listen string, intr chan struct{}) {
 	logger := initializeApplicationLogging(cfgMgr)
 
 	domainObjs, _ := domain.NewDomainObjects()
+	domainObjs.CollectionPageSize = cfgMgr.GetInt("collection.pagesize")
+	domainObjs.TaskThreshold = time.Duration(cfgMgr.GetInt("task.threshold")) * time.Second
+	domainObjs.TaskTimeout = time.Duration(cfgMgr.GetInt("task.timeout")) * time.Second
+	domainObjs.TaskRetention = time.Duration(cfgMgr.GetInt("task.retention")) * time.Second
+	if cfgMgr.GetString("delete.childpolicy") == "cascade" {
+		domainObjs.ChildDeletePolicy = domain.CascadeDelete
+	}
+	switch mode := cfgMgr.GetString("schema.validation"); mode {
+	case "log", "strict":
+		schemas, err := schema.Load(cfgMgr.GetString("schema.dir"))
+		if err != nil {
+			logger.Crit("Could not load schemas, resources will not be validated", "err", err)
+			break
+		}
+		if mode == "strict" {
+			domain.SetResourceValidator(schemas, domain.ValidationStrict)
+		} else {
+			domain.SetResourceValidator(schemas, domain.ValidationLog)
+		}
+	}
 	domainObjs.EventPublisher.AddObserver(logger)
 	domainObjs.CommandHandler = logger.makeLoggingCmdHandler(domainObjs.CommandHandler)
 
 	// This also initializes all of the plugins
 	domain.InitDomain(ctx, domainObjs.CommandHandler, domainObjs.EventBus, domainObjs.EventWaiter)
 
+	stdcollections.SchemaDir = cfgMgr.GetString("schema.dir")
+
 	// These three all set up a waiter for the root service to appear, so init root service after.
 	stdcollections.InitService(ctx, domainObjs.CommandHandler, domainObjs.EventBus, domainObjs.EventWaiter)
 	actionhandler.InitService(ctx, domainObjs.CommandHandler, domainObjs.EventBus, domainObjs.EventWaiter)
 
 	ocp := obmc.New(ctx, logger, cfgMgr, &cfgMgrMu, domainObjs.CommandHandler, domainObjs.EventBus, domainObjs.EventWaiter)
+	// account privileges come from the Role resources
+	ocp.GetAccountsSvc().ApplyOption(accounts.WithTree(domainObjs))
 
 	cfgMgr.OnConfigChange(func(e fsnotify.Event) {
 		cfgMgrMu.Lock()
@@ -118,23 +183,27 @@ func Run(listen string, intr chan struct{}) {
 	// per spec: redirect /redfish/v1 to /redfish/v1/
 	m.Path("/redfish/v1/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/redfish/v1", 301) })
 
-	// some static files that we should generate at some point
-	m.Path("/redfish/v1/$metadata").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.ServeFile(w, r, "v1/metadata.xml") })
-	m.Path("/redfish/v1/odata").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.ServeFile(w, r, "v1/odata.json") })
+	// generated from what is in the tree
+	m.Path("/redfish/v1/$metadata").Handler(domainObjs.GetMetadataHandler())
+	m.Path("/redfish/v1/odata").Handler(domainObjs.GetODataServiceHandler())
 
 	// serve up the schema XML
-	m.PathPrefix("/schemas/v1/").Handler(http.StripPrefix("/schemas/v1/", http.FileServer(http.Dir("./v1/schemas/"))))
+	m.PathPrefix(domain.SchemaURIPrefix).Handler(http.StripPrefix(domain.SchemaURIPrefix, http.FileServer(http.Dir(cfgMgr.GetString("schema.dir")))))
+
+	// and the message registries
+	m.PathPrefix(registries.URIPrefix).Handler(registries.Handler())
 
 	// generic handler for redfish output on most http verbs
 	// Note: this works by using the session service to get user details from token to pass up the stack using the embedded struct
 	chainAuth := func(u string, p []string) http.Handler { return domain.NewRedfishHandler(domainObjs, logger, u, p) }
+	// X-Auth-Token first, then basic auth, then a bearer token, then a TLS client certificate
 	m.PathPrefix("/redfish/v1").Methods("GET", "PUT", "POST", "PATCH", "DELETE", "HEAD", "OPTIONS").HandlerFunc(
-		ocp.GetSessionSvc().MakeHandlerFunc(domainObjs.EventBus, domainObjs, chainAuth, ocp.GetBasicAuthSvc().MakeHandlerFunc(chainAuth, chainAuth("UNKNOWN", []string{"Unauthenticated"}))))
+		ocp.GetSessionSvc().MakeHandlerFunc(domainObjs.EventBus, domainObjs, chainAuth, ocp.GetBasicAuthSvc().MakeHandlerFunc(chainAuth, ocp.GetBearerAuthSvc().MakeHandlerFunc(chainAuth, ocp.GetCertAuthSvc().MakeHandlerFunc(chainAuth, chainAuth("UNKNOWN", []string{"Unauthenticated"}))))))
 
 	// SSE
 	chainAuthSSE := func(u string, p []string) http.Handler { return domain.NewSSEHandler(domainObjs, logger, u, p) }
 	m.PathPrefix("/events").Methods("GET").HandlerFunc(
-		ocp.GetSessionSvc().MakeHandlerFunc(domainObjs.EventBus, domainObjs, chainAuthSSE, ocp.GetBasicAuthSvc().MakeHandlerFunc(chainAuthSSE, chainAuth("UNKNOWN", []string{"Unauthenticated"}))))
+		ocp.GetSessionSvc().MakeHandlerFunc(domainObjs.EventBus, domainObjs, chainAuthSSE, ocp.GetBasicAuthSvc().MakeHandlerFunc(chainAuthSSE, ocp.GetBearerAuthSvc().MakeHandlerFunc(chainAuthSSE, ocp.GetCertAuthSvc().MakeHandlerFunc(chainAuthSSE, chainAuth("UNKNOWN", []string{"Unauthenticated"}))))))
 
 	// backend command handling
 	m.PathPrefix("/api/{command}").Handler(domainObjs.GetInternalCommandHandler(ctx))
@@ -204,6 +273,17 @@ func Run(listen string, intr chan struct{}) {
 		serverCert.Serialize()
 	}
 
+	// Client certificates are checked against the CA bundle (by default the CA
+	// above), the account they name is logged in by certauth.
+	clientAuthType, clientCAs, err := clientAuth(logger, cfgMgr.GetString("tls.client_auth"), cfgMgr.GetString("tls.client_ca"), cfgMgr.GetString("tls.client_username"))
+	if err != nil {
+		logger.Crit("Could not set up client certificates, not starting", "file", cfgMgr.GetString("tls.client_ca"), "err", err)
+		fmt.Fprintf(os.Stderr, "Could not set up client certificates: %s\n", err)
+		os.Exit(1)
+	}
+	tlscfg.ClientAuth = clientAuthType
+	tlscfg.ClientCAs = clientCAs
+
 	if len(cfgMgr.GetStringSlice("listen")) == 0 {
 		fmt.Fprintf(os.Stderr, "No listeners configured! Use the '-l' option to configure a listener!")
 	}
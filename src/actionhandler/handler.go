@@ -2,7 +2,6 @@ package actionhandler
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"time"
 
@@ -51,8 +50,11 @@ func (c *POST) CommandType() eh.CommandType     { return POSTCommand }
 func (c *POST) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *POST) SetCmdID(id eh.UUID)             { c.CmdID = id }
 func (c *POST) ParseHTTPRequest(r *http.Request) error {
-	json.NewDecoder(r.Body).Decode(&c.PostBody)
-	return nil
+	return domain.DecodeJSONBody(r, &c.PostBody)
+}
+func (c *POST) ActionParameters() map[string]interface{} {
+	params, _ := c.PostBody.(map[string]interface{})
+	return params
 }
 func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
 	// Action handler needs to send HTTP response
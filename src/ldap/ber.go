@@ -0,0 +1,176 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// Just enough BER (X.690) for the LDAP messages we send and receive: single
+// byte tags and definite lengths, which is all that LDAP allows anyway.
+
+// the most we'll read for one message, a search entry with a lot of groups
+// is still way smaller than this
+const maxPacketSize = 4 << 20
+
+const (
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = constructed | 0x10
+	tagSet         = constructed | 0x11
+)
+
+var errMalformed = errors.New("ldap: malformed packet")
+
+// packet is one decoded TLV, the children are only filled in for constructed
+// ones
+type packet struct {
+	tag      byte
+	value    []byte
+	children []packet
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	b := []byte{}
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func tlv(tag byte, value []byte) []byte {
+	b := append([]byte{tag}, encodeLength(len(value))...)
+	return append(b, value...)
+}
+
+func sequence(tag byte, parts ...[]byte) []byte {
+	value := []byte{}
+	for _, p := range parts {
+		value = append(value, p...)
+	}
+	return tlv(tag, value)
+}
+
+func octetString(tag byte, s string) []byte {
+	return tlv(tag, []byte(s))
+}
+
+func integer(tag byte, n int) []byte {
+	// two's complement, big endian, as short as it can be
+	b := []byte{byte(n)}
+	for n >>= 8; n != 0 && n != -1; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	// the sign bit has to be right
+	if n == 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	} else if n == -1 && b[0]&0x80 == 0 {
+		b = append([]byte{0xff}, b...)
+	}
+	return tlv(tag, b)
+}
+
+func boolean(tag byte, v bool) []byte {
+	if v {
+		return tlv(tag, []byte{0xff})
+	}
+	return tlv(tag, []byte{0})
+}
+
+// readPacket reads one whole TLV off the connection and decodes it
+func readPacket(r *bufio.Reader) (packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	if tag&0x1f == 0x1f {
+		return packet{}, errMalformed
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 4 {
+			return packet{}, errMalformed
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return packet{}, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return packet{}, errMalformed
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return packet{}, err
+	}
+	return decode(tag, value)
+}
+
+func decode(tag byte, value []byte) (packet, error) {
+	p := packet{tag: tag, value: value}
+	if tag&constructed == 0 {
+		return p, nil
+	}
+	for len(value) > 0 {
+		if len(value) < 2 || value[0]&0x1f == 0x1f {
+			return packet{}, errMalformed
+		}
+		childTag := value[0]
+		length := int(value[1])
+		header := 2
+		if value[1]&0x80 != 0 {
+			n := int(value[1] & 0x7f)
+			if n == 0 || n > 4 || len(value) < 2+n {
+				return packet{}, errMalformed
+			}
+			length = 0
+			for _, b := range value[2 : 2+n] {
+				length = length<<8 | int(b)
+			}
+			header += n
+		}
+		if length < 0 || len(value)-header < length {
+			return packet{}, errMalformed
+		}
+		child, err := decode(childTag, value[header:header+length])
+		if err != nil {
+			return packet{}, err
+		}
+		p.children = append(p.children, child)
+		value = value[header+length:]
+	}
+	return p, nil
+}
+
+func (p packet) int() int {
+	if len(p.value) == 0 || len(p.value) > 4 {
+		return 0
+	}
+	n := int(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+func (p packet) string() string {
+	return string(p.value)
+}
@@ -0,0 +1,314 @@
+// Package ldap is a small LDAPv3 (RFC 4511) client, just simple binds and
+// searches, which is all that logging in users from a directory needs.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	appBindRequest       = classApplication | constructed | 0
+	appBindResponse      = classApplication | constructed | 1
+	appUnbindRequest     = classApplication | 2
+	appSearchRequest     = classApplication | constructed | 3
+	appSearchEntry       = classApplication | constructed | 4
+	appSearchDone        = classApplication | constructed | 5
+	appSearchReference   = classApplication | constructed | 19
+	appExtendedResponse  = classApplication | constructed | 24
+	ctxSimpleAuth        = classContext | 0
+	scopeWholeSubtree    = 2
+	derefNever           = 0
+	defaultLDAPPort      = "389"
+	defaultLDAPSPort     = "636"
+	defaultSearchTimeout = 10
+)
+
+// Result codes that callers care about, the rest are just errors
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultInvalidCredentials = 49
+)
+
+// Error is a non-success result from the server
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsInvalidCredentials is true for errors from a bind with the wrong DN or
+// password
+func IsInvalidCredentials(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.ResultCode == ResultInvalidCredentials
+}
+
+// Entry is one search result
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Values returns the values of an attribute, attribute names aren't case
+// sensitive
+func (e Entry) Values(attribute string) []string {
+	for name, values := range e.Attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Filter is a search filter. They are built up from values rather than
+// parsed from a string, so a user name can't change what is searched for.
+type Filter interface {
+	encode() []byte
+}
+
+type and []Filter
+type or []Filter
+type equal struct{ attribute, value string }
+type present string
+
+// And matches entries that match all of the filters
+func And(filters ...Filter) Filter { return and(filters) }
+
+// Or matches entries that match any of the filters
+func Or(filters ...Filter) Filter { return or(filters) }
+
+// Equal matches entries with the value in the attribute
+func Equal(attribute, value string) Filter { return equal{attribute, value} }
+
+// Present matches entries that have the attribute
+func Present(attribute string) Filter { return present(attribute) }
+
+func encodeFilters(tag byte, filters []Filter) []byte {
+	parts := [][]byte{}
+	for _, f := range filters {
+		parts = append(parts, f.encode())
+	}
+	return sequence(tag, parts...)
+}
+
+func (f and) encode() []byte     { return encodeFilters(classContext|constructed|0, f) }
+func (f or) encode() []byte      { return encodeFilters(classContext|constructed|1, f) }
+func (f present) encode() []byte { return octetString(classContext|7, string(f)) }
+func (f equal) encode() []byte {
+	return sequence(classContext|constructed|3, octetString(tagOctetString, f.attribute), octetString(tagOctetString, f.value))
+}
+
+// Conn is a connection to a directory server. It isn't safe to use from more
+// than one goroutine, the requests are made one at a time.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	msgID   int
+	timeout time.Duration
+}
+
+// Dial connects to an "ldap://host[:port]" or "ldaps://host[:port]" address,
+// or a plain "host:port" which is ldap. ldaps uses the tls config, with the
+// server name filled in from the address if it isn't set.
+func Dial(address string, tlsConfig *tls.Config, timeout time.Duration) (*Conn, error) {
+	scheme, hostPort := "ldap", address
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, err
+		}
+		scheme, hostPort = strings.ToLower(u.Scheme), u.Host
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = hostPort, ""
+	}
+	if host == "" {
+		return nil, fmt.Errorf("ldap: no host in address %q", address)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch scheme {
+	case "ldap":
+		if port == "" {
+			port = defaultLDAPPort
+		}
+		conn, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = defaultLDAPSPort
+		}
+		config := &tls.Config{}
+		if tlsConfig != nil {
+			config = tlsConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), config)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn, timeout), nil
+}
+
+// NewConn is a Conn over a connection that is already open. Each request
+// has to be answered within timeout, 0 for no limit.
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	c.send(tlv(appUnbindRequest, nil))
+	return c.conn.Close()
+}
+
+// send writes a request and returns its message ID
+func (c *Conn) send(op []byte) (int, error) {
+	c.msgID++
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := c.conn.Write(sequence(tagSequence, integer(tagInteger, c.msgID), op))
+	return c.msgID, err
+}
+
+// receive reads the next message for the request and returns its protocol op
+func (c *Conn) receive(msgID int) (packet, error) {
+	for {
+		msg, err := readPacket(c.r)
+		if err != nil {
+			return packet{}, err
+		}
+		if msg.tag != tagSequence || len(msg.children) < 2 {
+			return packet{}, errMalformed
+		}
+		op := msg.children[1]
+		switch id := msg.children[0].int(); {
+		case id == msgID:
+			return op, nil
+		case id == 0 && op.tag == appExtendedResponse:
+			// notice of disconnection, the server is going away
+			return packet{}, resultError(op)
+		}
+		// anything else is left over from an earlier request
+	}
+}
+
+// resultError returns the error in an LDAPResult, or nil if it succeeded
+func resultError(op packet) error {
+	if len(op.children) < 3 {
+		return errMalformed
+	}
+	code := op.children[0].int()
+	if code == ResultSuccess {
+		return nil
+	}
+	return &Error{ResultCode: code, Message: op.children[2].string()}
+}
+
+// Bind is a simple bind. An empty password is refused here rather than sent,
+// because servers take that as an unauthenticated bind and let it through
+// (RFC 4513 5.1.2).
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return &Error{ResultCode: ResultInvalidCredentials, Message: "empty password"}
+	}
+	msgID, err := c.send(sequence(appBindRequest,
+		integer(tagInteger, 3),
+		octetString(tagOctetString, dn),
+		octetString(ctxSimpleAuth, password),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(msgID)
+	if err != nil {
+		return err
+	}
+	if op.tag != appBindResponse {
+		return errMalformed
+	}
+	return resultError(op)
+}
+
+// Search looks for the entries under baseDN that match the filter, and
+// returns the attributes asked for. At most sizeLimit entries are returned,
+// 0 for the server's limit.
+func (c *Conn) Search(baseDN string, filter Filter, attributes []string, sizeLimit int) ([]Entry, error) {
+	attrs := [][]byte{}
+	for _, a := range attributes {
+		attrs = append(attrs, octetString(tagOctetString, a))
+	}
+	msgID, err := c.send(sequence(appSearchRequest,
+		octetString(tagOctetString, baseDN),
+		integer(tagEnumerated, scopeWholeSubtree),
+		integer(tagEnumerated, derefNever),
+		integer(tagInteger, sizeLimit),
+		integer(tagInteger, defaultSearchTimeout),
+		boolean(tagBoolean, false),
+		filter.encode(),
+		sequence(tagSequence, attrs...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for {
+		op, err := c.receive(msgID)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case appSearchEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case appSearchReference:
+			// we don't chase referrals
+		case appSearchDone:
+			return entries, resultError(op)
+		default:
+			return nil, errMalformed
+		}
+	}
+}
+
+func parseEntry(op packet) (Entry, error) {
+	if len(op.children) < 2 || op.children[1].tag != tagSequence {
+		return Entry{}, errMalformed
+	}
+	entry := Entry{DN: op.children[0].string(), Attributes: map[string][]string{}}
+	for _, attr := range op.children[1].children {
+		if len(attr.children) < 2 || attr.children[1].tag != tagSet {
+			return Entry{}, errMalformed
+		}
+		name := attr.children[0].string()
+		for _, v := range attr.children[1].children {
+			entry.Attributes[name] = append(entry.Attributes[name], v.string())
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,165 @@
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// serve answers the requests on the server end of a pipe with the replies
+// from answer, and sends the requests it got down the channel when the client
+// is done
+func serve(server net.Conn, answer func(op packet) [][]byte) <-chan []packet {
+	done := make(chan []packet, 1)
+	go func() {
+		defer server.Close()
+		requests := []packet{}
+		r := bufio.NewReader(server)
+		for {
+			msg, err := readPacket(r)
+			if err != nil || len(msg.children) < 2 || msg.children[1].tag == appUnbindRequest {
+				done <- requests
+				return
+			}
+			requests = append(requests, msg.children[1])
+			for _, op := range answer(msg.children[1]) {
+				server.Write(sequence(tagSequence, integer(tagInteger, msg.children[0].int()), op))
+			}
+		}
+	}()
+	return done
+}
+
+func result(tag byte, code int) []byte {
+	return sequence(tag, integer(tagEnumerated, code), octetString(tagOctetString, ""), octetString(tagOctetString, "message"))
+}
+
+func entry(dn string, groups ...string) []byte {
+	values := [][]byte{}
+	for _, g := range groups {
+		values = append(values, octetString(tagOctetString, g))
+	}
+	return sequence(appSearchEntry, octetString(tagOctetString, dn),
+		sequence(tagSequence, sequence(tagSequence, octetString(tagOctetString, "memberOf"), sequence(tagSet, values...))))
+}
+
+func TestBind(t *testing.T) {
+	client, server := net.Pipe()
+	done := serve(server, func(op packet) [][]byte {
+		if op.children[2].string() == "secret" {
+			return [][]byte{result(appBindResponse, ResultSuccess)}
+		}
+		return [][]byte{result(appBindResponse, ResultInvalidCredentials)}
+	})
+	c := NewConn(client, time.Second)
+
+	if err := c.Bind("cn=admin", "secret"); err != nil {
+		t.Errorf("bind failed: %v", err)
+	}
+	if err := c.Bind("cn=admin", "wrong"); !IsInvalidCredentials(err) {
+		t.Errorf("bind with the wrong password: %v", err)
+	}
+	if err := c.Bind("cn=admin", ""); !IsInvalidCredentials(err) {
+		t.Errorf("bind with an empty password: %v", err)
+	}
+	c.Close()
+
+	requests := <-done
+	if len(requests) != 2 {
+		t.Fatalf("%d binds sent, the empty password shouldn't have been", len(requests))
+	}
+	for _, op := range requests {
+		if op.tag != appBindRequest || op.children[0].int() != 3 || op.children[1].string() != "cn=admin" {
+			t.Errorf("bad bind request %+v", op)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies [][]byte
+		entries int
+		err     bool
+	}{
+		{"no entries", [][]byte{result(appSearchDone, ResultSuccess)}, 0, false},
+		{"one entry", [][]byte{
+			entry("uid=alice,dc=example", "cn=admins,dc=example", "cn=staff,dc=example"),
+			result(appSearchDone, ResultSuccess),
+		}, 1, false},
+		{"two entries", [][]byte{
+			entry("uid=alice,ou=a,dc=example"),
+			sequence(appSearchReference, octetString(tagOctetString, "ldap://elsewhere")),
+			entry("uid=alice,ou=b,dc=example"),
+			result(appSearchDone, ResultSuccess),
+		}, 2, false},
+		{"size limit", [][]byte{
+			entry("uid=alice,ou=a,dc=example"),
+			entry("uid=alice,ou=b,dc=example"),
+			result(appSearchDone, ResultSizeLimitExceeded),
+		}, 2, true},
+		{"refused", [][]byte{result(appSearchDone, 50)}, 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			done := serve(server, func(op packet) [][]byte { return tc.replies })
+			c := NewConn(client, time.Second)
+
+			entries, err := c.Search("dc=example", And(Equal("uid", "alice"), Present("objectClass")), []string{"memberOf"}, 2)
+			c.Close()
+			if (err != nil) != tc.err {
+				t.Fatalf("error is %v", err)
+			}
+			if len(entries) != tc.entries {
+				t.Fatalf("%d entries, expected %d", len(entries), tc.entries)
+			}
+			if tc.name == "one entry" {
+				if groups := entries[0].Values("MEMBEROF"); len(groups) != 2 || groups[1] != "cn=staff,dc=example" {
+					t.Errorf("groups are %q", groups)
+				}
+			}
+
+			requests := <-done
+			if len(requests) != 1 || requests[0].tag != appSearchRequest {
+				t.Fatalf("requests were %+v", requests)
+			}
+			search := requests[0].children
+			if search[0].string() != "dc=example" || search[3].int() != 2 {
+				t.Errorf("bad search request %+v", search)
+			}
+			filter := search[6]
+			if filter.tag != classContext|constructed|0 || len(filter.children) != 2 ||
+				filter.children[0].children[0].string() != "uid" || filter.children[0].children[1].string() != "alice" ||
+				filter.children[1].string() != "objectClass" {
+				t.Errorf("bad filter %+v", filter)
+			}
+		})
+	}
+}
+
+func TestInteger(t *testing.T) {
+	tests := []struct {
+		n       int
+		encoded []byte
+	}{
+		{0, []byte{0}},
+		{127, []byte{0x7f}},
+		{128, []byte{0, 0x80}},
+		{256, []byte{1, 0}},
+		{-1, []byte{0xff}},
+		{-129, []byte{0xff, 0x7f}},
+	}
+	for _, tc := range tests {
+		b := integer(tagInteger, tc.n)
+		if string(b[2:]) != string(tc.encoded) {
+			t.Errorf("%d encoded as % x", tc.n, b[2:])
+		}
+		p, err := decode(tagInteger, b[2:])
+		if err != nil || p.int() != tc.n {
+			t.Errorf("% x decoded as %d, %v", b[2:], p.int(), err)
+		}
+	}
+}
@@ -5,6 +5,8 @@ package obmc
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/godbus/dbus"
@@ -15,7 +17,7 @@ import (
 	domain "github.com/superchalupa/go-redfish/src/redfishresource"
 )
 
-func BMCReset(ctx context.Context, event eh.Event, res *domain.HTTPCmdProcessedData, eb eh.EventBus) {
+func BMCReset(ctx context.Context, event eh.Event, res *domain.HTTPCmdProcessedData) {
 	logger := log.MustLogger("bmc_reset")
 
 	bus := "org.openbmc.control.Bmc"
@@ -24,7 +26,13 @@ func BMCReset(ctx context.Context, event eh.Event, res *domain.HTTPCmdProcessedD
 
 	logger.Debug("resetType raw", "event", event.Data())
 	ad := event.Data().(ah.GenericActionEventData)
-	resetType, _ := ad.ActionData.(map[string]interface{})["ResetType"]
+	params, _ := ad.ActionData.(map[string]interface{})
+	resetType, ok := params["ResetType"]
+	if !ok {
+		*res = domain.NewErrorResponse(ad.CmdID, domain.NewRedfishError(http.StatusBadRequest, "ActionParameterMissing", "Manager.Reset", "ResetType"))
+		return
+	}
+
 	call := "undefined"
 	if resetType == "ForceRestart" {
 		call = "coldReset"
@@ -34,17 +42,16 @@ func BMCReset(ctx context.Context, event eh.Event, res *domain.HTTPCmdProcessedD
 	}
 	logger.Info("Parsed reset type", "resetType", resetType)
 	if call == "undefined" {
+		// the domain checks against ResetType@Redfish.AllowableValues, so this only happens if they get out of sync
+		*res = domain.NewErrorResponse(ad.CmdID, domain.NewRedfishError(http.StatusBadRequest, "ActionParameterValueNotInList", fmt.Sprintf("%v", resetType), "ResetType", "Manager.Reset"))
 		return
 	}
 
-	statusCode := 200
-	statusMessage := "OK"
-
 	conn, err := dbus.SystemBus()
 	if err != nil {
 		logger.Error("Cannot connect to System Bus", "err", err)
-		statusCode = 501
-		statusMessage = "ERROR: Cannot attach to dbus system bus"
+		*res = domain.NewErrorResponse(ad.CmdID, domain.NewRedfishError(http.StatusInternalServerError, "InternalError"))
+		return
 	}
 
 	dh := mydbus.NewDbusHelper(conn, bus, path)
@@ -52,14 +59,16 @@ func BMCReset(ctx context.Context, event eh.Event, res *domain.HTTPCmdProcessedD
 	defer cancel()
 	_, err = dh.DbusCall(timedctx, 0, intfc+"."+call)
 	if err != nil {
-		statusCode = 501
-		statusMessage = "Internal call failed"
+		logger.Error("Internal call failed", "call", call, "err", err)
+		*res = domain.NewErrorResponse(ad.CmdID, domain.NewRedfishError(http.StatusInternalServerError, "InternalError"))
+		return
 	}
 
-	eb.PublishEvent(ctx, eh.NewEvent(domain.HTTPCmdProcessed, domain.HTTPCmdProcessedData{
-		CommandID:  event.Data().(ah.GenericActionEventData).CmdID,
-		Results:    map[string]interface{}{"RESET": statusMessage},
-		StatusCode: statusCode,
+	// the action stream processor publishes the response
+	*res = domain.HTTPCmdProcessedData{
+		CommandID:  ad.CmdID,
+		Results:    map[string]interface{}{"RESET": "OK"},
+		StatusCode: http.StatusOK,
 		Headers:    map[string]string{},
-	}, time.Now()))
+	}
 }
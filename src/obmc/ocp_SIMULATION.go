@@ -6,7 +6,9 @@ package obmc
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 	"io/ioutil"
@@ -19,8 +21,11 @@ import (
 
 	"github.com/superchalupa/go-redfish/src/log"
 	plugins "github.com/superchalupa/go-redfish/src/ocp"
+	"github.com/superchalupa/go-redfish/src/ocp/accounts"
 	"github.com/superchalupa/go-redfish/src/ocp/basicauth"
+	"github.com/superchalupa/go-redfish/src/ocp/bearerauth"
 	"github.com/superchalupa/go-redfish/src/ocp/bmc"
+	"github.com/superchalupa/go-redfish/src/ocp/certauth"
 	"github.com/superchalupa/go-redfish/src/ocp/chassis"
 	"github.com/superchalupa/go-redfish/src/ocp/protocol"
 	"github.com/superchalupa/go-redfish/src/ocp/root"
@@ -35,13 +40,19 @@ type ocp struct {
 	rootSvc             *root.Service
 	sessionSvc          *session.Service
 	basicAuthSvc        *basicauth.Service
+	certAuthSvc         *certauth.Service
+	bearerAuthSvc       *bearerauth.Service
+	accountsSvc         *accounts.Service
 	configChangeHandler func()
 	logger              log.Logger
 }
 
-func (o *ocp) GetSessionSvc() *session.Service     { return o.sessionSvc }
-func (o *ocp) GetBasicAuthSvc() *basicauth.Service { return o.basicAuthSvc }
-func (o *ocp) ConfigChangeHandler()                { o.configChangeHandler() }
+func (o *ocp) GetSessionSvc() *session.Service       { return o.sessionSvc }
+func (o *ocp) GetBasicAuthSvc() *basicauth.Service   { return o.basicAuthSvc }
+func (o *ocp) GetCertAuthSvc() *certauth.Service     { return o.certAuthSvc }
+func (o *ocp) GetBearerAuthSvc() *bearerauth.Service { return o.bearerAuthSvc }
+func (o *ocp) GetAccountsSvc() *accounts.Service     { return o.accountsSvc }
+func (o *ocp) ConfigChangeHandler()                  { o.configChangeHandler() }
 
 func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, viperMu *sync.Mutex, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) *ocp {
 	// initial implementation is one BMC, one Chassis, and one System.
@@ -54,11 +65,55 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, viperMu *s
 
 	self.rootSvc, _ = root.New()
 
+	accountsSvc, _ := accounts.New(
+		accounts.Root(self.rootSvc),
+		accounts.WithFile(cfgMgr.GetString("accounts.file")),
+		accounts.InitialPassword(cfgMgr.GetString("accounts.initial_password")),
+		accounts.DirectoryFile(cfgMgr.GetString("accounts.directory_file")),
+		accounts.DirectoryCA(cfgMgr.GetString("accounts.directory_ca")),
+		accounts.DirectoryCacheTime(time.Duration(cfgMgr.GetInt("accounts.directory_cache_time"))*time.Second),
+		accounts.DirectoryGroupCN(cfgMgr.GetBool("accounts.directory_group_cn")),
+	)
+	self.accountsSvc = accountsSvc
+
+	// accounts service properties and the config keys they are saved under
+	accountsConfig := map[string]string{
+		"min_password_length":            "accounts.min_password_length",
+		"lockout_threshold":              "accounts.lockout_threshold",
+		"lockout_duration":               "accounts.lockout_duration",
+		"lockout_reset_after":            "accounts.lockout_reset_after",
+		"auth_failure_logging_threshold": "accounts.auth_failure_logging_threshold",
+	}
+
 	self.sessionSvc, _ = session.New(
 		session.Root(self.rootSvc),
+		session.WithAuthenticator(accountsSvc),
+		session.KeyFile(cfgMgr.GetString("session.key_file")),
+		session.KeyRotation(time.Duration(cfgMgr.GetInt("session.key_rotation"))*time.Second),
+		session.KeyGrace(time.Duration(cfgMgr.GetInt("session.key_grace"))*time.Second),
+		session.TokenLifetime(time.Duration(cfgMgr.GetInt("session.token_lifetime"))*time.Second),
 	)
 
-	self.basicAuthSvc, _ = basicauth.New()
+	self.basicAuthSvc, _ = basicauth.New(
+		basicauth.WithAuthenticator(accountsSvc),
+	)
+
+	self.certAuthSvc, _ = certauth.New(
+		certauth.WithAccounts(accountsSvc),
+		certauth.UserName(cfgMgr.GetString("tls.client_username")),
+	)
+
+	self.bearerAuthSvc, _ = bearerauth.New(
+		bearerauth.WithRoles(accountsSvc),
+		bearerauth.Issuer(cfgMgr.GetString("oauth.issuer")),
+		bearerauth.Audience(cfgMgr.GetString("oauth.audience")),
+		bearerauth.UserClaim(cfgMgr.GetString("oauth.user_claim")),
+		bearerauth.RoleClaim(cfgMgr.GetString("oauth.role_claim")),
+		bearerauth.RemoteRoleMapping(bearerauth.RoleMappings(cfgMgr.Get("oauth.remote_role_mapping"))...),
+		bearerauth.JWKSURL(cfgMgr.GetString("oauth.jwks_url")),
+		bearerauth.JWKSFile(cfgMgr.GetString("oauth.jwks_file")),
+		bearerauth.JWKSRefresh(time.Duration(cfgMgr.GetInt("oauth.jwks_refresh"))*time.Second),
+	)
 
 	bmcSvc, _ := bmc.New(
 		bmc.WithUniqueName("OBMC"),
@@ -150,6 +205,11 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, viperMu *s
 		logger.Info("Re-applying configuration from config file.")
 
 		self.sessionSvc.ApplyOption(plugins.UpdateProperty("session_timeout", cfgMgr.GetInt("session.timeout")))
+		self.sessionSvc.ApplyOption(plugins.UpdateProperty("max_sessions", cfgMgr.GetInt("session.max_sessions")))
+		self.sessionSvc.ApplyOption(plugins.UpdateProperty("max_sessions_per_user", cfgMgr.GetInt("session.max_sessions_per_user")))
+		for property, key := range accountsConfig {
+			accountsSvc.ApplyOption(plugins.UpdateProperty(property, cfgMgr.GetInt(key)))
+		}
 
 		for _, k := range []string{"name", "description", "model", "timezone", "version"} {
 			bmcSvc.ApplyOption(plugins.UpdateProperty(k, cfgMgr.Get("managers.OBMC."+k)))
@@ -248,12 +308,25 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, viperMu *s
 		dumpViperConfig()
 	})
 
+	for property, key := range accountsConfig {
+		key := key
+		accountsSvc.AddPropertyObserver(property, func(newval interface{}) {
+			viperMu.Lock()
+			cfgMgr.Set(key, newval.(int))
+			viperMu.Unlock()
+			dumpViperConfig()
+		})
+	}
+
 	// register all of the plugins (do this first so we dont get any race
 	// conditions if somebody accesses the URIs before these plugins are
 	// registered
 	domain.RegisterPlugin(func() domain.Plugin { return self.rootSvc })
 	domain.RegisterPlugin(func() domain.Plugin { return self.sessionSvc })
 	domain.RegisterPlugin(func() domain.Plugin { return self.basicAuthSvc })
+	domain.RegisterPlugin(func() domain.Plugin { return self.certAuthSvc })
+	domain.RegisterPlugin(func() domain.Plugin { return self.bearerAuthSvc })
+	domain.RegisterPlugin(func() domain.Plugin { return accountsSvc })
 	domain.RegisterPlugin(func() domain.Plugin { return bmcSvc })
 	domain.RegisterPlugin(func() domain.Plugin { return protocolSvc })
 	domain.RegisterPlugin(func() domain.Plugin { return chas })
@@ -266,6 +339,9 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, viperMu *s
 	self.rootSvc.AddResource(ctx, ch, eb, ew)
 	self.sessionSvc.AddResource(ctx, ch, eb, ew)
 	self.basicAuthSvc.AddResource(ctx, ch, eb, ew)
+	self.certAuthSvc.AddResource(ctx, ch, eb, ew)
+	self.bearerAuthSvc.AddResource(ctx, ch, eb, ew)
+	accountsSvc.AddResource(ctx, ch, eb, ew)
 	bmcSvc.AddResource(ctx, ch, eb, ew)
 	protocolSvc.AddResource(ctx, ch)
 	chas.AddResource(ctx, ch)
@@ -276,11 +352,13 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, viperMu *s
 
 	bmcSvc.ApplyOption(plugins.UpdateProperty("manager.reset", func(event eh.Event, res *domain.HTTPCmdProcessedData) {
 		self.logger.Crit("Hello WORLD!\n\tGOT RESET EVENT\n")
+		res.StatusCode = http.StatusOK
 		res.Results = map[string]interface{}{"RESET": "FAKE SIMULATED BMC RESET"}
 	}))
 
 	system.ApplyOption(plugins.UpdateProperty("computersystem.reset", func(event eh.Event, res *domain.HTTPCmdProcessedData) {
 		self.logger.Crit("Hello WORLD!\n\tGOT RESET EVENT\n")
+		res.StatusCode = http.StatusOK
 		res.Results = map[string]interface{}{"RESET": "FAKE SIMULATED COMPUTER RESET"}
 	}))
 
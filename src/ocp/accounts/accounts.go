@@ -0,0 +1,663 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/superchalupa/go-redfish/src/log"
+	plugins "github.com/superchalupa/go-redfish/src/ocp"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+)
+
+const (
+	AccountsPlugin = domain.PluginType("obmc_accounts")
+
+	AccountServiceURI = "/redfish/v1/AccountService"
+	AccountsURI       = AccountServiceURI + "/Accounts"
+	RolesURI          = AccountServiceURI + "/Roles"
+
+	// the ManagerAccount resources find their PATCH and DELETE commands with this
+	accountPlugin = "ManagerAccount"
+	// and the AccountService its PATCH command
+	accountServicePlugin = "AccountService"
+
+	// created when there are no accounts at all, so that somebody can log in
+	initialUserName = "Administrator"
+	initialRoleID   = "Admin"
+	// its generated password is written to this file, next to the accounts
+	initialPasswordFile = "initial_password"
+)
+
+type uuidObj interface {
+	GetUUID() eh.UUID
+}
+
+// the roles are Role resources in the tree, we read their privileges from there
+type propertyReader interface {
+	GetResourceProperty(ctx context.Context, uri, property string) (interface{}, bool)
+}
+
+// Service is the AccountService: the local accounts, kept in a file, and the
+// ManagerAccount resources for them. Users without a local account can log
+// in with the LDAP or ActiveDirectory service, if one is enabled.
+type Service struct {
+	*plugins.Service
+	root            uuidObj
+	tree            propertyReader
+	store           *store
+	lockout         lockout
+	filename        string
+	initialPassword string
+
+	directories        *directories
+	directoryFile      string
+	directoryCA        string
+	directoryCacheTime time.Duration
+	directoryGroupCN   bool
+}
+
+var _ = plugins.Authenticator(&Service{})
+var _ = plugins.PrivilegeGetter(&Service{})
+
+func New(options ...interface{}) (*Service, error) {
+	s := &Service{
+		Service: plugins.NewService(plugins.PluginType(AccountsPlugin)),
+		lockout: lockout{accounts: map[string]*failures{}},
+
+		directoryCacheTime: time.Minute,
+	}
+
+	// defaults
+	s.intProperty("min_password_length", 8, 1)
+	s.intProperty("lockout_threshold", 5, 0)
+	s.intProperty("lockout_duration", 30, 0)
+	s.intProperty("lockout_reset_after", 30, 0)
+	s.intProperty("auth_failure_logging_threshold", 3, 0)
+
+	s.ApplyOption(plugins.UUID())
+	s.ApplyOption(options...)
+	return s, nil
+}
+
+// intProperty sets up a number property of the service that can be PATCHed
+// to anything that isn't less than min
+func (s *Service) intProperty(name string, value, min int) {
+	s.UpdatePropertyUnlocked(name, value)
+	s.UpdatePropertyUnlocked(name+"@meta.validator",
+		func(rrp *domain.RedfishResourceProperty, body interface{}) {
+			// already locked when we are called
+			bodyFloat, ok := body.(float64)
+			if ok && bodyFloat >= float64(min) {
+				newval := int(bodyFloat)
+				s.UpdatePropertyUnlocked(name, newval)
+				rrp.Value = newval
+			}
+		})
+}
+
+func Root(obj uuidObj) Option {
+	return func(s *Service) error {
+		s.root = obj
+		return nil
+	}
+}
+
+// WithTree is where the Role resources are read from, the DomainObjects
+func WithTree(tree propertyReader) Option {
+	return func(s *Service) error {
+		s.tree = tree
+		return nil
+	}
+}
+
+// WithFile is where the accounts are saved
+func WithFile(filename string) Option {
+	return func(s *Service) error {
+		s.filename = filename
+		return nil
+	}
+}
+
+// InitialPassword is the password of the Administrator account that is
+// created when there are no accounts. If it isn't set, a random one is
+// generated and written to a file next to the accounts file.
+func InitialPassword(password string) Option {
+	return func(s *Service) error {
+		s.initialPassword = password
+		return nil
+	}
+}
+
+// DirectoryFile is where the LDAP and ActiveDirectory settings are saved
+func DirectoryFile(filename string) Option {
+	return func(s *Service) error {
+		s.directoryFile = filename
+		return nil
+	}
+}
+
+// DirectoryCA is the CA bundle that ldaps servers are checked against, the
+// system roots are used if it isn't set.
+func DirectoryCA(filename string) Option {
+	return func(s *Service) error {
+		s.directoryCA = filename
+		return nil
+	}
+}
+
+// DirectoryCacheTime is how long a directory login is remembered before
+// the directory is asked again, 0 to always ask.
+func DirectoryCacheTime(d time.Duration) Option {
+	return func(s *Service) error {
+		s.directoryCacheTime = d
+		return nil
+	}
+}
+
+// Authenticate checks the password of an enabled account that isn't locked
+// out, and returns the privileges of its role. Users that don't have a local
+// account are checked against the directories instead, and get the
+// privileges of the roles their groups are mapped to.
+func (s *Service) Authenticate(username, password string) ([]string, bool) {
+	if s.store == nil {
+		return nil, false
+	}
+	if s.isLocked(username) {
+		return nil, false
+	}
+	if _, local := s.store.get(username); !local && s.directories != nil {
+		// the directory has its own lockout, only count these for logging
+		login, ok := s.directories.login(username, password)
+		if !ok {
+			s.loginFailed(username, false)
+			return nil, false
+		}
+		privileges, ok := s.remotePrivileges(username, login)
+		if !ok {
+			log.MustLogger("accounts").Info("Directory user has no mapped role", "UserName", username, "provider", login.provider)
+			s.loginFailed(username, false)
+			return nil, false
+		}
+		s.loginSucceeded(username)
+		return privileges, true
+	}
+	a, ok := s.store.check(username, password)
+	if !ok {
+		_, known := s.store.get(username)
+		s.loginFailed(username, known)
+		return nil, false
+	}
+	s.loginSucceeded(username)
+	return s.accountPrivileges(a), true
+}
+
+// Privileges looks up the privileges of an account that has already logged
+// in, as its role has them now. Directory users have the roles of the groups
+// they were in when they last logged in.
+func (s *Service) Privileges(username string) ([]string, bool) {
+	if s.store == nil {
+		return nil, false
+	}
+	a, ok := s.store.get(username)
+	if !ok {
+		if login, ok := s.remoteUser(username); ok {
+			return s.remotePrivileges(username, login)
+		}
+		return nil, false
+	}
+	if !a.Enabled {
+		return nil, false
+	}
+	return s.accountPrivileges(a), true
+}
+
+// RolePrivileges returns the privileges of the Role resource, for users that
+// have no account here
+func (s *Service) RolePrivileges(roleID string) ([]string, bool) {
+	return s.rolePrivileges(roleID)
+}
+
+func (s *Service) accountPrivileges(a account) []string {
+	privileges := []string{"ConfigureSelf_" + a.UserName}
+	rolePrivileges, _ := s.rolePrivileges(a.RoleID)
+	return append(privileges, rolePrivileges...)
+}
+
+func (s *Service) intSetting(name string) int {
+	i, _ := s.GetProperty(name).(int)
+	return i
+}
+
+func (s *Service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) {
+	logger := log.MustLogger("accounts")
+
+	var err error
+	s.store, err = loadStore(s.filename)
+	if err != nil {
+		// don't save over a file that we couldn't read, keep the accounts in memory
+		logger.Crit("Could not load accounts, changes will not be saved", "file", s.filename, "err", err)
+		s.store = &store{accounts: map[string]*account{}}
+	}
+	if len(s.store.list()) == 0 {
+		s.addInitialAccount(logger)
+	}
+
+	directories, err := loadDirectories(s.directoryFile)
+	if err != nil {
+		// same as the accounts, and the directories stay off until they are set up again
+		logger.Crit("Could not load directory settings, changes will not be saved", "file", s.directoryFile, "err", err)
+		directories, _ = loadDirectories("")
+	}
+	directories.caFile = s.directoryCA
+	directories.cacheTime = s.directoryCacheTime
+	directories.groupCN = s.directoryGroupCN
+	s.directories = directories
+
+	eh.RegisterCommand(func() eh.Command { return &AccountServicePATCH{service: s} })
+	eh.RegisterCommand(func() eh.Command { return &PATCH{service: s} })
+	eh.RegisterCommand(func() eh.Command { return &DELETE{service: s} })
+	eh.RegisterCommand(func() eh.Command { return &RolePATCH{service: s} })
+	eh.RegisterCommand(func() eh.Command { return &RoleDELETE{service: s} })
+
+	ch.HandleCommand(
+		ctx,
+		&domain.CreateRedfishResource{
+			ID:          eh.NewUUID(),
+			ResourceURI: AccountServiceURI,
+			Type:        "#AccountService.v1_3_0.AccountService",
+			Context:     "/redfish/v1/$metadata#AccountService.AccountService",
+			Plugin:      accountServicePlugin,
+			Privileges: map[string]interface{}{
+				"GET":    []string{"Login"},
+				"POST":   []string{"ConfigureManager"}, // cannot create sub objects
+				"PUT":    []string{"ConfigureManager"},
+				"PATCH":  []string{"ConfigureManager"},
+				"DELETE": []string{}, // can't be deleted
+			},
+			Properties: map[string]interface{}{
+				"Id":          "AccountService",
+				"Name":        "Account Service",
+				"Description": "Account Service",
+				"Status": map[string]interface{}{
+					"State":  "Enabled",
+					"Health": "OK",
+				},
+				"ServiceEnabled":              true,
+				"AuthFailureLoggingThreshold": s.intSetting("auth_failure_logging_threshold"),
+				"AuthFailureLoggingThreshold@meta": s.Meta(
+					plugins.PropGET("auth_failure_logging_threshold"),
+					plugins.PropPATCH("auth_failure_logging_threshold"),
+				),
+				"MinPasswordLength": s.intSetting("min_password_length"),
+				"MinPasswordLength@meta": s.Meta(
+					plugins.PropGET("min_password_length"),
+					plugins.PropPATCH("min_password_length"),
+				),
+				"AccountLockoutThreshold": s.intSetting("lockout_threshold"),
+				"AccountLockoutThreshold@meta": s.Meta(
+					plugins.PropGET("lockout_threshold"),
+					plugins.PropPATCH("lockout_threshold"),
+				),
+				"AccountLockoutDuration": s.intSetting("lockout_duration"),
+				"AccountLockoutDuration@meta": s.Meta(
+					plugins.PropGET("lockout_duration"),
+					plugins.PropPATCH("lockout_duration"),
+				),
+				"AccountLockoutCounterResetAfter": s.intSetting("lockout_reset_after"),
+				"AccountLockoutCounterResetAfter@meta": s.Meta(
+					plugins.PropGET("lockout_reset_after"),
+					plugins.PropPATCH("lockout_reset_after"),
+				),
+				"LDAP":                 providerProperties(ldapProvider, s.directories.get(ldapProvider)),
+				"LDAP@meta":            s.directoryMeta(ldapProvider),
+				"ActiveDirectory":      providerProperties(adProvider, s.directories.get(adProvider)),
+				"ActiveDirectory@meta": s.directoryMeta(adProvider),
+				"Accounts":             map[string]string{"@odata.id": AccountsURI},
+				"Roles":                map[string]string{"@odata.id": RolesURI},
+			}})
+
+	ch.HandleCommand(
+		ctx,
+		&domain.CreateRedfishResource{
+			ID:         eh.NewUUID(),
+			Collection: true,
+
+			ResourceURI: AccountsURI,
+			Type:        "#ManagerAccountCollection.ManagerAccountCollection",
+			Context:     "/redfish/v1/$metadata#ManagerAccountCollection.ManagerAccountCollection",
+			Privileges: map[string]interface{}{
+				"GET":    []string{"Login"},
+				"POST":   []string{"ConfigureUsers"},
+				"PUT":    []string{}, // Read Only
+				"PATCH":  []string{}, // Read Only
+				"DELETE": []string{}, // can't be deleted
+			},
+			Properties: map[string]interface{}{
+				"Name": "Accounts Collection",
+			}})
+
+	ch.HandleCommand(ctx,
+		&domain.UpdateRedfishResourceProperties{
+			ID: s.root.GetUUID(),
+			Properties: map[string]interface{}{
+				"AccountService": map[string]interface{}{"@odata.id": AccountServiceURI},
+			},
+		})
+
+	for _, a := range s.store.list() {
+		ch.HandleCommand(ctx, &domain.CreateRedfishResource{
+			ID:          eh.NewUUID(),
+			ResourceURI: AccountsURI + "/" + a.UserName,
+			Type:        accountType,
+			Context:     accountContext,
+			Privileges:  accountPrivileges(a.UserName),
+			Plugin:      accountPlugin,
+			Properties:  s.accountProperties(a),
+			Deletable:   true,
+		})
+	}
+
+	domain.RegisterCollectionMemberFactory(AccountsURI, &domain.CollectionMemberFactory{
+		Type:    accountType,
+		Context: accountContext,
+		Plugin:  accountPlugin,
+		Properties: map[string]interface{}{
+			"Name":        "User Account",
+			"Description": "User Account",
+			"Enabled":     true,
+		},
+		IDPolicy:  domain.PropertyIDPolicy("UserName"),
+		Required:  []string{"UserName", "Password", "RoleId"},
+		Deletable: true,
+		Create:    s.createAccount,
+	})
+
+	// the predefined roles are created with the Roles collection in stdcollections
+	domain.RegisterCollectionMemberFactory(RolesURI, &domain.CollectionMemberFactory{
+		Type:       roleType,
+		Context:    roleContext,
+		Plugin:     rolePlugin,
+		Privileges: customRolePrivileges,
+		Properties: map[string]interface{}{
+			"Name":                    "User Role",
+			"Description":             "Custom User Role",
+			"IsPredefined":            false,
+			"AssignedPrivileges":      []interface{}{},
+			"AssignedPrivileges@meta": s.roleMeta("AssignedPrivileges"),
+			"OemPrivileges":           []interface{}{},
+			"OemPrivileges@meta":      s.roleMeta("OemPrivileges"),
+		},
+		IDPolicy:  domain.PropertyIDPolicy("RoleId"),
+		Required:  []string{"RoleId", "AssignedPrivileges"},
+		Deletable: true,
+		Create:    s.createRole,
+	})
+}
+
+func (s *Service) addInitialAccount(logger log.Logger) {
+	password := s.initialPassword
+	if password == "" {
+		if s.filename == "" {
+			logger.Crit("No accounts, and no initial password or accounts file to put a generated one next to. Set the initial password.")
+			return
+		}
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			logger.Crit("Could not generate a password for the initial account", "err", err)
+			return
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+		// never logged, only somebody who can read the accounts file gets it
+		file := filepath.Join(filepath.Dir(s.filename), initialPasswordFile)
+		if err := writePasswordFile(file, password); err != nil {
+			logger.Crit("Could not save the generated password for the initial account", "file", file, "err", err)
+			return
+		}
+		logger.Warn("No accounts, created the initial account with a generated password. Change it.", "UserName", initialUserName, "file", file)
+	}
+	if err := s.store.add(initialUserName, password, initialRoleID, true); err != nil {
+		logger.Crit("Could not save the initial account", "file", s.filename, "err", err)
+	}
+}
+
+// writePasswordFile replaces the file with a new one that only we can read
+func writePasswordFile(filename, password string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(password + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+const (
+	accountType    = "#ManagerAccount.v1_0_3.ManagerAccount"
+	accountContext = "/redfish/v1/$metadata#ManagerAccount.ManagerAccount"
+)
+
+// users can look at and change their own account, except for their role
+func accountPrivileges(username string) map[string]interface{} {
+	return map[string]interface{}{
+		"GET":    []string{"ConfigureUsers", "ConfigureSelf_" + username},
+		"POST":   []string{},
+		"PUT":    []string{}, // everything goes through PATCH so that it is checked
+		"PATCH":  []string{"ConfigureUsers", "ConfigureSelf_" + username},
+		"DELETE": []string{"ConfigureUsers"},
+	}
+}
+
+func (s *Service) accountMeta(property string) map[string]interface{} {
+	return map[string]interface{}{
+		"GET":   map[string]interface{}{"plugin": string(AccountsPlugin), "account": property},
+		"PATCH": map[string]interface{}{"plugin": string(AccountsPlugin), "account": property},
+	}
+}
+
+func (s *Service) accountProperties(a account) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":            a.UserName,
+		"Name":          "User Account",
+		"Description":   "User Account",
+		"UserName":      a.UserName,
+		"Password":      nil, // never shown
+		"Password@meta": s.accountMeta("Password"),
+		"RoleId":        a.RoleID,
+		"RoleId@meta":   s.accountMeta("RoleId"),
+		"Enabled":       a.Enabled,
+		"Enabled@meta":  s.accountMeta("Enabled"),
+		"Locked":        false,
+		"Locked@meta":   s.accountMeta("Locked"),
+		"Links":         map[string]interface{}{"Role": map[string]interface{}{"@odata.id": RolesURI + "/" + a.RoleID}},
+		"Links@meta":    s.accountMeta("Links"),
+	}
+}
+
+// createAccount saves a new account from a POST to the Accounts collection,
+// the factory has already checked that the properties are there.
+func (s *Service) createAccount(ctx context.Context, create *domain.CreateRedfishResource, body map[string]interface{}) error {
+	username, _ := body["UserName"].(string)
+	if rerr := s.checkAccount(username, body, true); rerr != nil {
+		return rerr
+	}
+	enabled := true
+	if e, ok := body["Enabled"].(bool); ok {
+		enabled = e
+	}
+	roleID := body["RoleId"].(string)
+	if err := s.store.add(username, body["Password"].(string), roleID, enabled); err != nil {
+		log.MustLogger("accounts").Crit("Could not save account", "UserName", username, "err", err)
+		return domain.NewRedfishError(http.StatusInternalServerError, "InternalError")
+	}
+
+	create.Privileges = accountPrivileges(username)
+	create.Properties = s.accountProperties(account{UserName: username, RoleID: roleID, Enabled: enabled})
+	return nil
+}
+
+// checkAccount checks the account properties in a POST or PATCH body
+func (s *Service) checkAccount(username string, body map[string]interface{}, create bool) *domain.RedfishError {
+	rerr := &domain.RedfishError{StatusCode: http.StatusBadRequest}
+
+	if v, ok := body["Password"]; ok {
+		if p, ok := v.(string); !ok {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", "********", "Password").WithRelatedProperties("#/Password"))
+		} else if len(p) < s.intSetting("min_password_length") {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueFormatError", "********", "Password").WithRelatedProperties("#/Password"))
+		}
+	}
+	if v, ok := body["RoleId"]; ok {
+		if r, ok := v.(string); !ok {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", v, "RoleId").WithRelatedProperties("#/RoleId"))
+		} else if _, ok := s.rolePrivileges(r); !ok {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueNotInList", r, "RoleId").WithRelatedProperties("#/RoleId"))
+		}
+	}
+	if v, ok := body["Enabled"]; ok {
+		if _, ok := v.(bool); !ok {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", v, "Enabled").WithRelatedProperties("#/Enabled"))
+		}
+	}
+	// the lockout can be cleared, but accounts are only locked by failed logins
+	if v, ok := body["Locked"]; ok {
+		if l, ok := v.(bool); !ok {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", v, "Locked").WithRelatedProperties("#/Locked"))
+		} else if l {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueNotInList", v, "Locked").WithRelatedProperties("#/Locked"))
+		}
+	}
+	if len(rerr.ExtendedInfo) > 0 {
+		return rerr
+	}
+
+	if !create && s.removesLastAdmin(username, body) {
+		return domain.NewRedfishError(http.StatusConflict, "ResourceInUse")
+	}
+	return nil
+}
+
+// removesLastAdmin returns true if the change to the account (nil for a
+// delete) would leave nobody that can manage the accounts.
+func (s *Service) removesLastAdmin(username string, body map[string]interface{}) bool {
+	accounts := []account{}
+	for _, a := range s.store.list() {
+		if a.UserName == username {
+			if body == nil {
+				continue
+			}
+			if r, ok := body["RoleId"].(string); ok {
+				a.RoleID = r
+			}
+			if e, ok := body["Enabled"].(bool); ok {
+				a.Enabled = e
+			}
+		}
+		accounts = append(accounts, a)
+	}
+	return !s.hasAdmin(accounts, s.rolePrivileges)
+}
+
+// hasAdmin returns true if one of the accounts can manage the accounts, with
+// the privileges of the roles from rolePrivileges.
+func (s *Service) hasAdmin(accounts []account, rolePrivileges func(string) ([]string, bool)) bool {
+	for _, a := range accounts {
+		if !a.Enabled {
+			continue
+		}
+		if privileges, _ := rolePrivileges(a.RoleID); hasPrivilege(privileges, "ConfigureUsers") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrivilege(privileges []string, want string) bool {
+	for _, p := range privileges {
+		if p == want {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertyGet fills in the account properties from the store, and the LDAP
+// and ActiveDirectory settings. Anything else is a property of the service.
+func (s *Service) PropertyGet(ctx context.Context, agg *domain.RedfishResourceAggregate, rrp *domain.RedfishResourceProperty, method string, meta map[string]interface{}) {
+	if name, ok := meta["directory"].(string); ok {
+		rrp.Value = providerProperties(name, s.directories.get(name))
+		return
+	}
+	property, ok := meta["account"].(string)
+	if !ok {
+		s.Service.PropertyGet(ctx, agg, rrp, method, meta)
+		return
+	}
+	a, ok := s.store.get(path.Base(agg.ResourceURI))
+	if !ok {
+		return
+	}
+	switch property {
+	case "Password":
+		rrp.Value = nil
+	case "RoleId":
+		rrp.Value = a.RoleID
+	case "Enabled":
+		rrp.Value = a.Enabled
+	case "Locked":
+		rrp.Value = s.isLocked(a.UserName)
+	case "Links":
+		rrp.Value = map[string]interface{}{"Role": map[string]interface{}{"@odata.id": RolesURI + "/" + a.RoleID}}
+	}
+}
+
+// updateAccount saves the changes in a PATCH body to the account, all at
+// once, the PATCH command has already checked them.
+func (s *Service) updateAccount(username string, body map[string]interface{}) error {
+	return s.store.update(username, func(a *account) error {
+		if password, ok := body["Password"].(string); ok {
+			hash, err := hashPassword(password)
+			if err != nil {
+				return err
+			}
+			a.PasswordHash = hash
+		}
+		if roleID, ok := body["RoleId"].(string); ok {
+			a.RoleID = roleID
+		}
+		if enabled, ok := body["Enabled"].(bool); ok {
+			a.Enabled = enabled
+		}
+		return nil
+	})
+}
+
+// PropertyPatch reflects the account or directory as the PATCH command left
+// it in the response, and sets the role privileges. Anything else is a
+// property of the service.
+func (s *Service) PropertyPatch(ctx context.Context, agg *domain.RedfishResourceAggregate, rrp *domain.RedfishResourceProperty, method string, meta map[string]interface{}, body interface{}, present bool) {
+	if _, ok := meta["role"].(string); ok {
+		s.rolePropertyPatch(rrp, body, present)
+		return
+	}
+	_, account := meta["account"].(string)
+	_, directory := meta["directory"].(string)
+	if !account && !directory {
+		s.Service.PropertyPatch(ctx, agg, rrp, method, meta, body, present)
+		return
+	}
+	s.PropertyGet(ctx, agg, rrp, "GET", meta)
+}
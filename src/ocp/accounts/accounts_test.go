@@ -0,0 +1,219 @@
+package accounts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/superchalupa/go-redfish/src/log"
+)
+
+// testLogger keeps everything that is logged, so tests can check what was
+type testLogger struct {
+	sync.Mutex
+	lines *[]string
+}
+
+func newTestLogger() *testLogger {
+	return &testLogger{lines: &[]string{}}
+}
+
+func (l *testLogger) log(level, msg string, ctx ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	*l.lines = append(*l.lines, fmt.Sprintln(level, msg, ctx))
+}
+
+func (l *testLogger) New(ctx ...interface{}) log.Logger    { return l }
+func (l *testLogger) Debug(msg string, ctx ...interface{}) { l.log("DEBUG", msg, ctx...) }
+func (l *testLogger) Info(msg string, ctx ...interface{})  { l.log("INFO", msg, ctx...) }
+func (l *testLogger) Warn(msg string, ctx ...interface{})  { l.log("WARN", msg, ctx...) }
+func (l *testLogger) Error(msg string, ctx ...interface{}) { l.log("ERROR", msg, ctx...) }
+func (l *testLogger) Crit(msg string, ctx ...interface{})  { l.log("CRIT", msg, ctx...) }
+
+func (l *testLogger) String() string {
+	l.Lock()
+	defer l.Unlock()
+	return strings.Join(*l.lines, "")
+}
+
+func TestInitialPasswordFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, _ := New(WithFile(filepath.Join(dir, "accounts.json")))
+	s.store, _ = loadStore(s.filename)
+	// left over from before, it is replaced rather than written through
+	file := filepath.Join(dir, initialPasswordFile)
+	if err := ioutil.WriteFile(file, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := newTestLogger()
+	s.addInitialAccount(logger)
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("password file mode is %v", fi.Mode().Perm())
+	}
+	b, _ := ioutil.ReadFile(file)
+	password := strings.TrimSpace(string(b))
+	if _, ok := s.store.check(initialUserName, password); !ok {
+		t.Errorf("the password in the file doesn't log in")
+	}
+	if strings.Contains(logger.String(), password) {
+		t.Errorf("the password was logged: %s", logger)
+	}
+	if !strings.Contains(logger.String(), file) {
+		t.Errorf("the password file wasn't logged: %s", logger)
+	}
+}
+
+func TestInitialPasswordConfigured(t *testing.T) {
+	s, _ := New(InitialPassword("configured"))
+	s.store, _ = loadStore("")
+	s.addInitialAccount(newTestLogger())
+	if _, ok := s.store.check(initialUserName, "configured"); !ok {
+		t.Errorf("the configured password doesn't log in")
+	}
+}
+
+func TestInitialPasswordNowhereToWrite(t *testing.T) {
+	s, _ := New()
+	s.store, _ = loadStore("")
+	logger := newTestLogger()
+	s.addInitialAccount(logger)
+	if _, ok := s.store.get(initialUserName); ok {
+		t.Errorf("created the initial account with a password nobody can know")
+	}
+	if !strings.Contains(logger.String(), "CRIT") {
+		t.Errorf("nothing logged about the missing initial account")
+	}
+}
+
+// newAdminService returns a Service with an enabled and a disabled
+// administrator, and an operator
+func newAdminService(t *testing.T) *Service {
+	log.GlobalLogger = newTestLogger()
+	s, _ := New(WithTree(testRoles{
+		"Administrator": {"Login", "ConfigureManager", "ConfigureUsers", "ConfigureSelf", "ConfigureComponents"},
+		"Operator":      {"Login", "ConfigureSelf", "ConfigureComponents"},
+		"ReadOnly":      {"Login", "ConfigureSelf"},
+	}))
+	s.store, _ = loadStore("")
+	for _, a := range []account{
+		{UserName: "root", RoleID: "Administrator", Enabled: true},
+		{UserName: "former", RoleID: "Administrator", Enabled: false},
+		{UserName: "operator", RoleID: "Operator", Enabled: true},
+	} {
+		if err := s.store.add(a.UserName, "password", a.RoleID, a.Enabled); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestCheckAccount(t *testing.T) {
+	s := newAdminService(t)
+
+	tests := []struct {
+		name     string
+		username string
+		body     string
+		create   bool
+		status   int
+		messages []string
+	}{
+		{"nothing", "operator", `{}`, false, 0, nil},
+		{"everything", "operator", `{"Password": "12345678", "RoleId": "ReadOnly", "Enabled": false, "Locked": false}`, false, 0, nil},
+		{"short password", "operator", `{"Password": "1234567"}`, false, http.StatusBadRequest, []string{"PropertyValueFormatError"}},
+		{"password type", "operator", `{"Password": 12345678}`, false, http.StatusBadRequest, []string{"PropertyValueTypeError"}},
+		{"unknown role", "operator", `{"RoleId": "Nope"}`, false, http.StatusBadRequest, []string{"PropertyValueNotInList"}},
+		{"role path", "operator", `{"RoleId": "../Roles/Administrator"}`, false, http.StatusBadRequest, []string{"PropertyValueNotInList"}},
+		{"role type", "operator", `{"RoleId": 1}`, false, http.StatusBadRequest, []string{"PropertyValueTypeError"}},
+		{"enabled type", "operator", `{"Enabled": "yes"}`, false, http.StatusBadRequest, []string{"PropertyValueTypeError"}},
+		{"lock", "operator", `{"Locked": true}`, false, http.StatusBadRequest, []string{"PropertyValueNotInList"}},
+		{"locked type", "operator", `{"Locked": "no"}`, false, http.StatusBadRequest, []string{"PropertyValueTypeError"}},
+		{"all wrong", "operator", `{"Password": "x", "RoleId": "Nope", "Enabled": 1, "Locked": true}`, false, http.StatusBadRequest,
+			[]string{"PropertyValueFormatError", "PropertyValueNotInList", "PropertyValueTypeError", "PropertyValueNotInList"}},
+		{"demote the last admin", "root", `{"RoleId": "Operator"}`, false, http.StatusConflict, []string{"ResourceInUse"}},
+		{"disable the last admin", "root", `{"Enabled": false}`, false, http.StatusConflict, []string{"ResourceInUse"}},
+		{"last admin stays admin", "root", `{"RoleId": "Administrator", "Enabled": true, "Password": "12345678"}`, false, 0, nil},
+		{"bad values come first", "root", `{"RoleId": "Nope", "Enabled": false}`, false, http.StatusBadRequest, []string{"PropertyValueNotInList"}},
+		{"new account", "root", `{"RoleId": "Operator", "Enabled": false}`, true, 0, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			rerr := s.checkAccount(tc.username, body, tc.create)
+			if tc.status == 0 {
+				if rerr != nil {
+					t.Fatalf("refused: %s", rerr)
+				}
+				return
+			}
+			if rerr == nil {
+				t.Fatalf("accepted")
+			}
+			messages := []string{}
+			for _, ei := range rerr.ExtendedInfo {
+				messages = append(messages, ei.MessageID)
+			}
+			if rerr.StatusCode != tc.status || strings.Join(messages, ",") != strings.Join(tc.messages, ",") {
+				t.Errorf("status %d, messages %v, expected %d %v", rerr.StatusCode, messages, tc.status, tc.messages)
+			}
+		})
+	}
+}
+
+func TestRemovesLastAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		extra    *account
+		username string
+		body     map[string]interface{}
+		removes  bool
+	}{
+		{"delete the admin", nil, "root", nil, true},
+		{"delete the disabled admin", nil, "former", nil, false},
+		{"delete another account", nil, "operator", nil, false},
+		{"delete an unknown account", nil, "nobody", nil, false},
+		{"demote the admin", nil, "root", map[string]interface{}{"RoleId": "ReadOnly"}, true},
+		{"give the admin an unknown role", nil, "root", map[string]interface{}{"RoleId": "Nope"}, true},
+		{"disable the admin", nil, "root", map[string]interface{}{"Enabled": false}, true},
+		{"change the password", nil, "root", map[string]interface{}{"Password": "12345678"}, false},
+		{"promote another", nil, "operator", map[string]interface{}{"RoleId": "Administrator"}, false},
+		{"enable the disabled admin", nil, "former", map[string]interface{}{"Enabled": true}, false},
+		{"delete one of two admins", &account{UserName: "second", RoleID: "Administrator", Enabled: true}, "root", nil, false},
+		{"second admin is disabled", &account{UserName: "second", RoleID: "Administrator", Enabled: false}, "root", nil, true},
+		{"second admin's role is gone", &account{UserName: "second", RoleID: "Deleted", Enabled: true}, "root", nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newAdminService(t)
+			if tc.extra != nil {
+				s.store.add(tc.extra.UserName, "password", tc.extra.RoleID, tc.extra.Enabled)
+			}
+			if removes := s.removesLastAdmin(tc.username, tc.body); removes != tc.removes {
+				t.Errorf("removesLastAdmin is %v", removes)
+			}
+		})
+	}
+}
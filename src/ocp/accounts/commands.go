@@ -0,0 +1,157 @@
+package accounts
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+const (
+	AccountServicePATCHCommand = eh.CommandType(accountServicePlugin + ":PATCH")
+
+	PATCHCommand  = eh.CommandType(accountPlugin + ":PATCH")
+	DELETECommand = eh.CommandType(accountPlugin + ":DELETE")
+
+	RolePATCHCommand  = eh.CommandType(rolePlugin + ":PATCH")
+	RoleDELETECommand = eh.CommandType(rolePlugin + ":DELETE")
+)
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&AccountServicePATCH{})
+var _ = eh.Command(&PATCH{})
+var _ = eh.Command(&DELETE{})
+var _ = eh.Command(&RolePATCH{})
+var _ = eh.Command(&RoleDELETE{})
+
+// AccountServicePATCH checks and saves the changes to the LDAP and
+// ActiveDirectory settings before the standard PATCH fills in the response.
+type AccountServicePATCH struct {
+	domain.PATCH
+	service *Service
+}
+
+func (c *AccountServicePATCH) CommandType() eh.CommandType { return AccountServicePATCHCommand }
+func (c *AccountServicePATCH) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	for _, name := range providerNames {
+		body, ok := c.Body[name]
+		if !ok {
+			continue
+		}
+		if rerr := c.service.checkProvider(name, body); rerr != nil {
+			a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, rerr), time.Now()))
+			return nil
+		}
+	}
+	for _, name := range providerNames {
+		body, ok := c.Body[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := c.service.updateProvider(name, body); err != nil {
+			domain.ContextLogger(ctx, "accounts").Crit("Could not save directory settings", "provider", name, "err", err)
+			a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusInternalServerError, "InternalError")), time.Now()))
+			return nil
+		}
+	}
+	return c.PATCH.Handle(ctx, a)
+}
+
+// PATCH checks and saves the changes to an account before the standard
+// PATCH fills in the response. Users that can only configure themselves can
+// change their password, but not their role, whether they are enabled or
+// the lockout.
+type PATCH struct {
+	domain.PATCH
+	service    *Service
+	privileges []string
+}
+
+func (c *PATCH) CommandType() eh.CommandType { return PATCHCommand }
+func (c *PATCH) SetUserDetails(u string, privileges []string) string {
+	c.privileges = privileges
+	return "checkMaster"
+}
+func (c *PATCH) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	_, role := c.Body["RoleId"]
+	_, enabled := c.Body["Enabled"]
+	_, locked := c.Body["Locked"]
+	if (role || enabled || locked) && !hasPrivilege(c.privileges, "ConfigureUsers") {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusForbidden, "InsufficientPrivilege")), time.Now()))
+		return nil
+	}
+	if rerr := c.service.checkAccount(path.Base(a.ResourceURI), c.Body, false); rerr != nil {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, rerr), time.Now()))
+		return nil
+	}
+	if err := c.service.updateAccount(path.Base(a.ResourceURI), c.Body); err != nil {
+		domain.ContextLogger(ctx, "accounts").Crit("Could not save account", "UserName", path.Base(a.ResourceURI), "err", err)
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusInternalServerError, "InternalError")), time.Now()))
+		return nil
+	}
+	if locked {
+		c.service.unlock(path.Base(a.ResourceURI))
+	}
+	return c.PATCH.Handle(ctx, a)
+}
+
+// DELETE removes the account from the store along with the resource. The
+// last account that can manage accounts can't be deleted.
+type DELETE struct {
+	domain.DELETE
+	service *Service
+}
+
+func (c *DELETE) CommandType() eh.CommandType { return DELETECommand }
+func (c *DELETE) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	username := path.Base(a.ResourceURI)
+	if c.service.removesLastAdmin(username, nil) {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusConflict, "ResourceInUse")), time.Now()))
+		return nil
+	}
+	if err := c.service.store.remove(username); err != nil {
+		domain.ContextLogger(ctx, "accounts").Crit("Could not save accounts", "UserName", username, "err", err)
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusInternalServerError, "InternalError")), time.Now()))
+		return nil
+	}
+	c.service.unlock(username)
+	return c.DELETE.Handle(ctx, a)
+}
+
+// RolePATCH checks the privileges of a custom role before the standard PATCH
+// applies them. The role that the last admin has can't lose ConfigureUsers.
+type RolePATCH struct {
+	domain.PATCH
+	service *Service
+}
+
+func (c *RolePATCH) CommandType() eh.CommandType { return RolePATCHCommand }
+func (c *RolePATCH) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	if rerr := checkRole(c.Body); rerr != nil {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, rerr), time.Now()))
+		return nil
+	}
+	if c.service.roleRemovesLastAdmin(path.Base(a.ResourceURI), c.Body) {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusConflict, "ResourceInUse")), time.Now()))
+		return nil
+	}
+	return c.PATCH.Handle(ctx, a)
+}
+
+// RoleDELETE removes a custom role, as long as no account has it
+type RoleDELETE struct {
+	domain.DELETE
+	service *Service
+}
+
+func (c *RoleDELETE) CommandType() eh.CommandType { return RoleDELETECommand }
+func (c *RoleDELETE) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	if c.service.roleInUse(path.Base(a.ResourceURI)) {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.NewRedfishError(http.StatusConflict, "ResourceInUse")), time.Now()))
+		return nil
+	}
+	return c.DELETE.Handle(ctx, a)
+}
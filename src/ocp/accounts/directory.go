@@ -0,0 +1,398 @@
+package accounts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	plugins "github.com/superchalupa/go-redfish/src/ocp"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+// the external account providers, by the AccountService property they are in
+const (
+	ldapProvider = "LDAP"
+	adProvider   = "ActiveDirectory"
+)
+
+var providerNames = []string{ldapProvider, adProvider}
+
+// how long each request to a directory server can take
+const directoryTimeout = 5 * time.Second
+
+// roleMapping gives the users in a directory group a local Role
+type roleMapping struct {
+	RemoteGroup string `json:"remote_group"`
+	LocalRole   string `json:"local_role"`
+}
+
+// provider is the config of a directory that users can log in with when they
+// don't have a local account. The bind password is kept so that we can look
+// users up, which is why the file is only readable by us.
+type provider struct {
+	Enabled         bool          `json:"enabled"`
+	Addresses       []string      `json:"addresses"`
+	BindDN          string        `json:"bind_dn"`
+	BindPassword    string        `json:"bind_password"`
+	BaseDNs         []string      `json:"base_dns"`
+	UserAttribute   string        `json:"username_attribute"`
+	GroupsAttribute string        `json:"groups_attribute"`
+	RoleMapping     []roleMapping `json:"remote_role_mapping"`
+}
+
+func defaultProvider(name string) *provider {
+	p := &provider{Addresses: []string{}, BaseDNs: []string{}, RoleMapping: []roleMapping{}}
+	switch name {
+	case adProvider:
+		p.UserAttribute = "sAMAccountName"
+		p.GroupsAttribute = "memberOf"
+	default:
+		p.UserAttribute = "uid"
+		p.GroupsAttribute = "memberOf"
+	}
+	return p
+}
+
+// directories are the LDAP and ActiveDirectory providers, saved to their own
+// file every time they change, and what we know about the users that logged
+// in with them.
+type directories struct {
+	sync.RWMutex
+	filename  string
+	providers map[string]*provider
+
+	caFile    string
+	cacheTime time.Duration
+	// RemoteGroup can be just the CN of a group, see groupMatches
+	groupCN bool
+	cache   loginCache
+	// the groups users had when they last logged in, for Privileges
+	remote map[string]remoteLogin
+}
+
+func loadDirectories(filename string) (*directories, error) {
+	d := &directories{
+		filename:  filename,
+		providers: map[string]*provider{},
+		remote:    map[string]remoteLogin{},
+	}
+	for _, name := range providerNames {
+		d.providers[name] = defaultProvider(name)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &d.providers); err != nil {
+		return nil, err
+	}
+	for _, name := range providerNames {
+		if d.providers[name] == nil {
+			d.providers[name] = defaultProvider(name)
+		}
+	}
+	return d, nil
+}
+
+// save writes the providers out, already locked when we get here. Written
+// the same way as the accounts.
+func (d *directories) save() error {
+	if d.filename == "" {
+		return nil
+	}
+	return plugins.WriteFileAtomic(d.filename, d.providers, 0600)
+}
+
+func (d *directories) get(name string) provider {
+	d.RLock()
+	defer d.RUnlock()
+	p := *d.providers[name]
+	return p
+}
+
+// update changes a provider and saves the result. Logins that were cached
+// with the old settings have to be checked again.
+func (d *directories) update(name string, fn func(*provider)) error {
+	d.Lock()
+	defer d.Unlock()
+	updated := *d.providers[name]
+	fn(&updated)
+	d.providers[name] = &updated
+	d.cache.clear()
+	return d.save()
+}
+
+func stringList(list []string) []interface{} {
+	ret := []interface{}{}
+	for _, s := range list {
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// providerProperties is the provider as the AccountService shows it. The
+// bind password is never shown.
+func providerProperties(name string, p provider) map[string]interface{} {
+	providerType := "LDAPService"
+	if name == adProvider {
+		providerType = "ActiveDirectoryService"
+	}
+	mapping := []interface{}{}
+	for _, m := range p.RoleMapping {
+		mapping = append(mapping, map[string]interface{}{"RemoteGroup": m.RemoteGroup, "LocalRole": m.LocalRole})
+	}
+	return map[string]interface{}{
+		"AccountProviderType": providerType,
+		"ServiceEnabled":      p.Enabled,
+		"ServiceAddresses":    stringList(p.Addresses),
+		"Authentication": map[string]interface{}{
+			"AuthenticationType": "UsernameAndPassword",
+			"Username":           p.BindDN,
+			"Password":           nil,
+		},
+		"LDAPService": map[string]interface{}{
+			"SearchSettings": map[string]interface{}{
+				"BaseDistinguishedNames": stringList(p.BaseDNs),
+				"UsernameAttribute":      p.UserAttribute,
+				"GroupsAttribute":        p.GroupsAttribute,
+			},
+		},
+		"RemoteRoleMapping": mapping,
+	}
+}
+
+func (s *Service) directoryMeta(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"GET":   map[string]interface{}{"plugin": string(AccountsPlugin), "directory": name},
+		"PATCH": map[string]interface{}{"plugin": string(AccountsPlugin), "directory": name},
+	}
+}
+
+// providerChecker collects the problems with a provider in a PATCH body
+type providerChecker struct {
+	rerr *domain.RedfishError
+	path string
+}
+
+func (c *providerChecker) add(id, property string, args ...interface{}) {
+	c.rerr.AddExtendedInfo(domain.NewExtendedInfo(id, args...).WithRelatedProperties(c.path + "/" + property))
+}
+
+// object returns the body as an object, or nil if it isn't one
+func (c *providerChecker) object(v interface{}, property string) map[string]interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		c.add("PropertyValueTypeError", property, v, property)
+	}
+	return m
+}
+
+// strings returns the body as a list of strings, or false if it isn't one
+func (c *providerChecker) strings(v interface{}, property string) ([]string, bool) {
+	list, ok := v.([]interface{})
+	ret := []string{}
+	for _, item := range list {
+		s, isString := item.(string)
+		ok = ok && isString
+		ret = append(ret, s)
+	}
+	if !ok {
+		c.add("PropertyValueTypeError", property, v, property)
+	}
+	return ret, ok
+}
+
+func (c *providerChecker) nonEmpty(v interface{}, property, name string) {
+	if s, ok := v.(string); !ok {
+		c.add("PropertyValueTypeError", property, v, name)
+	} else if s == "" {
+		c.add("PropertyValueFormatError", property, s, name)
+	}
+}
+
+// checkAddress allows ldap:// and ldaps:// URLs with a host and nothing else
+func checkAddress(address string) bool {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") || u.User != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "ldap" || scheme == "ldaps"
+}
+
+// checkProvider checks the LDAP or ActiveDirectory object in a PATCH body,
+// and that the provider would still have what it needs to be enabled.
+func (s *Service) checkProvider(name string, body interface{}) *domain.RedfishError {
+	c := &providerChecker{rerr: &domain.RedfishError{StatusCode: http.StatusBadRequest}, path: "#/" + name}
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		c.rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", body, name).WithRelatedProperties("#/" + name))
+		return c.rerr
+	}
+
+	p := s.directories.get(name)
+	for k, v := range m {
+		switch k {
+		case "ServiceEnabled":
+			if e, ok := v.(bool); !ok {
+				c.add("PropertyValueTypeError", k, v, k)
+			} else {
+				p.Enabled = e
+			}
+		case "ServiceAddresses":
+			if addresses, ok := c.strings(v, k); ok {
+				for _, a := range addresses {
+					if !checkAddress(a) {
+						c.add("PropertyValueFormatError", k, a, k)
+					}
+				}
+				p.Addresses = addresses
+			}
+		case "Authentication":
+			for k, v := range c.object(v, k) {
+				switch k {
+				case "AuthenticationType":
+					if v != "UsernameAndPassword" {
+						c.add("PropertyValueNotInList", "Authentication/"+k, v, k)
+					}
+				case "Username", "Password":
+					if _, ok := v.(string); !ok {
+						c.add("PropertyValueTypeError", "Authentication/"+k, v, k)
+					}
+				default:
+					c.add("PropertyUnknown", "Authentication/"+k, k)
+				}
+			}
+		case "LDAPService":
+			for k, v := range c.object(v, k) {
+				if k != "SearchSettings" {
+					c.add("PropertyUnknown", "LDAPService/"+k, k)
+					continue
+				}
+				for k, v := range c.object(v, "LDAPService/SearchSettings") {
+					property := "LDAPService/SearchSettings/" + k
+					switch k {
+					case "BaseDistinguishedNames":
+						if dns, ok := c.strings(v, property); ok {
+							p.BaseDNs = dns
+						}
+					case "UsernameAttribute", "GroupsAttribute":
+						c.nonEmpty(v, property, k)
+					default:
+						c.add("PropertyUnknown", property, k)
+					}
+				}
+			}
+		case "RemoteRoleMapping":
+			list, ok := v.([]interface{})
+			if !ok {
+				c.add("PropertyValueTypeError", k, v, k)
+				continue
+			}
+			for i, item := range list {
+				path := fmt.Sprintf("RemoteRoleMapping/%d", i)
+				m := c.object(item, path)
+				for _, k := range []string{"RemoteGroup", "LocalRole"} {
+					if _, ok := m[k]; !ok && m != nil {
+						c.add("PropertyMissing", path+"/"+k, k)
+					}
+				}
+				for k, v := range m {
+					property := path + "/" + k
+					switch k {
+					case "RemoteGroup":
+						c.nonEmpty(v, property, k)
+					case "LocalRole":
+						if r, ok := v.(string); !ok {
+							c.add("PropertyValueTypeError", property, v, k)
+						} else if _, ok := s.rolePrivileges(r); !ok {
+							c.add("PropertyValueNotInList", property, r, k)
+						}
+					default:
+						c.add("PropertyUnknown", property, k)
+					}
+				}
+			}
+		case "AccountProviderType":
+			c.add("PropertyNotWritable", k, k)
+		default:
+			c.add("PropertyUnknown", k, k)
+		}
+	}
+	if len(c.rerr.ExtendedInfo) > 0 {
+		return c.rerr
+	}
+
+	if p.Enabled && len(p.Addresses) == 0 {
+		c.add("PropertyMissing", "ServiceAddresses", "ServiceAddresses")
+	}
+	if p.Enabled && len(p.BaseDNs) == 0 {
+		c.add("PropertyMissing", "LDAPService/SearchSettings/BaseDistinguishedNames", "BaseDistinguishedNames")
+	}
+	if len(c.rerr.ExtendedInfo) > 0 {
+		return c.rerr
+	}
+	return nil
+}
+
+// updateProvider saves the changes in a PATCH body to the provider, all at
+// once, the PATCH command has already checked them.
+func (s *Service) updateProvider(name string, body map[string]interface{}) error {
+	return s.directories.update(name, func(p *provider) {
+		if e, ok := body["ServiceEnabled"].(bool); ok {
+			p.Enabled = e
+		}
+		if _, ok := body["ServiceAddresses"]; ok {
+			p.Addresses = listOfStrings(body["ServiceAddresses"])
+		}
+		if auth, ok := body["Authentication"].(map[string]interface{}); ok {
+			if u, ok := auth["Username"].(string); ok {
+				p.BindDN = u
+			}
+			if pw, ok := auth["Password"].(string); ok {
+				p.BindPassword = pw
+			}
+		}
+		if ldapService, ok := body["LDAPService"].(map[string]interface{}); ok {
+			settings, _ := ldapService["SearchSettings"].(map[string]interface{})
+			if _, ok := settings["BaseDistinguishedNames"]; ok {
+				p.BaseDNs = listOfStrings(settings["BaseDistinguishedNames"])
+			}
+			if a, ok := settings["UsernameAttribute"].(string); ok {
+				p.UserAttribute = a
+			}
+			if a, ok := settings["GroupsAttribute"].(string); ok {
+				p.GroupsAttribute = a
+			}
+		}
+		if list, ok := body["RemoteRoleMapping"].([]interface{}); ok {
+			p.RoleMapping = []roleMapping{}
+			for _, item := range list {
+				m, _ := item.(map[string]interface{})
+				group, _ := m["RemoteGroup"].(string)
+				role, _ := m["LocalRole"].(string)
+				p.RoleMapping = append(p.RoleMapping, roleMapping{RemoteGroup: group, LocalRole: role})
+			}
+		}
+	})
+}
+
+func listOfStrings(v interface{}) []string {
+	list, _ := v.([]interface{})
+	ret := []string{}
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,111 @@
+package accounts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/superchalupa/go-redfish/src/log"
+)
+
+// failures are the failed logins of an account since the counter was last
+// reset, and since the last successful login for logging
+type failures struct {
+	count       int
+	logCount    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// lockout tracks failed logins for basic auth and session login. Only
+// accounts that exist are counted for the lockout. User names without an
+// account are only counted for logging, and only so many of them, so that
+// logins with made up user names can't fill up memory.
+type lockout struct {
+	sync.Mutex
+	accounts map[string]*failures
+	unknown  map[string]int
+	// the failures of the unknown user names that didn't fit
+	unknownOverflow int
+}
+
+// how many user names without an account are counted separately
+const maxUnknownUsers = 1000
+
+func (s *Service) isLocked(username string) bool {
+	s.lockout.Lock()
+	defer s.lockout.Unlock()
+	f, ok := s.lockout.accounts[username]
+	return ok && time.Now().Before(f.lockedUntil)
+}
+
+// loginFailed counts a failed login, and locks the account when there have
+// been AccountLockoutThreshold of them without AccountLockoutCounterResetAfter
+// seconds between them. Every AuthFailureLoggingThreshold failures of a user
+// are logged.
+func (s *Service) loginFailed(username string, known bool) {
+	threshold := s.intSetting("lockout_threshold")
+	duration := time.Duration(s.intSetting("lockout_duration")) * time.Second
+	resetAfter := time.Duration(s.intSetting("lockout_reset_after")) * time.Second
+	logThreshold := s.intSetting("auth_failure_logging_threshold")
+	logger := log.MustLogger("accounts")
+	now := time.Now()
+
+	s.lockout.Lock()
+	defer s.lockout.Unlock()
+
+	if !known {
+		if n := s.lockout.unknownFailed(username); logThreshold > 0 && n%logThreshold == 0 {
+			logger.Warn("Authentication failures", "UserName", username, "failures", n)
+		}
+		return
+	}
+	f, ok := s.lockout.accounts[username]
+	if !ok {
+		f = &failures{}
+		s.lockout.accounts[username] = f
+	}
+	f.logCount++
+	if logThreshold > 0 && f.logCount%logThreshold == 0 {
+		logger.Warn("Authentication failures", "UserName", username, "failures", f.logCount)
+	}
+
+	if now.Sub(f.last) > resetAfter {
+		f.count = 0
+	}
+	f.count++
+	f.last = now
+
+	// 0 for either of them means no lockout
+	if threshold > 0 && duration > 0 && f.count >= threshold {
+		f.count = 0
+		f.lockedUntil = now.Add(duration)
+		logger.Warn("Account locked after too many failed logins", "UserName", username, "until", f.lockedUntil)
+	}
+}
+
+// unknownFailed counts a failed login of a user name without an account, and
+// returns how many there have been. Already locked.
+func (l *lockout) unknownFailed(username string) int {
+	if l.unknown == nil {
+		l.unknown = map[string]int{}
+	}
+	if _, ok := l.unknown[username]; !ok && len(l.unknown) >= maxUnknownUsers {
+		l.unknownOverflow++
+		return l.unknownOverflow
+	}
+	l.unknown[username]++
+	return l.unknown[username]
+}
+
+// loginSucceeded resets the counters for the user
+func (s *Service) loginSucceeded(username string) {
+	s.unlock(username)
+}
+
+// unlock clears the failures for the user, and any lockout
+func (s *Service) unlock(username string) {
+	s.lockout.Lock()
+	defer s.lockout.Unlock()
+	delete(s.lockout.accounts, username)
+	delete(s.lockout.unknown, username)
+}
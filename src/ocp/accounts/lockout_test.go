@@ -0,0 +1,206 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/superchalupa/go-redfish/src/log"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+// failLogins makes n logins with the wrong password
+func failLogins(s *Service, username string, n int) {
+	for i := 0; i < n; i++ {
+		s.Authenticate(username, "wrong")
+	}
+}
+
+func TestLockoutThreshold(t *testing.T) {
+	s := newAdminService(t)
+	threshold := s.intSetting("lockout_threshold")
+
+	failLogins(s, "root", threshold-1)
+	if s.isLocked("root") {
+		t.Fatalf("locked before the threshold")
+	}
+	if _, ok := s.Authenticate("root", "password"); !ok {
+		t.Fatalf("can't log in before the threshold")
+	}
+
+	// logging in started the count again
+	failLogins(s, "root", threshold-1)
+	if s.isLocked("root") {
+		t.Fatalf("locked after a successful login reset the count")
+	}
+	failLogins(s, "root", 1)
+	if !s.isLocked("root") {
+		t.Fatalf("not locked after %d failures", threshold)
+	}
+	if _, ok := s.Authenticate("root", "password"); ok {
+		t.Errorf("logged in with the right password while locked")
+	}
+	if _, ok := s.Authenticate("operator", "password"); !ok {
+		t.Errorf("another account was locked too")
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	s := newAdminService(t)
+	s.UpdateProperty("lockout_duration", 60)
+	failLogins(s, "root", s.intSetting("lockout_threshold"))
+
+	s.lockout.Lock()
+	until := s.lockout.accounts["root"].lockedUntil
+	s.lockout.Unlock()
+	if d := time.Until(until); d <= 59*time.Second || d > 60*time.Second {
+		t.Fatalf("locked for %v", d)
+	}
+
+	// the lockout runs out by itself
+	s.lockout.Lock()
+	s.lockout.accounts["root"].lockedUntil = time.Now().Add(-time.Second)
+	s.lockout.Unlock()
+	if s.isLocked("root") {
+		t.Errorf("still locked after the duration")
+	}
+	if _, ok := s.Authenticate("root", "password"); !ok {
+		t.Errorf("can't log in after the duration")
+	}
+}
+
+func TestLockoutCounterReset(t *testing.T) {
+	s := newAdminService(t)
+	threshold := s.intSetting("lockout_threshold")
+	resetAfter := time.Duration(s.intSetting("lockout_reset_after")) * time.Second
+
+	failLogins(s, "root", threshold-1)
+	// the last failure was longer ago than AccountLockoutCounterResetAfter
+	s.lockout.Lock()
+	s.lockout.accounts["root"].last = time.Now().Add(-resetAfter - time.Second)
+	s.lockout.Unlock()
+	failLogins(s, "root", 1)
+	if s.isLocked("root") {
+		t.Fatalf("failures from before the reset window were counted")
+	}
+	failLogins(s, "root", threshold-1)
+	if !s.isLocked("root") {
+		t.Errorf("not locked after %d failures inside the reset window", threshold)
+	}
+}
+
+func TestLockoutOff(t *testing.T) {
+	for _, setting := range []string{"lockout_threshold", "lockout_duration"} {
+		s := newAdminService(t)
+		s.UpdateProperty(setting, 0)
+		failLogins(s, "root", 20)
+		if s.isLocked("root") {
+			t.Errorf("%s 0: locked", setting)
+		}
+	}
+}
+
+func TestLockoutUnknownUsers(t *testing.T) {
+	s := newAdminService(t)
+	failLogins(s, "nobody", 20)
+	if s.isLocked("nobody") || len(s.lockout.accounts) != 0 {
+		t.Errorf("an account that doesn't exist was counted for the lockout")
+	}
+}
+
+func TestPATCHClearsLocked(t *testing.T) {
+	tests := []struct {
+		name       string
+		privileges []string
+		locked     bool
+	}{
+		{"admin", []string{"Login", "ConfigureUsers"}, false},
+		{"self", []string{"Login", "ConfigureSelf"}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newAdminService(t)
+			failLogins(s, "operator", s.intSetting("lockout_threshold"))
+			if !s.isLocked("operator") {
+				t.Fatalf("not locked")
+			}
+
+			a := &domain.RedfishResourceAggregate{ResourceURI: AccountsURI + "/operator"}
+			c := &PATCH{service: s}
+			c.Body = map[string]interface{}{"Locked": false}
+			c.SetUserDetails("tester", tc.privileges)
+			c.Handle(context.Background(), a)
+
+			if s.isLocked("operator") != tc.locked {
+				t.Errorf("locked is %v", s.isLocked("operator"))
+			}
+			events := a.EventsToPublish()
+			if len(events) != 1 {
+				t.Fatalf("%d events", len(events))
+			}
+			data, _ := events[0].Data().(domain.HTTPCmdProcessedData)
+			if forbidden := data.StatusCode == http.StatusForbidden; forbidden != tc.locked {
+				t.Errorf("status %d", data.StatusCode)
+			}
+		})
+	}
+}
+
+func TestFailureLoggingPerUser(t *testing.T) {
+	logger := newTestLogger()
+	log.GlobalLogger = logger
+	s, _ := New()
+	threshold := s.intSetting("auth_failure_logging_threshold")
+	if threshold < 2 {
+		t.Fatalf("default logging threshold is %d", threshold)
+	}
+
+	// interleaved, nobody reaches the threshold even though the total does
+	for i := 0; i < threshold-1; i++ {
+		s.loginFailed("alice", true)
+		s.loginFailed("bob", true)
+		s.loginFailed("mallory", false)
+	}
+	if out := logger.String(); strings.Contains(out, "Authentication failures") {
+		t.Fatalf("logged before any user reached the threshold:\n%s", out)
+	}
+
+	s.loginFailed("alice", true)
+	s.loginFailed("mallory", false)
+	out := logger.String()
+	for _, user := range []string{"alice", "mallory"} {
+		if !strings.Contains(out, fmt.Sprintf("UserName %s failures %d", user, threshold)) {
+			t.Errorf("%s reaching the threshold wasn't logged:\n%s", user, out)
+		}
+	}
+	if strings.Contains(out, "UserName bob") {
+		t.Errorf("bob was logged below the threshold:\n%s", out)
+	}
+
+	// a successful login starts the count again
+	s.loginSucceeded("bob")
+	s.loginFailed("bob", true)
+	if strings.Contains(logger.String(), "UserName bob") {
+		t.Errorf("bob was logged after logging in successfully:\n%s", logger)
+	}
+}
+
+func TestFailureLoggingUnknownUsersBounded(t *testing.T) {
+	log.GlobalLogger = newTestLogger()
+	s, _ := New()
+	for i := 0; i < maxUnknownUsers+10; i++ {
+		s.loginFailed(fmt.Sprintf("user%d", i), false)
+	}
+	if n := len(s.lockout.unknown); n != maxUnknownUsers {
+		t.Errorf("%d unknown users counted separately", n)
+	}
+	if s.lockout.unknownOverflow != 10 {
+		t.Errorf("%d failures in the shared count, expected 10", s.lockout.unknownOverflow)
+	}
+	if len(s.lockout.accounts) != 0 {
+		t.Errorf("unknown users were counted for the lockout")
+	}
+}
@@ -0,0 +1,298 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/superchalupa/go-redfish/src/ldap"
+	"github.com/superchalupa/go-redfish/src/log"
+)
+
+// remoteLogin is who a directory says a user is
+type remoteLogin struct {
+	provider string
+	groups   []string
+}
+
+// loginCache remembers logins for a little while, so that every basic auth
+// request doesn't make a round trip to the directory, or run bcrypt for a
+// local account. The passwords aren't kept, only a keyed hash of them.
+type loginCache struct {
+	sync.Mutex
+	key     []byte
+	entries map[string]cachedLogin
+}
+
+type cachedLogin struct {
+	remoteLogin
+	username string
+	expires  time.Time
+}
+
+func (c *loginCache) hash(username, password string) string {
+	c.Lock()
+	defer c.Unlock()
+	if c.key == nil {
+		c.key = make([]byte, 32)
+		rand.Read(c.key)
+	}
+	mac := hmac.New(sha256.New, c.key)
+	fmt.Fprintf(mac, "%d:%s:%s", len(username), username, password)
+	return string(mac.Sum(nil))
+}
+
+func (c *loginCache) get(username, password string) (remoteLogin, bool) {
+	h := c.hash(username, password)
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[h]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, h)
+		return remoteLogin{}, false
+	}
+	return entry.remoteLogin, true
+}
+
+func (c *loginCache) put(username, password string, login remoteLogin, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	h := c.hash(username, password)
+	c.Lock()
+	defer c.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]cachedLogin{}
+	}
+	// drop the old ones while we're here so the cache can't grow forever
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[h] = cachedLogin{remoteLogin: login, username: username, expires: now.Add(d)}
+}
+
+// drop forgets the logins of a user, whatever password they were made with
+func (c *loginCache) drop(username string) {
+	c.Lock()
+	defer c.Unlock()
+	for k, entry := range c.entries {
+		if entry.username == username {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *loginCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = nil
+}
+
+func (d *directories) tlsConfig() (*tls.Config, error) {
+	if d.caFile == "" {
+		// the system roots
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(d.caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", d.caFile)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+var errNoSuchUser = errors.New("no such user")
+
+// login checks the user against the enabled providers, LDAP first, and
+// returns the groups they are in. Only a wrong password or an unknown user
+// moves on to the next provider. When none of the directories have the user
+// any more, what we remember about them goes too: their cached logins and
+// the groups that their sessions get privileges from. A wrong password
+// doesn't do that, or anybody could log a directory user out.
+func (d *directories) login(username, password string) (remoteLogin, bool) {
+	if username == "" || password == "" {
+		return remoteLogin{}, false
+	}
+	if login, ok := d.cache.get(username, password); ok {
+		return login, true
+	}
+
+	logger := log.MustLogger("accounts")
+	unknown := true
+	for _, name := range providerNames {
+		p := d.get(name)
+		if !p.Enabled {
+			continue
+		}
+		groups, err := d.search(p, username, password)
+		if err != nil {
+			logger.Info("Directory login failed", "provider", name, "UserName", username, "err", err)
+			unknown = unknown && err == errNoSuchUser
+			continue
+		}
+		login := remoteLogin{provider: name, groups: groups}
+		d.cache.put(username, password, login, d.cacheTime)
+
+		d.Lock()
+		d.remote[username] = login
+		d.Unlock()
+		return login, true
+	}
+	if unknown {
+		d.forget(username)
+	}
+	return remoteLogin{}, false
+}
+
+// forget drops the cached logins of a user and the groups they had
+func (d *directories) forget(username string) {
+	d.cache.drop(username)
+	d.Lock()
+	defer d.Unlock()
+	delete(d.remote, username)
+}
+
+// search finds the user with the bind account, and checks their password by
+// binding as them. The first address that we can connect to is used.
+func (d *directories) search(p provider, username, password string) ([]string, error) {
+	config, err := d.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	var conn *ldap.Conn
+	for _, address := range p.Addresses {
+		conn, err = ldap.Dial(address, config, directoryTimeout)
+		if err == nil {
+			break
+		}
+	}
+	if conn == nil {
+		if err == nil {
+			err = fmt.Errorf("no service addresses")
+		}
+		return nil, err
+	}
+	defer conn.Close()
+
+	// without a bind account, the search is anonymous
+	if p.BindDN != "" {
+		if err := conn.Bind(p.BindDN, p.BindPassword); err != nil {
+			return nil, fmt.Errorf("bind account: %v", err)
+		}
+	}
+
+	filter := ldap.And(ldap.Equal(p.UserAttribute, username), ldap.Present("objectClass"))
+	var entry *ldap.Entry
+	for _, base := range p.BaseDNs {
+		// two is enough to know the user name isn't unique
+		entries, err := conn.Search(base, filter, []string{p.GroupsAttribute}, 2)
+		if err != nil && !isSizeLimit(err) {
+			return nil, err
+		}
+		for i := range entries {
+			if entry != nil {
+				return nil, fmt.Errorf("more than one entry for the user")
+			}
+			entry = &entries[i]
+		}
+	}
+	if entry == nil {
+		return nil, errNoSuchUser
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+	return entry.Values(p.GroupsAttribute), nil
+}
+
+func isSizeLimit(err error) bool {
+	e, ok := err.(*ldap.Error)
+	return ok && e.ResultCode == ldap.ResultSizeLimitExceeded
+}
+
+// normalizeDN takes out the spaces around the separators of a DN, so that
+// "cn=a, ou=b" and "cn=a,ou=b" compare the same
+func normalizeDN(dn string) string {
+	rdns := strings.Split(dn, ",")
+	for i, rdn := range rdns {
+		parts := strings.SplitN(rdn, "=", 2)
+		for j := range parts {
+			parts[j] = strings.TrimSpace(parts[j])
+		}
+		rdns[i] = strings.Join(parts, "=")
+	}
+	return strings.Join(rdns, ",")
+}
+
+// groupMatches compares a RemoteGroup with a group of the user, which is
+// usually a DN (memberOf). The whole DN has to match, unless byCN is set:
+// then just the name in its first RDN (ie. the CN) matches too. That is
+// easier to set up, but groups with the same CN in different OUs are
+// mixed up.
+func groupMatches(remoteGroup, group string, byCN bool) bool {
+	if strings.EqualFold(normalizeDN(remoteGroup), normalizeDN(group)) {
+		return true
+	}
+	if !byCN {
+		return false
+	}
+	rdn := strings.SplitN(group, ",", 2)[0]
+	if i := strings.Index(rdn, "="); i >= 0 {
+		return strings.EqualFold(remoteGroup, strings.TrimSpace(rdn[i+1:]))
+	}
+	return false
+}
+
+// remotePrivileges returns the privileges of the Roles that the groups of a
+// directory user are mapped to, with the mapping as it is now. ok is false if
+// the provider has been turned off or none of the groups are mapped.
+func (s *Service) remotePrivileges(username string, login remoteLogin) ([]string, bool) {
+	p := s.directories.get(login.provider)
+	if !p.Enabled {
+		return nil, false
+	}
+	privileges := []string{}
+	for _, m := range p.RoleMapping {
+		for _, group := range login.groups {
+			if !groupMatches(m.RemoteGroup, group, s.directories.groupCN) {
+				continue
+			}
+			if rolePrivileges, ok := s.rolePrivileges(m.LocalRole); ok {
+				privileges = append(privileges, rolePrivileges...)
+			}
+			break
+		}
+	}
+	if len(privileges) == 0 {
+		return nil, false
+	}
+	// so that they can log themselves out, they have no account to configure
+	return append([]string{"ConfigureSelf_" + username}, privileges...), true
+}
+
+// remoteUser is the last directory login of a user, if they have no local
+// account
+func (s *Service) remoteUser(username string) (remoteLogin, bool) {
+	if s.directories == nil {
+		return remoteLogin{}, false
+	}
+	s.directories.RLock()
+	defer s.directories.RUnlock()
+	login, ok := s.directories.remote[username]
+	return login, ok
+}
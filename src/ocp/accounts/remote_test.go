@@ -0,0 +1,518 @@
+package accounts
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/superchalupa/go-redfish/src/log"
+)
+
+// ber is one decoded TLV of a request to the fake directory
+type ber struct {
+	tag      byte
+	value    []byte
+	children []ber
+}
+
+func decodeBER(tag byte, value []byte) ber {
+	p := ber{tag: tag, value: value}
+	if tag&0x20 == 0 {
+		return p
+	}
+	for len(value) >= 2 {
+		length, header := int(value[1]), 2
+		if value[1]&0x80 != 0 {
+			n := int(value[1] & 0x7f)
+			length = 0
+			for _, b := range value[2 : 2+n] {
+				length = length<<8 | int(b)
+			}
+			header += n
+		}
+		p.children = append(p.children, decodeBER(value[0], value[header:header+length]))
+		value = value[header+length:]
+	}
+	return p
+}
+
+func readBER(r *bufio.Reader) (ber, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return ber{}, err
+	}
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		n := make([]byte, header[1]&0x7f)
+		if _, err := io.ReadFull(r, n); err != nil {
+			return ber{}, err
+		}
+		length = 0
+		for _, b := range n {
+			length = length<<8 | int(b)
+		}
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return ber{}, err
+	}
+	return decodeBER(header[0], value), nil
+}
+
+func encodeBER(tag byte, parts ...[]byte) []byte {
+	value := []byte{}
+	for _, p := range parts {
+		value = append(value, p...)
+	}
+	if len(value) < 0x80 {
+		return append([]byte{tag, byte(len(value))}, value...)
+	}
+	return append([]byte{tag, 0x82, byte(len(value) >> 8), byte(len(value))}, value...)
+}
+
+func berString(s string) []byte { return encodeBER(0x04, []byte(s)) }
+
+// ldapResult is an LDAPResult with the application tag of the response
+func ldapResult(tag byte, code int) []byte {
+	return encodeBER(tag, encodeBER(0x0a, []byte{byte(code)}), berString(""), berString(""))
+}
+
+const (
+	testBindDN       = "cn=bmc,dc=example,dc=com"
+	testBindPassword = "bindpassword"
+	testBaseDN       = "ou=people,dc=example,dc=com"
+)
+
+type directoryUser struct {
+	dn       string
+	password string
+	groups   []string
+}
+
+// fakeDirectory is an LDAP server with the users in it, found by their uid.
+// Only the bind account can search.
+type fakeDirectory struct {
+	net.Listener
+	users map[string][]directoryUser
+
+	sync.Mutex
+	binds    []string
+	searches int
+}
+
+func newFakeDirectory(t *testing.T) *fakeDirectory {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeDirectory{
+		Listener: ln,
+		users: map[string][]directoryUser{
+			"alice": {{"uid=alice," + testBaseDN, "alicepassword", []string{"cn=bmc-admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"}}},
+			"bob":   {{"uid=bob," + testBaseDN, "bobpassword", []string{"cn=staff,ou=groups,dc=example,dc=com"}}},
+			"twin": {
+				{"uid=twin,ou=a," + testBaseDN, "twinpassword", nil},
+				{"uid=twin,ou=b," + testBaseDN, "twinpassword", nil},
+			},
+		},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeDirectory) address() string { return "ldap://" + f.Addr().String() }
+
+func (f *fakeDirectory) counts() ([]string, int) {
+	f.Lock()
+	defer f.Unlock()
+	return append([]string{}, f.binds...), f.searches
+}
+
+// remove takes a user out of the directory
+func (f *fakeDirectory) remove(name string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.users, name)
+}
+
+func (f *fakeDirectory) bindOK(dn, password string) bool {
+	if dn == testBindDN {
+		return password == testBindPassword
+	}
+	f.Lock()
+	defer f.Unlock()
+	for _, users := range f.users {
+		for _, u := range users {
+			if u.dn == dn {
+				return password == u.password
+			}
+		}
+	}
+	return false
+}
+
+func (f *fakeDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	bound := ""
+	for {
+		msg, err := readBER(r)
+		if err != nil || len(msg.children) < 2 {
+			return
+		}
+		msgID := encodeBER(0x02, msg.children[0].value)
+		reply := func(ops ...[]byte) {
+			for _, op := range ops {
+				conn.Write(encodeBER(0x30, msgID, op))
+			}
+		}
+
+		switch op := msg.children[1]; op.tag {
+		case 0x60:
+			dn, password := string(op.children[1].value), string(op.children[2].value)
+			f.Lock()
+			f.binds = append(f.binds, dn)
+			f.Unlock()
+			if f.bindOK(dn, password) {
+				bound = dn
+				reply(ldapResult(0x61, 0))
+			} else {
+				reply(ldapResult(0x61, 49))
+			}
+
+		case 0x63:
+			f.Lock()
+			f.searches++
+			f.Unlock()
+			if bound != testBindDN {
+				// insufficientAccessRights
+				reply(ldapResult(0x65, 50))
+				continue
+			}
+			// And(Equal(uid, name), Present(objectClass))
+			equal := op.children[6].children[0]
+			name := string(equal.children[1].value)
+			ops := [][]byte{}
+			f.Lock()
+			users := f.users[name]
+			f.Unlock()
+			for _, u := range users {
+				groups := [][]byte{}
+				for _, g := range u.groups {
+					groups = append(groups, berString(g))
+				}
+				ops = append(ops, encodeBER(0x64, berString(u.dn),
+					encodeBER(0x30, encodeBER(0x30, berString("memberOf"), encodeBER(0x31, groups...)))))
+			}
+			reply(append(ops, ldapResult(0x65, 0))...)
+
+		case 0x42:
+			return
+		}
+	}
+}
+
+// newDirectoryService returns a Service with LDAP set up to use the fake
+// directory
+func newDirectoryService(t *testing.T, f *fakeDirectory, cacheTime time.Duration) *Service {
+	log.GlobalLogger = newTestLogger()
+	s, _ := New(WithTree(testRoles{
+		"Administrator": {"Login", "ConfigureManager", "ConfigureUsers"},
+		"ReadOnly":      {"Login"},
+	}))
+	s.store, _ = loadStore("")
+	s.directories, _ = loadDirectories("")
+	s.directories.cacheTime = cacheTime
+	s.directories.update(ldapProvider, func(p *provider) {
+		p.Enabled = true
+		// the first one isn't listening, the next one is used
+		p.Addresses = []string{"ldap://127.0.0.1:1", f.address()}
+		p.BindDN = testBindDN
+		p.BindPassword = testBindPassword
+		p.BaseDNs = []string{testBaseDN}
+		p.RoleMapping = []roleMapping{
+			{RemoteGroup: "bmc-admins", LocalRole: "Administrator"},
+			{RemoteGroup: "cn=staff,ou=groups,dc=example,dc=com", LocalRole: "ReadOnly"},
+			{RemoteGroup: "staff", LocalRole: "NoSuchRole"},
+		}
+	})
+	return s
+}
+
+func TestDirectoryLogin(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		bindPass string
+		ok       bool
+		binds    []string
+	}{
+		{"one entry", "alice", "alicepassword", testBindPassword, true,
+			[]string{testBindDN, "uid=alice," + testBaseDN}},
+		{"wrong password", "alice", "wrong", testBindPassword, false,
+			[]string{testBindDN, "uid=alice," + testBaseDN}},
+		{"empty password", "alice", "", testBindPassword, false, nil},
+		{"no entries", "nobody", "password", testBindPassword, false, []string{testBindDN}},
+		{"two entries", "twin", "twinpassword", testBindPassword, false, []string{testBindDN}},
+		{"bind account refused", "alice", "alicepassword", "wrong", false, []string{testBindDN}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newDirectoryService(t, f, 0)
+			s.directories.update(ldapProvider, func(p *provider) { p.BindPassword = tc.bindPass })
+			before, _ := f.counts()
+
+			login, ok := s.directories.login(tc.username, tc.password)
+			if ok != tc.ok {
+				t.Fatalf("login is %v, expected %v", ok, tc.ok)
+			}
+			if ok && (login.provider != ldapProvider || len(login.groups) != 2) {
+				t.Errorf("login is %+v", login)
+			}
+
+			binds, _ := f.counts()
+			binds = binds[len(before):]
+			if strings.Join(binds, ";") != strings.Join(tc.binds, ";") {
+				t.Errorf("bound as %q, expected %q", binds, tc.binds)
+			}
+		})
+	}
+}
+
+func TestDirectoryAuthenticate(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+	s := newDirectoryService(t, f, 0)
+
+	tests := []struct {
+		username   string
+		password   string
+		byCN       bool
+		privileges []string
+	}{
+		// staff by its DN, bmc-admins and NoSuchRole only by their CN
+		{"alice", "alicepassword", false, []string{"ConfigureSelf_alice", "Login"}},
+		{"bob", "bobpassword", false, []string{"ConfigureSelf_bob", "Login"}},
+		{"bob", "alicepassword", false, nil},
+		// NoSuchRole is skipped
+		{"alice", "alicepassword", true, []string{"ConfigureSelf_alice", "Login", "ConfigureManager", "ConfigureUsers", "Login"}},
+		{"bob", "bobpassword", true, []string{"ConfigureSelf_bob", "Login"}},
+	}
+	for _, tc := range tests {
+		s.directories.groupCN = tc.byCN
+		privileges, ok := s.Authenticate(tc.username, tc.password)
+		if ok != (tc.privileges != nil) {
+			t.Fatalf("%s: authenticated is %v", tc.username, ok)
+		}
+		if strings.Join(privileges, ",") != strings.Join(tc.privileges, ",") {
+			t.Errorf("%s (CN %v): privileges are %v, expected %v", tc.username, tc.byCN, privileges, tc.privileges)
+		}
+	}
+
+	// turning LDAP off takes the privileges away from an earlier login
+	login, _ := s.remoteUser("alice")
+	s.directories.update(ldapProvider, func(p *provider) { p.Enabled = false })
+	if _, ok := s.remotePrivileges("alice", login); ok {
+		t.Errorf("privileges from a provider that is turned off")
+	}
+}
+
+func TestDirectoryLoginCacheExpires(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+	s := newDirectoryService(t, f, 200*time.Millisecond)
+	searches := func() int {
+		_, n := f.counts()
+		return n
+	}
+
+	start := searches()
+	for i := 0; i < 3; i++ {
+		if _, ok := s.directories.login("alice", "alicepassword"); !ok {
+			t.Fatalf("login %d failed", i)
+		}
+	}
+	if n := searches() - start; n != 1 {
+		t.Fatalf("%d searches for cached logins, expected 1", n)
+	}
+	if _, ok := s.directories.login("alice", "wrong"); ok {
+		t.Fatal("wrong password accepted with a cached login")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	start = searches()
+	if _, ok := s.directories.login("alice", "alicepassword"); !ok {
+		t.Fatal("login after the cache expired failed")
+	}
+	if n := searches() - start; n != 1 {
+		t.Fatalf("%d searches after the cache expired, expected 1", n)
+	}
+}
+
+func TestGroupMatches(t *testing.T) {
+	tests := []struct {
+		remoteGroup string
+		group       string
+		match       bool
+		byCN        bool
+	}{
+		{"cn=bmc-admins,ou=groups,dc=example,dc=com", "CN=BMC-Admins,ou=groups,dc=example,dc=com", true, true},
+		{"cn=bmc-admins, ou=groups, dc=example, dc=com", "cn = bmc-admins,ou=groups,dc=example,dc=com", true, true},
+		{"bmc-admins", "bmc-admins", true, true},
+		{"cn=bmc-admins,ou=other,dc=example,dc=com", "cn=bmc-admins,ou=groups,dc=example,dc=com", false, false},
+		{"cn=bmc-admins", "cn=bmc-admins,ou=groups,dc=example,dc=com", false, false},
+		// only with CN matching turned on
+		{"bmc-admins", "cn=bmc-admins,ou=groups,dc=example,dc=com", false, true},
+		{"BMC-Admins", "CN=bmc-admins,OU=Groups,DC=example,DC=com", false, true},
+		{"bmc-admins", "cn = bmc-admins ,ou=groups", false, true},
+		{"bmc", "cn=bmc-admins,ou=groups,dc=example,dc=com", false, false},
+		{"groups", "cn=bmc-admins,ou=groups,dc=example,dc=com", false, false},
+		{"example", "cn=bmc-admins,ou=groups,dc=example,dc=com", false, false},
+		{"", "cn=bmc-admins", false, false},
+	}
+	for _, tc := range tests {
+		if match := groupMatches(tc.remoteGroup, tc.group, false); match != tc.match {
+			t.Errorf("groupMatches(%q, %q) is %v", tc.remoteGroup, tc.group, match)
+		}
+		if match := groupMatches(tc.remoteGroup, tc.group, true); match != tc.byCN {
+			t.Errorf("groupMatches(%q, %q) by CN is %v", tc.remoteGroup, tc.group, match)
+		}
+	}
+}
+
+func TestDirectoryFallback(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+	s := newDirectoryService(t, f, 0)
+	if err := s.store.add("alice", "localpassword", "ReadOnly", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// a local account is never looked up in the directory
+	before, _ := f.counts()
+	if _, ok := s.Authenticate("alice", "alicepassword"); ok {
+		t.Errorf("directory password accepted for a local account")
+	}
+	if privileges, ok := s.Authenticate("alice", "localpassword"); !ok || strings.Join(privileges, ",") != "ConfigureSelf_alice,Login" {
+		t.Errorf("local login: %v, %v", privileges, ok)
+	}
+	if binds, _ := f.counts(); len(binds) != len(before) {
+		t.Errorf("the directory was asked about a local account: %v", binds[len(before):])
+	}
+
+	// anybody else is
+	if _, ok := s.Authenticate("bob", "bobpassword"); !ok {
+		t.Errorf("directory login refused")
+	}
+	if _, ok := s.Privileges("bob"); !ok {
+		t.Errorf("no privileges for a directory user that logged in")
+	}
+	if _, ok := s.Privileges("nobody"); ok {
+		t.Errorf("privileges for a user that never logged in")
+	}
+}
+
+func TestDirectoryLoginCacheCleared(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+	s := newDirectoryService(t, f, time.Minute)
+	searches := func() int {
+		_, n := f.counts()
+		return n
+	}
+
+	s.directories.login("alice", "alicepassword")
+	start := searches()
+	s.directories.login("alice", "alicepassword")
+	if n := searches() - start; n != 0 {
+		t.Fatalf("%d searches for a cached login", n)
+	}
+
+	// the provider settings changed, the login is checked again
+	s.directories.update(ldapProvider, func(p *provider) { p.BindPassword = "wrong" })
+	if _, ok := s.directories.login("alice", "alicepassword"); ok {
+		t.Errorf("cached login used after the settings changed")
+	}
+}
+
+func TestDirectoryMappingChange(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+	s := newDirectoryService(t, f, 0)
+	if _, ok := s.Authenticate("alice", "alicepassword"); !ok {
+		t.Fatal("login failed")
+	}
+
+	// the sessions of a user that logged in get the mapping as it is now
+	tests := []struct {
+		mapping    []roleMapping
+		privileges []string
+	}{
+		{[]roleMapping{{RemoteGroup: "cn=bmc-admins,ou=groups,dc=example,dc=com", LocalRole: "Administrator"}},
+			[]string{"ConfigureSelf_alice", "Login", "ConfigureManager", "ConfigureUsers"}},
+		{[]roleMapping{{RemoteGroup: "cn=other,ou=groups,dc=example,dc=com", LocalRole: "Administrator"}}, nil},
+		{[]roleMapping{}, nil},
+	}
+	for _, tc := range tests {
+		s.directories.update(ldapProvider, func(p *provider) { p.RoleMapping = tc.mapping })
+		privileges, ok := s.Privileges("alice")
+		if ok != (tc.privileges != nil) || strings.Join(privileges, ",") != strings.Join(tc.privileges, ",") {
+			t.Errorf("mapping %v: privileges %v, %v", tc.mapping, privileges, ok)
+		}
+	}
+}
+
+func TestDirectoryUserRemoved(t *testing.T) {
+	f := newFakeDirectory(t)
+	defer f.Close()
+	s := newDirectoryService(t, f, time.Minute)
+	for _, user := range []string{"alice", "bob"} {
+		if _, ok := s.Authenticate(user, user+"password"); !ok {
+			t.Fatalf("%s: login failed", user)
+		}
+	}
+
+	// a wrong password doesn't take anything away
+	s.Authenticate("bob", "wrong")
+	if _, ok := s.Privileges("bob"); !ok {
+		t.Errorf("a wrong password took the privileges of the user away")
+	}
+	if _, ok := s.Authenticate("bob", "bobpassword"); !ok {
+		t.Errorf("a wrong password dropped the cached login")
+	}
+
+	// the directory doesn't have the user any more
+	f.remove("alice")
+	if _, ok := s.Authenticate("alice", "alicepassword"); !ok {
+		t.Errorf("cached login refused")
+	}
+	if _, ok := s.Authenticate("alice", "other"); ok {
+		t.Fatalf("login of a user that was removed")
+	}
+	if _, ok := s.remoteUser("alice"); ok {
+		t.Errorf("the groups of a user that was removed are kept")
+	}
+	if _, ok := s.Privileges("alice"); ok {
+		t.Errorf("privileges for a user that was removed")
+	}
+	if _, ok := s.Authenticate("alice", "alicepassword"); ok {
+		t.Errorf("the cached login of a user that was removed is kept")
+	}
+}
@@ -0,0 +1,170 @@
+package accounts
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+const (
+	roleType    = "#Role.v1_2_0.Role"
+	roleContext = "/redfish/v1/$metadata#Role.Role"
+
+	// the custom Role resources find their PATCH and DELETE commands with this
+	rolePlugin = "Role"
+)
+
+// standardPrivileges are the privileges that can be in AssignedPrivileges,
+// anything else goes in OemPrivileges
+var standardPrivileges = []string{"Login", "ConfigureManager", "ConfigureUsers", "ConfigureSelf", "ConfigureComponents"}
+
+// reservedPrivileges are added by the auth plugins for how a request was
+// authenticated, and ConfigureSelf_<user> is what lets a user at their own
+// account and sessions. A role can't hand any of them out.
+var reservedPrivileges = []string{"Unauthenticated", "basicauth", "bearerauth", "certauth", "tokenauth"}
+
+const selfPrivilegePrefix = "ConfigureSelf_"
+
+func isReservedPrivilege(privilege string) bool {
+	if len(privilege) >= len(selfPrivilegePrefix) && strings.EqualFold(privilege[:len(selfPrivilegePrefix)], selfPrivilegePrefix) {
+		return true
+	}
+	for _, p := range reservedPrivileges {
+		if strings.EqualFold(p, privilege) {
+			return true
+		}
+	}
+	return false
+}
+
+// everything goes through PATCH so that it is checked
+var customRolePrivileges = map[string]interface{}{
+	"GET":    []string{"Login"},
+	"POST":   []string{},
+	"PUT":    []string{},
+	"PATCH":  []string{"ConfigureUsers"},
+	"DELETE": []string{"ConfigureUsers"},
+}
+
+func (s *Service) roleMeta(property string) map[string]interface{} {
+	return map[string]interface{}{
+		"PATCH": map[string]interface{}{"plugin": string(AccountsPlugin), "role": property},
+	}
+}
+
+// roleProperty reads a list of privileges from the Role resource in the tree
+func (s *Service) roleProperty(roleID, property string) ([]string, bool) {
+	if s.tree == nil || roleID == "" || strings.Contains(roleID, "/") {
+		return nil, false
+	}
+	v, ok := s.tree.GetResourceProperty(context.Background(), RolesURI+"/"+roleID, property)
+	if !ok {
+		return nil, false
+	}
+	list, _ := v.([]interface{})
+	ret := []string{}
+	for _, p := range list {
+		if p, ok := p.(string); ok {
+			ret = append(ret, p)
+		}
+	}
+	return ret, true
+}
+
+// rolePrivileges returns the AssignedPrivileges and OemPrivileges of a role,
+// as the Role resource has them now. ok is false if there is no such role.
+func (s *Service) rolePrivileges(roleID string) ([]string, bool) {
+	privileges, ok := s.roleProperty(roleID, "AssignedPrivileges")
+	if !ok {
+		return nil, false
+	}
+	oem, _ := s.roleProperty(roleID, "OemPrivileges")
+	return append(privileges, oem...), true
+}
+
+// createRole checks a custom role from a POST to the Roles collection
+func (s *Service) createRole(ctx context.Context, create *domain.CreateRedfishResource, body map[string]interface{}) error {
+	if rerr := checkRole(body); rerr != nil {
+		return rerr
+	}
+	return nil
+}
+
+// checkRole checks the privileges in a POST or PATCH body for a custom role
+func checkRole(body map[string]interface{}) *domain.RedfishError {
+	rerr := &domain.RedfishError{StatusCode: http.StatusBadRequest}
+
+	for _, property := range []string{"AssignedPrivileges", "OemPrivileges"} {
+		v, ok := body[property]
+		if !ok {
+			continue
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", v, property).WithRelatedProperties("#/" + property))
+			continue
+		}
+		for _, p := range list {
+			privilege, ok := p.(string)
+			switch {
+			case !ok || privilege == "":
+				rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueTypeError", p, property).WithRelatedProperties("#/" + property))
+			case property == "AssignedPrivileges" && !hasPrivilege(standardPrivileges, privilege):
+				rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueNotInList", privilege, property).WithRelatedProperties("#/" + property))
+			case isReservedPrivilege(privilege):
+				rerr.AddExtendedInfo(domain.NewExtendedInfo("PropertyValueFormatError", privilege, property).WithRelatedProperties("#/" + property))
+			}
+		}
+	}
+	if len(rerr.ExtendedInfo) > 0 {
+		return rerr
+	}
+	return nil
+}
+
+// roleRemovesLastAdmin returns true if the change to the privileges of the
+// role would leave nobody that can manage the accounts.
+func (s *Service) roleRemovesLastAdmin(roleID string, body map[string]interface{}) bool {
+	changed := []string{}
+	for _, property := range []string{"AssignedPrivileges", "OemPrivileges"} {
+		if list, ok := body[property].([]interface{}); ok {
+			for _, p := range list {
+				if p, ok := p.(string); ok {
+					changed = append(changed, p)
+				}
+			}
+			continue
+		}
+		current, _ := s.roleProperty(roleID, property)
+		changed = append(changed, current...)
+	}
+
+	return !s.hasAdmin(s.store.list(), func(r string) ([]string, bool) {
+		if r == roleID {
+			return changed, true
+		}
+		return s.rolePrivileges(r)
+	})
+}
+
+// roleInUse returns true if any account has the role
+func (s *Service) roleInUse(roleID string) bool {
+	for _, a := range s.store.list() {
+		if a.RoleID == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// rolePropertyPatch sets the privileges, the PATCH command has already
+// checked them.
+func (s *Service) rolePropertyPatch(rrp *domain.RedfishResourceProperty, body interface{}, present bool) {
+	if present {
+		// replaced, not appended to
+		rrp.Value = nil
+		rrp.Parse(body)
+	}
+}
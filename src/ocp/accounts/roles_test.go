@@ -0,0 +1,131 @@
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testRoles are the Role resources for rolePrivileges
+type testRoles map[string][]string
+
+func (r testRoles) GetResourceProperty(ctx context.Context, uri, property string) (interface{}, bool) {
+	privileges, ok := r[strings.TrimPrefix(uri, RolesURI+"/")]
+	if !ok {
+		return nil, false
+	}
+	if property != "AssignedPrivileges" {
+		return []interface{}{}, true
+	}
+	list := []interface{}{}
+	for _, p := range privileges {
+		list = append(list, p)
+	}
+	return list, true
+}
+
+func TestCheckRole(t *testing.T) {
+	tests := []struct {
+		body     string
+		messages []string
+	}{
+		{`{}`, nil},
+		{`{"AssignedPrivileges": ["Login", "ConfigureUsers"], "OemPrivileges": ["OemClearLog"]}`, nil},
+		{`{"AssignedPrivileges": []}`, nil},
+		{`{"AssignedPrivileges": ["Login", "OemClearLog"]}`, []string{"PropertyValueNotInList"}},
+		{`{"AssignedPrivileges": "Login"}`, []string{"PropertyValueTypeError"}},
+		{`{"AssignedPrivileges": ["Login", 1, ""]}`, []string{"PropertyValueTypeError", "PropertyValueTypeError"}},
+		{`{"OemPrivileges": [null]}`, []string{"PropertyValueTypeError"}},
+		{`{"AssignedPrivileges": ["Nope"], "OemPrivileges": {}}`, []string{"PropertyValueNotInList", "PropertyValueTypeError"}},
+		// what the auth plugins and accounts give out can't be in a role
+		{`{"OemPrivileges": ["ConfigureSelf_root"]}`, []string{"PropertyValueFormatError"}},
+		{`{"OemPrivileges": ["configureself_root", "ConfigureSelf_"]}`, []string{"PropertyValueFormatError", "PropertyValueFormatError"}},
+		{`{"OemPrivileges": ["Unauthenticated", "basicauth", "bearerauth", "certauth", "tokenauth"]}`,
+			[]string{"PropertyValueFormatError", "PropertyValueFormatError", "PropertyValueFormatError", "PropertyValueFormatError", "PropertyValueFormatError"}},
+		{`{"OemPrivileges": ["BasicAuth", "OemConfigureSelf_root"]}`, []string{"PropertyValueFormatError"}},
+		{`{"AssignedPrivileges": ["Unauthenticated"]}`, []string{"PropertyValueNotInList"}},
+	}
+	for _, tc := range tests {
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+			t.Fatal(err)
+		}
+		rerr := checkRole(body)
+		messages := []string{}
+		if rerr != nil {
+			for _, ei := range rerr.ExtendedInfo {
+				messages = append(messages, ei.MessageID)
+			}
+		}
+		if strings.Join(messages, ",") != strings.Join(tc.messages, ",") {
+			t.Errorf("%s: messages %v, expected %v", tc.body, messages, tc.messages)
+		}
+	}
+}
+
+func TestRolePrivileges(t *testing.T) {
+	s := newAdminService(t)
+	tests := []struct {
+		roleID     string
+		privileges string
+		ok         bool
+	}{
+		{"Administrator", "Login,ConfigureManager,ConfigureUsers,ConfigureSelf,ConfigureComponents", true},
+		{"ReadOnly", "Login,ConfigureSelf", true},
+		{"Nope", "", false},
+		{"", "", false},
+		{"../Roles/Administrator", "", false},
+	}
+	for _, tc := range tests {
+		privileges, ok := s.rolePrivileges(tc.roleID)
+		if ok != tc.ok || strings.Join(privileges, ",") != tc.privileges {
+			t.Errorf("%q: %v %v", tc.roleID, privileges, ok)
+		}
+	}
+
+	// accounts get their own ConfigureSelf_, and nothing while disabled
+	if privileges, ok := s.Privileges("operator"); !ok || strings.Join(privileges, ",") != "ConfigureSelf_operator,Login,ConfigureSelf,ConfigureComponents" {
+		t.Errorf("operator: %v %v", privileges, ok)
+	}
+	if privileges, ok := s.Privileges("former"); ok {
+		t.Errorf("disabled account has privileges %v", privileges)
+	}
+}
+
+func TestRoleRemovesLastAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		roleID  string
+		body    string
+		removes bool
+	}{
+		{"admin role loses ConfigureUsers", "Administrator", `{"AssignedPrivileges": ["Login", "ConfigureManager"]}`, true},
+		{"admin role loses everything", "Administrator", `{"AssignedPrivileges": []}`, true},
+		{"admin role keeps ConfigureUsers", "Administrator", `{"AssignedPrivileges": ["Login", "ConfigureUsers"]}`, false},
+		{"admin role only changes oem", "Administrator", `{"OemPrivileges": ["OemClearLog"]}`, false},
+		{"admin role gets ConfigureUsers as oem", "Administrator", `{"AssignedPrivileges": ["Login"], "OemPrivileges": ["ConfigureUsers"]}`, false},
+		{"another role", "Operator", `{"AssignedPrivileges": []}`, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newAdminService(t)
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if removes := s.roleRemovesLastAdmin(tc.roleID, body); removes != tc.removes {
+				t.Errorf("roleRemovesLastAdmin is %v", removes)
+			}
+		})
+	}
+}
+
+func TestRoleInUse(t *testing.T) {
+	s := newAdminService(t)
+	for roleID, inUse := range map[string]bool{"Administrator": true, "Operator": true, "ReadOnly": false} {
+		if s.roleInUse(roleID) != inUse {
+			t.Errorf("%s in use: %v", roleID, !inUse)
+		}
+	}
+}
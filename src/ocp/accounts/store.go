@@ -0,0 +1,151 @@
+package accounts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	plugins "github.com/superchalupa/go-redfish/src/ocp"
+)
+
+// account is what we keep for each local account. Only a salted hash of the
+// password is kept.
+type account struct {
+	UserName     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	RoleID       string `json:"role_id"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// store is the set of local accounts, saved to a file that only we can read
+// every time it changes. No file means no accounts yet.
+type store struct {
+	sync.RWMutex
+	filename string
+	accounts map[string]*account
+
+	// successful password checks, any change to the account drops them
+	cache loginCache
+}
+
+// how long a password check is remembered
+const storeCacheTime = time.Minute
+
+// used to compare against for unknown users, so that looking up a user that
+// doesn't exist takes as long as checking a bad password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+func loadStore(filename string) (*store, error) {
+	s := &store{filename: filename, accounts: map[string]*account{}}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.accounts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// save writes the accounts out, already locked when we get here. The file
+// is written next to the real one and renamed over it so that it is never
+// half written.
+func (s *store) save() error {
+	if s.filename == "" {
+		return nil
+	}
+	return plugins.WriteFileAtomic(s.filename, s.accounts, 0600)
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func (s *store) get(username string) (account, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	a, ok := s.accounts[username]
+	if !ok {
+		return account{}, false
+	}
+	return *a, true
+}
+
+func (s *store) list() []account {
+	s.RLock()
+	defer s.RUnlock()
+	ret := []account{}
+	for _, a := range s.accounts {
+		ret = append(ret, *a)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].UserName < ret[j].UserName })
+	return ret
+}
+
+func (s *store) add(username, password, roleID string, enabled bool) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.accounts[username] = &account{UserName: username, PasswordHash: hash, RoleID: roleID, Enabled: enabled}
+	return s.save()
+}
+
+func (s *store) remove(username string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.accounts, username)
+	s.cache.drop(username)
+	return s.save()
+}
+
+// update changes an account and saves the result
+func (s *store) update(username string, fn func(*account) error) error {
+	s.Lock()
+	defer s.Unlock()
+	a, ok := s.accounts[username]
+	if !ok {
+		return os.ErrNotExist
+	}
+	updated := *a
+	if err := fn(&updated); err != nil {
+		return err
+	}
+	s.accounts[username] = &updated
+	s.cache.drop(username)
+	return s.save()
+}
+
+// check returns the account if the password is right and it is enabled
+func (s *store) check(username, password string) (account, bool) {
+	a, ok := s.get(username)
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return account{}, false
+	}
+	if _, ok := s.cache.get(username, password); ok {
+		return a, a.Enabled
+	}
+	if bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password)) != nil {
+		return account{}, false
+	}
+	s.Lock()
+	// unless the account changed while bcrypt was running
+	if current, ok := s.accounts[username]; ok && current.PasswordHash == a.PasswordHash && current.Enabled {
+		// local accounts don't have a provider
+		s.cache.put(username, password, remoteLogin{}, storeCacheTime)
+	}
+	s.Unlock()
+	return a, a.Enabled
+}
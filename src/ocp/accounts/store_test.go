@@ -0,0 +1,97 @@
+package accounts
+
+import (
+	"testing"
+)
+
+func newTestStore(t *testing.T) *store {
+	s, err := loadStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add("alice", "password1", "Administrator", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add("bob", "password2", "Operator", true); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func cachedUsers(s *store) map[string]int {
+	s.cache.Lock()
+	defer s.cache.Unlock()
+	users := map[string]int{}
+	for _, entry := range s.cache.entries {
+		users[entry.username]++
+	}
+	return users
+}
+
+func TestStoreCheckCachesSuccess(t *testing.T) {
+	s := newTestStore(t)
+
+	if _, ok := s.check("alice", "wrong"); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if n := cachedUsers(s)["alice"]; n != 0 {
+		t.Fatalf("failed check was cached: %d entries", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		a, ok := s.check("alice", "password1")
+		if !ok || a.UserName != "alice" {
+			t.Fatalf("check %d: got %v %v", i, a, ok)
+		}
+	}
+	if n := cachedUsers(s)["alice"]; n != 1 {
+		t.Fatalf("expected one cached login, got %d", n)
+	}
+	if _, ok := s.check("alice", "wrong"); ok {
+		t.Fatal("wrong password accepted with a cached login")
+	}
+}
+
+func TestStoreCacheDroppedOnChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*store) error
+		checkOK bool
+	}{
+		{"password", func(s *store) error {
+			return s.update("alice", func(a *account) error {
+				a.PasswordHash, _ = hashPassword("password3")
+				return nil
+			})
+		}, false},
+		{"role", func(s *store) error {
+			return s.update("alice", func(a *account) error { a.RoleID = "ReadOnly"; return nil })
+		}, true},
+		{"disable", func(s *store) error {
+			return s.update("alice", func(a *account) error { a.Enabled = false; return nil })
+		}, false},
+		{"delete", func(s *store) error { return s.remove("alice") }, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestStore(t)
+			s.check("alice", "password1")
+			s.check("bob", "password2")
+
+			if err := tc.change(s); err != nil {
+				t.Fatal(err)
+			}
+			users := cachedUsers(s)
+			if users["alice"] != 0 {
+				t.Fatalf("cached login kept after %s change", tc.name)
+			}
+			if users["bob"] != 1 {
+				t.Fatalf("other users' logins dropped after %s change", tc.name)
+			}
+			if _, ok := s.check("alice", "password1"); ok != tc.checkOK {
+				t.Fatalf("check after %s change: got %v, want %v", tc.name, ok, tc.checkOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package plugins
+
+// Authenticator checks a username and password for the login paths (basic
+// auth and session login). It returns the privileges the user gets.
+type Authenticator interface {
+	Authenticate(username, password string) (privileges []string, ok bool)
+}
+
+// PrivilegeGetter is for Authenticators that can look up the current
+// privileges of a user that has already logged in, so that changes to their
+// account or role apply to existing sessions. ok is false if the user can't
+// log in anymore.
+type PrivilegeGetter interface {
+	Privileges(username string) (privileges []string, ok bool)
+}
+
+// RoleGetter looks up the privileges of a Role, for authenticators that get
+// the roles of a user from somewhere other than an account (ie. the claims in
+// a token). ok is false if there is no such role.
+type RoleGetter interface {
+	RolePrivileges(roleID string) (privileges []string, ok bool)
+}
@@ -17,6 +17,7 @@ const (
 
 type Service struct {
 	*plugins.Service
+	auth plugins.Authenticator
 }
 
 func New(options ...interface{}) (*Service, error) {
@@ -29,30 +30,22 @@ func New(options ...interface{}) (*Service, error) {
 	return s, nil
 }
 
+// WithAuthenticator is what checks the username and password
+func WithAuthenticator(auth plugins.Authenticator) Option {
+	return func(s *Service) error {
+		s.auth = auth
+		return nil
+	}
+}
+
 func (a *Service) MakeHandlerFunc(withUser func(string, []string) http.Handler, chain http.Handler) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
 		username, password, ok := req.BasicAuth()
 		privileges := []string{}
-		if ok {
-			// TODO: Actually look up privileges
-			// hardcode some privileges for now
-			if username == "Administrator" && password == "password" {
-				privileges = append(privileges,
-					"Unauthenticated", "basicauth", "ConfigureSelf_"+username,
-					"Login", "ConfigureManager", "ConfigureUsers", "ConfigureComponents",
-				)
-			}
-			if username == "Operator" && password == "password" {
-				privileges = append(privileges,
-					"Unauthenticated", "basicauth", "ConfigureSelf_"+username,
-					"Login", "ConfigureComponents",
-				)
-			}
-			if username == "ReadOnly" && password == "password" {
-				privileges = append(privileges,
-					"Unauthenticated", "basicauth", "ConfigureSelf_"+username,
-					"Login",
-				)
+		if ok && a.auth != nil {
+			if userPrivileges, ok := a.auth.Authenticate(username, password); ok {
+				privileges = append(privileges, "Unauthenticated", "basicauth")
+				privileges = append(privileges, userPrivileges...)
 			}
 		}
 		if len(privileges) > 0 && username != "" {
@@ -0,0 +1,287 @@
+package bearerauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/superchalupa/go-redfish/src/log"
+	plugins "github.com/superchalupa/go-redfish/src/ocp"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+)
+
+const (
+	BearerAuthPlugin = domain.PluginType("obmc_bearer_auth")
+)
+
+// only the asymmetric algorithms, the provider never shares a secret with us
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// RoleMapping gives the users that have the remote group (or role) in their
+// token claims the local Role
+type RoleMapping struct {
+	RemoteGroup string
+	LocalRole   string
+}
+
+// RoleMappings reads the mapping from the config, a list of maps with
+// "remote_group" and "local_role" in them. Anything else is skipped.
+func RoleMappings(raw interface{}) []RoleMapping {
+	list, _ := raw.([]interface{})
+	mapping := []RoleMapping{}
+	for _, item := range list {
+		m := RoleMapping{}
+		switch item := item.(type) {
+		case map[interface{}]interface{}:
+			m.RemoteGroup, _ = item["remote_group"].(string)
+			m.LocalRole, _ = item["local_role"].(string)
+		case map[string]interface{}:
+			m.RemoteGroup, _ = item["remote_group"].(string)
+			m.LocalRole, _ = item["local_role"].(string)
+		}
+		if m.RemoteGroup != "" && m.LocalRole != "" {
+			mapping = append(mapping, m)
+		}
+	}
+	return mapping
+}
+
+// Service logs in the users with an "Authorization: Bearer" JWT from an
+// external identity provider (ie. an OAuth2 access token or an OIDC id
+// token). They have no account here, their privileges come from the Roles
+// that their groups are mapped to.
+type Service struct {
+	*plugins.Service
+	roles plugins.RoleGetter
+
+	issuer    string
+	audience  string
+	userClaim string
+	roleClaim string
+	mapping   []RoleMapping
+	keys      *keySet
+}
+
+func New(options ...interface{}) (*Service, error) {
+	s := &Service{
+		Service:   plugins.NewService(plugins.PluginType(BearerAuthPlugin)),
+		userClaim: "sub",
+		roleClaim: "groups",
+		keys: &keySet{
+			refresh: time.Hour,
+			client:  &http.Client{Timeout: 10 * time.Second},
+		},
+	}
+
+	s.ApplyOption(plugins.UUID())
+	s.ApplyOption(options...)
+	return s, nil
+}
+
+// WithRoles is what looks up the privileges of the mapped Roles
+func WithRoles(roles plugins.RoleGetter) Option {
+	return func(s *Service) error {
+		s.roles = roles
+		return nil
+	}
+}
+
+// Issuer is the "iss" that tokens must have. Tokens are refused if it isn't
+// set.
+func Issuer(issuer string) Option {
+	return func(s *Service) error {
+		s.issuer = issuer
+		return nil
+	}
+}
+
+// Audience has to be in the "aud" of tokens, so that tokens the provider
+// gave out for something else don't work here. Tokens are refused if it
+// isn't set.
+func Audience(audience string) Option {
+	return func(s *Service) error {
+		s.audience = audience
+		return nil
+	}
+}
+
+// UserClaim is the claim with the user name, ie. "preferred_username"
+func UserClaim(claim string) Option {
+	return func(s *Service) error {
+		s.userClaim = claim
+		return nil
+	}
+}
+
+// RoleClaim is the claim with the list of groups or roles for the mapping
+func RoleClaim(claim string) Option {
+	return func(s *Service) error {
+		s.roleClaim = claim
+		return nil
+	}
+}
+
+// RemoteRoleMapping replaces the mapping of remote groups to local Roles
+func RemoteRoleMapping(mapping ...RoleMapping) Option {
+	return func(s *Service) error {
+		s.mapping = mapping
+		return nil
+	}
+}
+
+// JWKSURL is where the signing keys of the provider are fetched from
+func JWKSURL(url string) Option {
+	return func(s *Service) error {
+		s.keys.url = url
+		return nil
+	}
+}
+
+// JWKSFile is a local copy of the signing keys of the provider, it is used
+// instead of the JWKSURL if both are set.
+func JWKSFile(filename string) Option {
+	return func(s *Service) error {
+		s.keys.file = filename
+		return nil
+	}
+}
+
+// JWKSRefresh is how often the signing keys are loaded again
+func JWKSRefresh(d time.Duration) Option {
+	return func(s *Service) error {
+		s.keys.refresh = d
+		return nil
+	}
+}
+
+// enabled is false until there are keys to check tokens with, the bearer
+// tokens are left for the rest of the chain until then.
+func (s *Service) enabled() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.keys.url != "" || s.keys.file != ""
+}
+
+// audienceOK checks the "aud" claim, which can be a string or a list
+func audienceOK(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimList returns a claim that can be a string or a list of strings
+func claimList(claims jwt.MapClaims, claim string) []string {
+	switch v := claims[claim].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := []string{}
+		for _, item := range v {
+			if item, ok := item.(string); ok {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// authenticate checks the token and returns who it is for, and the
+// privileges of the Roles that their groups map to.
+func (s *Service) authenticate(tokenString string) (string, []string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.issuer == "" || s.audience == "" {
+		return "", nil, fmt.Errorf("issuer and audience have to be configured")
+	}
+
+	parser := &jwt.Parser{ValidMethods: validMethods}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.keys.lookup(kid)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	// Parse has checked nbf and iat, and exp if it is there, but it has to be
+	now := time.Now().Unix()
+	switch {
+	case !claims.VerifyExpiresAt(now, true):
+		return "", nil, fmt.Errorf("token has no expiry or is expired")
+	case !claims.VerifyIssuer(s.issuer, true):
+		return "", nil, fmt.Errorf("token is from the wrong issuer")
+	case !audienceOK(claims, s.audience):
+		return "", nil, fmt.Errorf("token is for the wrong audience")
+	}
+
+	userName, _ := claims[s.userClaim].(string)
+	if userName == "" {
+		return "", nil, fmt.Errorf("token has no %s claim", s.userClaim)
+	}
+
+	privileges := []string{}
+	if s.roles != nil {
+		for _, group := range claimList(claims, s.roleClaim) {
+			for _, m := range s.mapping {
+				if m.RemoteGroup != group {
+					continue
+				}
+				if rolePrivileges, ok := s.roles.RolePrivileges(m.LocalRole); ok {
+					privileges = append(privileges, rolePrivileges...)
+				}
+			}
+		}
+	}
+	if len(privileges) == 0 {
+		return "", nil, fmt.Errorf("no role is mapped for the user")
+	}
+	return userName, privileges, nil
+}
+
+func (a *Service) MakeHandlerFunc(withUser func(string, []string) http.Handler, chain http.Handler) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		var username string
+		privileges := []string{}
+
+		authorization := req.Header.Get("Authorization")
+		if a.enabled() && len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+			userName, userPrivileges, err := a.authenticate(strings.TrimSpace(authorization[7:]))
+			if err == nil {
+				username = userName
+				privileges = append(privileges, "Unauthenticated", "bearerauth")
+				privileges = append(privileges, userPrivileges...)
+			} else {
+				log.MustLogger("bearerauth").Info("Bearer token refused", "err", err)
+			}
+		}
+		if len(privileges) > 0 && username != "" {
+			withUser(username, privileges).ServeHTTP(rw, req)
+		} else {
+			chain.ServeHTTP(rw, req)
+		}
+	}
+}
+
+func (s *Service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) {
+	// no-op
+}
@@ -0,0 +1,173 @@
+package bearerauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// testRoles is a RoleGetter with fixed Roles
+type testRoles map[string][]string
+
+func (r testRoles) RolePrivileges(roleID string) ([]string, bool) {
+	privileges, ok := r[roleID]
+	return privileges, ok
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// padded returns the coordinate as the fixed size big endian the JWK has
+func padded(i *big.Int, size int) []byte {
+	b := i.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// newTestService returns a Service that takes its keys from a JWKS server
+// with an RSA key "rsa" and an EC key "ec" in it
+func newTestService(t *testing.T) (*Service, *rsa.PrivateKey, *ecdsa.PrivateKey, func()) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "use": "sig", "crv": "P-256", "x": b64(padded(ecKey.X, 32)), "y": b64(padded(ecKey.Y, 32))},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys)
+	}))
+
+	s, _ := New(
+		WithRoles(testRoles{"Administrator": {"Login", "ConfigureUsers"}, "ReadOnly": {"Login"}}),
+		Issuer("https://idp.example.com"),
+		Audience("bmc"),
+		UserClaim("preferred_username"),
+		RemoteRoleMapping(RoleMappings([]interface{}{
+			map[interface{}]interface{}{"remote_group": "admins", "local_role": "Administrator"},
+			map[string]interface{}{"remote_group": "viewers", "local_role": "ReadOnly"},
+		})...),
+		JWKSURL(server.URL),
+	)
+	return s, rsaKey, ecKey, server.Close
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, rsaKey, ecKey, stop := newTestService(t)
+	defer stop()
+
+	// claims returns valid claims, with the changes made to them
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":                "https://idp.example.com",
+			"aud":                []interface{}{"other", "bmc"},
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"preferred_username": "alice",
+			"groups":             []interface{}{"users", "admins"},
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	rsaPublic := []byte(b64(rsaKey.N.Bytes()))
+
+	tests := []struct {
+		name       string
+		token      string
+		user       string
+		privileges []string
+	}{
+		{"RS256", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(nil)),
+			"alice", []string{"Login", "ConfigureUsers"}},
+		{"ES256", sign(t, jwt.SigningMethodES256, ecKey, "ec", claims(jwt.MapClaims{"aud": "bmc", "groups": "viewers"})),
+			"alice", []string{"Login"}},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "rsa", claims(nil)), "", nil},
+		{"HS256", sign(t, jwt.SigningMethodHS256, rsaPublic, "rsa", claims(nil)), "", nil},
+		{"wrong key", sign(t, jwt.SigningMethodES256, ecKey, "rsa", claims(nil)), "", nil},
+		{"wrong iss", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"iss": "https://evil.example.com"})), "", nil},
+		{"no iss", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"iss": nil})), "", nil},
+		{"wrong aud", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"aud": "other"})), "", nil},
+		{"no aud", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"aud": nil})), "", nil},
+		{"no exp", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"exp": nil})), "", nil},
+		{"expired", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), "", nil},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, rsaKey, "other", claims(nil)), "", nil},
+		{"no kid", sign(t, jwt.SigningMethodRS256, rsaKey, "", claims(nil)), "", nil},
+		{"no user", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"preferred_username": nil})), "", nil},
+		{"no mapped group", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"groups": []interface{}{"users"}})), "", nil},
+		{"no groups", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(jwt.MapClaims{"groups": nil})), "", nil},
+		{"garbage", "not.a.token", "", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			user, privileges, err := s.authenticate(tc.token)
+			if tc.user == "" {
+				if err == nil {
+					t.Fatalf("accepted for %s with %v", user, privileges)
+				}
+				t.Log(err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("refused: %s", err)
+			}
+			if user != tc.user {
+				t.Errorf("user is %s, expected %s", user, tc.user)
+			}
+			if len(privileges) != len(tc.privileges) {
+				t.Fatalf("privileges are %v, expected %v", privileges, tc.privileges)
+			}
+			for i := range privileges {
+				if privileges[i] != tc.privileges[i] {
+					t.Fatalf("privileges are %v, expected %v", privileges, tc.privileges)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateNeedsIssuerAndAudience(t *testing.T) {
+	s, rsaKey, _, stop := newTestService(t)
+	defer stop()
+	s.ApplyOption(Audience(""))
+
+	token := sign(t, jwt.SigningMethodRS256, rsaKey, "rsa", jwt.MapClaims{
+		"iss":                "https://idp.example.com",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"preferred_username": "alice",
+		"groups":             "admins",
+	})
+	if _, _, err := s.authenticate(token); err == nil {
+		t.Fatal("accepted a token without an audience configured")
+	}
+}
@@ -0,0 +1,218 @@
+package bearerauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwk is one key from a JSON Web Key Set (RFC 7517), only the public key
+// members that we use.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("bad RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
+
+// parseJWKS returns the signing keys in a key set by kid. Keys we can't use
+// are skipped rather than failing the whole set.
+func parseJWKS(b []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// keySet is the signing keys of the identity provider, from a JWKS URL or a
+// local file. They are loaded again every refresh, or sooner when a token
+// has a kid that we don't know (the provider rotated its keys), but not more
+// than once every minRefresh so that made up kids can't hammer the provider.
+// Only one load runs at a time and never with the lock held: tokens with a
+// kid we have keep using the old keys meanwhile, the ones that need the new
+// keys wait for it.
+type keySet struct {
+	sync.Mutex
+	url     string
+	file    string
+	refresh time.Duration
+	client  *http.Client
+
+	keys   map[string]interface{}
+	loaded time.Time
+	// closed when the load that is running is done, nil if there's none
+	loading chan struct{}
+	// why the last load failed
+	err error
+}
+
+const minRefresh = 30 * time.Second
+
+func (k *keySet) fetch() ([]byte, error) {
+	if k.file != "" {
+		return ioutil.ReadFile(k.file)
+	}
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request failed: %s", resp.Status)
+	}
+	// a key set is small, don't read whatever we are sent
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// loadUnlocked starts loading the keys again unless that is already
+// happening, and returns the channel that is closed when it is done. The
+// keys we have are kept if it fails.
+func (k *keySet) loadUnlocked() chan struct{} {
+	if k.loading != nil {
+		return k.loading
+	}
+	k.loaded = time.Now()
+	done := make(chan struct{})
+	k.loading = done
+	go func() {
+		defer close(done)
+		b, err := k.fetch()
+		var keys map[string]interface{}
+		if err == nil {
+			keys, err = parseJWKS(b)
+		}
+
+		k.Lock()
+		defer k.Unlock()
+		k.loading = nil
+		k.err = err
+		if err == nil {
+			k.keys = keys
+		}
+	}()
+	return done
+}
+
+// lookup returns the key for the kid in a token header. A token without a
+// kid can only be checked if the provider has just the one key.
+func (k *keySet) lookup(kid string) (interface{}, error) {
+	k.Lock()
+	if time.Since(k.loaded) > k.refresh {
+		k.loadUnlocked()
+	}
+	key, ok := k.find(kid)
+	if ok {
+		k.Unlock()
+		return key, nil
+	}
+	var done chan struct{}
+	if k.loading != nil || time.Since(k.loaded) > minRefresh {
+		done = k.loadUnlocked()
+	}
+	k.Unlock()
+	if done == nil {
+		return nil, fmt.Errorf("Unknown signing key: %v", kid)
+	}
+
+	<-done
+	k.Lock()
+	defer k.Unlock()
+	if key, ok := k.find(kid); ok {
+		return key, nil
+	}
+	if k.err != nil {
+		return nil, k.err
+	}
+	return nil, fmt.Errorf("Unknown signing key: %v", kid)
+}
+
+func (k *keySet) find(kid string) (interface{}, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
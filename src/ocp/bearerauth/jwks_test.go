@@ -0,0 +1,182 @@
+package bearerauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJWKS is a JWKS server that counts the requests and can be held up
+type testJWKS struct {
+	*httptest.Server
+	requests int32
+	status   int32
+
+	mu   sync.Mutex
+	kids []string
+	hold chan struct{}
+}
+
+func newTestJWKS(t *testing.T, kids ...string) *testJWKS {
+	j := &testJWKS{kids: kids, status: http.StatusOK}
+	j.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&j.requests, 1)
+		j.mu.Lock()
+		hold, kids := j.hold, j.kids
+		j.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		if status := int(atomic.LoadInt32(&j.status)); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		keys := []map[string]string{}
+		for _, kid := range kids {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			keys = append(keys, map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(padded(key.X, 32)), "y": b64(padded(key.Y, 32))})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	return j
+}
+
+func (j *testJWKS) set(hold chan struct{}, kids ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.hold = hold
+	if kids != nil {
+		j.kids = kids
+	}
+}
+
+func (j *testJWKS) count() int {
+	return int(atomic.LoadInt32(&j.requests))
+}
+
+func newTestKeySet(url string) *keySet {
+	return &keySet{url: url, refresh: time.Hour, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func TestKeySetLoadsOnce(t *testing.T) {
+	j := newTestJWKS(t, "a")
+	defer j.Close()
+	hold := make(chan struct{})
+	j.set(hold)
+	k := newTestKeySet(j.URL)
+
+	// everybody waits for the one load
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := k.lookup("a"); err != nil {
+				t.Errorf("lookup: %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(hold)
+	wg.Wait()
+	if n := j.count(); n != 1 {
+		t.Errorf("%d requests for the keys", n)
+	}
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	j := newTestJWKS(t, "a")
+	defer j.Close()
+	k := newTestKeySet(j.URL)
+	if _, err := k.lookup("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys are old and the provider is slow: the ones we have are used meanwhile
+	hold := make(chan struct{})
+	j.set(hold, "b")
+	k.Lock()
+	k.loaded = time.Now().Add(-2 * time.Hour)
+	k.Unlock()
+	start := time.Now()
+	if _, err := k.lookup("a"); err != nil {
+		t.Errorf("cached key during the refresh: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("lookup waited %v for the refresh", d)
+	}
+
+	// a new kid waits for the refresh that is running
+	found := make(chan error)
+	go func() {
+		_, err := k.lookup("b")
+		found <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(hold)
+	if err := <-found; err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if n := j.count(); n != 2 {
+		t.Errorf("%d requests for the keys", n)
+	}
+	if _, err := k.lookup("a"); err == nil {
+		t.Errorf("key that was rotated out is still used")
+	}
+}
+
+func TestKeySetUnknownKid(t *testing.T) {
+	j := newTestJWKS(t, "a")
+	defer j.Close()
+	k := newTestKeySet(j.URL)
+	k.lookup("a")
+
+	// made up kids don't load the keys again every time
+	for i := 0; i < 5; i++ {
+		if _, err := k.lookup("nope"); err == nil {
+			t.Errorf("found a key for a kid that isn't there")
+		}
+	}
+	if n := j.count(); n != 1 {
+		t.Errorf("%d requests for the keys", n)
+	}
+
+	// but a rotation is picked up after minRefresh
+	j.set(nil, "a", "b")
+	k.Lock()
+	k.loaded = time.Now().Add(-minRefresh - time.Second)
+	k.Unlock()
+	if _, err := k.lookup("b"); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+}
+
+func TestKeySetLoadFails(t *testing.T) {
+	j := newTestJWKS(t, "a")
+	defer j.Close()
+	k := newTestKeySet(j.URL)
+	k.lookup("a")
+
+	atomic.StoreInt32(&j.status, http.StatusInternalServerError)
+	k.Lock()
+	k.loaded = time.Now().Add(-2 * time.Hour)
+	k.Unlock()
+	if _, err := k.lookup("b"); err == nil || err.Error() != "JWKS request failed: 500 Internal Server Error" {
+		t.Errorf("new kid when loading fails: %v", err)
+	}
+	// the keys we had are kept
+	if _, err := k.lookup("a"); err != nil {
+		t.Errorf("cached key after loading failed: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ package bmc
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/superchalupa/go-redfish/src/log"
@@ -171,12 +172,11 @@ func (s *service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.E
 	sp.RunForever(func(event eh.Event) {
 		log.MustLogger("ocp_bmc").Info("Got action event", "event", event)
 
-		eventData := domain.HTTPCmdProcessedData{
-			CommandID:  event.Data().(ah.GenericActionEventData).CmdID,
-			Results:    map[string]interface{}{"msg": "Not Implemented"},
-			StatusCode: 500,
-			Headers:    map[string]string{},
-		}
+		// default response if nobody has registered a handler for the action
+		eventData := domain.NewErrorResponse(
+			event.Data().(ah.GenericActionEventData).CmdID,
+			domain.NewRedfishError(http.StatusInternalServerError, "ActionNotSupported", "Manager.Reset"),
+		)
 
 		handler := s.GetProperty("manager.reset")
 		if handler != nil {
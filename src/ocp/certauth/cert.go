@@ -0,0 +1,111 @@
+package certauth
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	plugins "github.com/superchalupa/go-redfish/src/ocp"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+)
+
+const (
+	CertAuthPlugin = domain.PluginType("obmc_cert_auth")
+)
+
+// Service logs in the account named in a TLS client certificate. The https
+// listener has to verify the certificate against the client CA bundle, only
+// verified certificates are looked at.
+type Service struct {
+	*plugins.Service
+	accounts plugins.PrivilegeGetter
+	userName string
+}
+
+// Where the account name comes from in a certificate
+const (
+	CommonName = "cn"    // the subject CN
+	DNSName    = "dns"   // the DNS SAN
+	EmailName  = "email" // the email SAN
+)
+
+func New(options ...interface{}) (*Service, error) {
+	s := &Service{
+		Service:  plugins.NewService(plugins.PluginType(CertAuthPlugin)),
+		userName: CommonName,
+	}
+
+	s.ApplyOption(plugins.UUID())
+	s.ApplyOption(options...)
+	return s, nil
+}
+
+// WithAccounts is what looks up the privileges of the account in the
+// certificate
+func WithAccounts(accounts plugins.PrivilegeGetter) Option {
+	return func(s *Service) error {
+		s.accounts = accounts
+		return nil
+	}
+}
+
+// UserName is where the account name is taken from: CommonName (the
+// default), DNSName or EmailName. Only that field is used, so that names the
+// CA put in the other fields for other reasons can't log in. Anything else
+// and no certificate logs in.
+func UserName(from string) Option {
+	return func(s *Service) error {
+		s.userName = from
+		if from == "" {
+			s.userName = CommonName
+		}
+		return nil
+	}
+}
+
+// userName returns the account name in the certificate. SANs can be in a
+// certificate more than once, those that are aren't used.
+func userName(cert *x509.Certificate, from string) (string, bool) {
+	var names []string
+	switch from {
+	case CommonName:
+		names = []string{cert.Subject.CommonName}
+	case DNSName:
+		names = cert.DNSNames
+	case EmailName:
+		names = cert.EmailAddresses
+	}
+	if len(names) != 1 || names[0] == "" {
+		return "", false
+	}
+	return names[0], true
+}
+
+func (a *Service) MakeHandlerFunc(withUser func(string, []string) http.Handler, chain http.Handler) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		username := ""
+		privileges := []string{}
+		// VerifiedChains is empty unless the listener checked the certificate
+		if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && a.accounts != nil {
+			if name, ok := userName(req.TLS.VerifiedChains[0][0], a.userName); ok {
+				if userPrivileges, ok := a.accounts.Privileges(name); ok {
+					username = name
+					privileges = append(privileges, "Unauthenticated", "certauth")
+					privileges = append(privileges, userPrivileges...)
+				}
+			}
+		}
+		if len(privileges) > 0 && username != "" {
+			withUser(username, privileges).ServeHTTP(rw, req)
+		} else {
+			chain.ServeHTTP(rw, req)
+		}
+	}
+}
+
+func (s *Service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) {
+	// no-op
+}
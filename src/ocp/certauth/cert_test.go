@@ -0,0 +1,89 @@
+package certauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testAccounts are the accounts that can log in, and their privileges
+type testAccounts map[string][]string
+
+func (a testAccounts) Privileges(username string) ([]string, bool) {
+	p, ok := a[username]
+	return p, ok
+}
+
+func testCert(cn string, dns, email []string) *x509.Certificate {
+	return &x509.Certificate{Subject: pkix.Name{CommonName: cn}, DNSNames: dns, EmailAddresses: email}
+}
+
+func TestUserName(t *testing.T) {
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		from string
+		user string
+	}{
+		{"cn", testCert("alice", []string{"bob"}, nil), CommonName, "alice"},
+		{"no cn", testCert("", []string{"bob"}, nil), CommonName, ""},
+		{"dns", testCert("alice", []string{"bob"}, []string{"carol"}), DNSName, "bob"},
+		{"no dns", testCert("alice", nil, nil), DNSName, ""},
+		{"more than one dns", testCert("alice", []string{"bob", "root"}, nil), DNSName, ""},
+		{"email", testCert("alice", []string{"bob"}, []string{"carol"}), EmailName, "carol"},
+		{"more than one email", testCert("alice", nil, []string{"carol", "root"}), EmailName, ""},
+		{"unknown", testCert("alice", []string{"bob"}, nil), "upn", ""},
+	}
+	for _, tc := range tests {
+		user, ok := userName(tc.cert, tc.from)
+		if user != tc.user || ok != (tc.user != "") {
+			t.Errorf("%s: %q %v", tc.name, user, ok)
+		}
+	}
+}
+
+func TestMakeHandlerFunc(t *testing.T) {
+	accounts := testAccounts{"alice": {"Login"}, "bob.example.com": {"Login", "ConfigureManager"}}
+	verified := func(cert *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	tests := []struct {
+		name       string
+		from       string
+		tls        *tls.ConnectionState
+		user       string
+		privileges string
+	}{
+		{"cn", "", verified(testCert("alice", []string{"bob.example.com"}, nil)), "alice", "Unauthenticated,certauth,Login"},
+		// the SANs aren't looked at unless they are configured
+		{"cn without an account", "", verified(testCert("nobody", []string{"bob.example.com"}, nil)), "", ""},
+		{"dns", DNSName, verified(testCert("alice", []string{"bob.example.com"}, nil)), "bob.example.com", "Unauthenticated,certauth,Login,ConfigureManager"},
+		{"dns, not the cn", DNSName, verified(testCert("alice", nil, nil)), "", ""},
+		{"unknown", "upn", verified(testCert("alice", nil, nil)), "", ""},
+		{"not verified", "", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{testCert("alice", nil, nil)}}, "", ""},
+		{"not tls", "", nil, "", ""},
+	}
+	for _, tc := range tests {
+		s, _ := New(WithAccounts(accounts), UserName(tc.from))
+		user, privileges := "", ""
+		withUser := func(u string, p []string) http.Handler {
+			return http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				user, privileges = u, strings.Join(p, ",")
+			})
+		}
+		chained := false
+		chain := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { chained = true })
+
+		r := httptest.NewRequest("GET", "/redfish/v1", nil)
+		r.TLS = tc.tls
+		s.MakeHandlerFunc(withUser, chain)(httptest.NewRecorder(), r)
+		if user != tc.user || privileges != tc.privileges || chained != (tc.user == "") {
+			t.Errorf("%s: user %q, privileges %q, chained %v", tc.name, user, privileges, chained)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package plugins
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// WriteFileAtomic saves v as json to filename with the given permissions. It
+// is written to a temporary file first and renamed, so a crash never leaves
+// a half written file behind.
+func WriteFileAtomic(filename string, v interface{}, perm os.FileMode) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, perm); err != nil {
+		return err
+	}
+	// WriteFile doesn't change the mode of a file that is already there
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,49 @@
+package plugins
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "file.json")
+
+	// a file that is already there is replaced, permissions and all
+	if err := ioutil.WriteFile(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(filename, map[string]int{"a": 1}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode is %v", fi.Mode().Perm())
+	}
+	var v map[string]int
+	b, _ := ioutil.ReadFile(filename)
+	if err := json.Unmarshal(b, &v); err != nil || v["a"] != 1 {
+		t.Errorf("file is %s", b)
+	}
+	if _, err := os.Stat(filename + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file was left behind")
+	}
+
+	// nothing is written when v can't be encoded
+	if err := WriteFileAtomic(filename, func() {}, 0600); err == nil {
+		t.Errorf("no error")
+	}
+	if b2, _ := ioutil.ReadFile(filename); string(b2) != string(b) {
+		t.Errorf("file changed to %s", b2)
+	}
+}
@@ -47,5 +47,7 @@ func (s *Service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.E
 				"Name":           "Root Service",
 				"RedfishVersion": "1.0.2",
 				"UUID":           s.GetUUID(),
+				// required, the services fill it in as they start up
+				"Links": map[string]interface{}{},
 			}})
 }
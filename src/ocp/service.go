@@ -270,8 +270,9 @@ func PropGET(name string) MetaOption {
 
 func PropPATCH(name string) MetaOption {
 	return func(s *Service, m MetaInt) error {
-		s.MustPropertyUnlocked(name)
-		m["PATCH"] = map[string]interface{}{"plugin": string(s.PluginTypeUnlocked()), "property": name}
+		// the startup value is what PUT resets the property to when it's left out
+		def := s.MustPropertyUnlocked(name)
+		m["PATCH"] = map[string]interface{}{"plugin": string(s.PluginTypeUnlocked()), "property": name, "default": def}
 		return nil
 	}
 }
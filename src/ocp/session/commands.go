@@ -3,15 +3,13 @@ package session
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"math/rand"
+	"net"
 	"net/http"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	eh "github.com/looplab/eventhorizon"
-	"github.com/looplab/eventhorizon/utils"
 	domain "github.com/superchalupa/go-redfish/src/redfishresource"
 )
 
@@ -20,39 +18,33 @@ type LoginRequest struct {
 	Password string
 }
 
-// This is a fairly slow implementation, but should be good enough for our
-// purposes. This could be optimized to operate in about 1/5th of the time
-const characters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz1234567890"
-
-var moduleRand *rand.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
-
-func createRandSecret(length int, characters string) []byte {
-	b := make([]byte, length)
-	charLen := len(characters)
-	for i := range b {
-		b[i] = characters[moduleRand.Intn(charLen)]
-	}
-	return b
-}
-
 const (
-	POSTCommand = eh.CommandType("SessionService:POST")
+	POSTCommand   = eh.CommandType("SessionService:POST")
+	GETCommand    = eh.CommandType("SessionService:GET")
+	DELETECommand = eh.CommandType(sessionPlugin + ":DELETE")
+
+	// the Session resources find their DELETE command with this
+	sessionPlugin = "Session"
 )
 
 // HTTP POST Command
 type POST struct {
 	service        *Service
 	commandHandler eh.CommandHandler
-	eventWaiter    *utils.EventWaiter
 
 	ID      eh.UUID           `json:"id"`
 	CmdID   eh.UUID           `json:"cmdid"`
 	Headers map[string]string `eh:"optional"`
 	LR      LoginRequest
+
+	// where the login came from, for ClientOriginIPAddress
+	ClientAddress string `eh:"optional"`
 }
 
 // Static type checking for commands to prevent runtime errors due to typos
 var _ = eh.Command(&POST{})
+var _ = eh.Command(&GET{})
+var _ = eh.Command(&DELETE{})
 
 func (c *POST) AggregateType() eh.AggregateType { return domain.AggregateType }
 func (c *POST) AggregateID() eh.UUID            { return c.ID }
@@ -60,61 +52,84 @@ func (c *POST) CommandType() eh.CommandType     { return POSTCommand }
 func (c *POST) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *POST) SetCmdID(id eh.UUID)             { c.CmdID = id }
 func (c *POST) ParseHTTPRequest(r *http.Request) error {
-	return json.NewDecoder(r.Body).Decode(&c.LR)
+	c.ClientAddress = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		c.ClientAddress = host
+	}
+	return domain.DecodeJSONBody(r, &c.LR)
 }
 func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
-	privileges := []string{} // no privs
-
-	// hardcode some privileges for now
-	// step 1: validate username/password (PUNT FOR NOW)
-	// TODO: implement real pam here
-	// TODO: Look up the privileges
-	if c.LR.UserName == "Administrator" && c.LR.Password == "password" {
-		privileges = append(privileges,
-			"Unauthenticated", "tokenauth", "ConfigureSelf_"+c.LR.UserName,
-			// TODO: Actually look up privileges
-			"Login", "ConfigureManager", "ConfigureUsers", "ConfigureComponents",
-		)
-	} else if c.LR.UserName == "Operator" && c.LR.Password == "password" {
-		privileges = append(privileges,
-			"Unauthenticated", "tokenauth", "ConfigureSelf_"+c.LR.UserName,
-			// TODO: Actually look up privileges
-			"Login", "ConfigureComponents",
-		)
-	} else if c.LR.UserName == "ReadOnly" && c.LR.Password == "password" {
-		privileges = append(privileges,
-			"Unauthenticated", "tokenauth", "ConfigureSelf_"+c.LR.UserName,
-			// TODO: Actually look up privileges
-			"Login",
-		)
-	} else {
-		return errors.New("Could not verify username/password")
+	// step 1: validate username/password
+	privileges := []string{}
+	if c.service.auth != nil {
+		if userPrivileges, ok := c.service.auth.Authenticate(c.LR.UserName, c.LR.Password); ok {
+			privileges = append(privileges, "Unauthenticated", "tokenauth")
+			privileges = append(privileges, userPrivileges...)
+		}
+	}
+	if len(privileges) == 0 {
+		return domain.NewRedfishError(http.StatusUnauthorized, "ResourceAtUriUnauthorized", a.ResourceURI, "Could not verify username/password")
 	}
 
-	// step 2: Generate new session
+	// step 2: Generate new session, if there is room for it
 	sessionUUID := eh.NewUUID()
 	sessionURI := fmt.Sprintf("/redfish/v1/SessionService/Sessions/%s", sessionUUID)
+	now := time.Now()
+	if rerr := c.service.openSession(sessionURI, sessionUUID, c.LR.UserName, now); rerr != nil {
+		return rerr
+	}
+
+	key, err := c.service.keys.current()
+	if key == nil {
+		c.service.closeSession(sessionURI)
+		return err
+	}
+	if err != nil {
+		domain.ContextLogger(ctx, "session").Crit("Could not save the session keys", "err", err)
+	}
 
 	token := jwt.New(jwt.SigningMethodHS256)
+	token.Header["kid"] = key.ID
 	claims := make(jwt.MapClaims)
-	//claims["exp"] = time.Now().Add(time.Hour * time.Duration(1)).Unix()
-	claims["iat"] = time.Now().Unix()
+	claims["exp"] = now.Add(c.service.tokenLifetime).Unix()
+	claims["iat"] = now.Unix()
 	claims["iss"] = "localhost"
 	claims["sub"] = c.LR.UserName
 	claims["privileges"] = privileges
 	claims["sessionuri"] = sessionURI
 	token.Claims = claims
-	secret := SECRET
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := token.SignedString(key.Secret)
+	if err != nil {
+		c.service.closeSession(sessionURI)
+		return err
+	}
 
 	retprops := map[string]interface{}{
-		"@odata.type":    "#Session.v1_0_0.Session",
-		"@odata.id":      sessionURI,
-		"@odata.context": "/redfish/v1/$metadata#Session.Session",
-		"Id":             fmt.Sprintf("%s", sessionUUID),
-		"Name":           "User Session",
-		"Description":    "User Session",
-		"UserName":       c.LR.UserName,
+		"@odata.type":           "#Session.v1_3_0.Session",
+		"@odata.id":             sessionURI,
+		"@odata.context":        "/redfish/v1/$metadata#Session.Session",
+		"Id":                    fmt.Sprintf("%s", sessionUUID),
+		"Name":                  "User Session",
+		"Description":           "User Session",
+		"UserName":              c.LR.UserName,
+		"ClientOriginIPAddress": c.ClientAddress,
+		"CreatedTime":           now.UTC().Format(time.RFC3339),
+		"Oem": map[string]interface{}{
+			"OpenBMC": map[string]interface{}{"LastActivityTime": now.UTC().Format(time.RFC3339)},
+		},
+	}
+
+	// the same, but the last activity is kept up to date
+	properties := map[string]interface{}{}
+	for k, v := range retprops {
+		properties[k] = v
+	}
+	properties["Oem"] = map[string]interface{}{
+		"OpenBMC": map[string]interface{}{
+			"LastActivityTime@meta": map[string]interface{}{
+				"GET": map[string]interface{}{"plugin": string(SessionPlugin), "session": "LastActivityTime"},
+			},
+		},
 	}
 
 	err = c.commandHandler.HandleCommand(
@@ -125,21 +140,21 @@ func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) e
 			Type:        retprops["@odata.type"].(string),
 			Context:     retprops["@odata.context"].(string),
 			Privileges: map[string]interface{}{
-				"GET":    []string{"ConfigureManager"},
+				"GET":    []string{"ConfigureSelf_" + c.LR.UserName, "ConfigureManager"},
 				"POST":   []string{"ConfigureManager"},
 				"PUT":    []string{"ConfigureManager"},
 				"PATCH":  []string{"ConfigureManager"},
 				"DELETE": []string{"ConfigureSelf_" + c.LR.UserName, "ConfigureManager"},
 			},
-			Properties: retprops,
-			Private:    map[string]interface{}{"token_secret": secret},
+			Properties: properties,
+			Plugin:     sessionPlugin,
+			Deletable:  true,
 		})
 	if err != nil {
+		c.service.closeSession(sessionURI)
 		return err
 	}
 
-	c.startSessionDeleteTimer(sessionUUID, sessionURI, c.service.GetProperty("session_timeout").(int))
-
 	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.HTTPCmdProcessedData{
 		CommandID:  c.CmdID,
 		Results:    retprops,
@@ -152,63 +167,65 @@ func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) e
 	return nil
 }
 
-func (c *POST) startSessionDeleteTimer(sessionUUID eh.UUID, sessionURI string, timeout int) {
-	// all background stuff
-	ctx := context.Background()
+// DELETE of a Session is a logout, the token is revoked before the session
+// goes away so that it can't be used again even for a moment.
+type DELETE struct {
+	domain.DELETE
+	service *Service
+}
 
-	refreshListener, err := c.eventWaiter.Listen(ctx, func(event eh.Event) bool {
-		if event.EventType() != XAuthTokenRefreshEvent {
-			return false
-		}
-		if data, ok := event.Data().(XAuthTokenRefreshData); ok {
-			if data.SessionURI == sessionURI {
-				return true
-			}
+func (c *DELETE) CommandType() eh.CommandType { return DELETECommand }
+func (c *DELETE) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	c.service.revoke(a.ResourceURI)
+	c.service.closeSession(a.ResourceURI)
+	return c.DELETE.Handle(ctx, a)
+}
+
+// GET of the Sessions collection lists all of the sessions for users that can
+// manage them, and just their own sessions for everybody else.
+type GET struct {
+	domain.GET
+	service    *Service
+	userName   string
+	privileges []string
+}
+
+func (c *GET) CommandType() eh.CommandType { return GETCommand }
+func (c *GET) SetUserDetails(u string, privileges []string) string {
+	c.userName = u
+	c.privileges = privileges
+	return "checkMaster"
+}
+func (c *GET) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	for _, p := range c.privileges {
+		if p == "ConfigureManager" {
+			return c.GET.Handle(ctx, a)
 		}
-		return false
-	})
-	if err != nil {
-		// immediately expire session if we cannot create a listener
-		c.commandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: sessionUUID, ResourceURI: sessionURI})
-		return
 	}
 
-	deleteListener, err := c.eventWaiter.Listen(ctx, func(event eh.Event) bool {
-		if event.EventType() != domain.RedfishResourceRemoved {
-			return false
-		}
-		if data, ok := event.Data().(domain.RedfishResourceRemovedData); ok {
-			if data.ResourceURI == sessionURI {
-				return true
-			}
-		}
-		return false
-	})
+	results, _ := a.ProcessMeta(ctx, "GET", map[string]interface{}{})
+	collection := map[string]interface{}{}
+	b, err := json.Marshal(results)
+	if err == nil {
+		err = json.Unmarshal(b, &collection)
+	}
 	if err != nil {
-		// immediately expire session if we cannot create a listener
-		c.commandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: sessionUUID, ResourceURI: sessionURI})
-		refreshListener.Close()
-		return
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.NewErrorResponse(c.CmdID, domain.AsRedfishError(err, http.StatusInternalServerError)), time.Now()))
+		return nil
 	}
 
-	// start a background task to delete session after expiry
-	go func() {
-		defer deleteListener.Close()
-		defer refreshListener.Close()
-
-		// loop forever
-		for {
-			select {
-			case <-refreshListener.Inbox():
-				continue // still alive for now! start over again...
-			case <-deleteListener.Inbox():
-				return // it's gone, all done here
-			case <-time.After(time.Duration(timeout) * time.Second):
-				c.commandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: sessionUUID, ResourceURI: sessionURI})
-				return //exit goroutine
-			}
-		}
-	}()
+	members := []interface{}{}
+	for _, sessionURI := range c.service.sessionsOf(c.userName) {
+		members = append(members, map[string]interface{}{"@odata.id": sessionURI})
+	}
+	collection["Members"] = members
+	collection["Members@odata.count"] = len(members)
 
-	return
+	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, domain.HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		Results:    collection,
+		StatusCode: 200,
+		Headers:    a.GetHeaders(),
+	}, time.Now()))
+	return nil
 }
@@ -0,0 +1,187 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+// sessionInfo is what we keep about an open session, to count it against the
+// limits and to expire it once it hasn't been used for SessionTimeout seconds.
+type sessionInfo struct {
+	id           eh.UUID
+	userName     string
+	lastActivity time.Time
+}
+
+// openSession counts a new session against the limits and starts its expiry
+// clock. 0 for either limit means no limit.
+func (s *Service) openSession(sessionURI string, id eh.UUID, userName string, now time.Time) *domain.RedfishError {
+	maxSessions, _ := s.GetProperty("max_sessions").(int)
+	maxPerUser, _ := s.GetProperty("max_sessions_per_user").(int)
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	userSessions := 0
+	for _, info := range s.sessions {
+		if info.userName == userName {
+			userSessions++
+		}
+	}
+	if (maxSessions > 0 && len(s.sessions) >= maxSessions) || (maxPerUser > 0 && userSessions >= maxPerUser) {
+		return domain.NewRedfishError(http.StatusServiceUnavailable, "SessionLimitExceeded")
+	}
+
+	s.sessions[sessionURI] = &sessionInfo{id: id, userName: userName, lastActivity: now}
+	s.wakeExpiry()
+	return nil
+}
+
+// closeSession stops counting a session, it returns false if it wasn't open
+func (s *Service) closeSession(sessionURI string) bool {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	_, ok := s.sessions[sessionURI]
+	delete(s.sessions, sessionURI)
+	return ok
+}
+
+// touchSession restarts the expiry clock of a session
+func (s *Service) touchSession(sessionURI string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if info, ok := s.sessions[sessionURI]; ok {
+		info.lastActivity = time.Now()
+	}
+}
+
+func (s *Service) lastActivity(sessionURI string) (time.Time, bool) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	info, ok := s.sessions[sessionURI]
+	if !ok {
+		return time.Time{}, false
+	}
+	return info.lastActivity, true
+}
+
+// sessionsOf returns the URIs of the open sessions of the user
+func (s *Service) sessionsOf(userName string) []string {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	uris := []string{}
+	for uri, info := range s.sessions {
+		if info.userName == userName {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// wakeExpiry tells the expiry goroutine that the deadlines changed, it doesn't
+// wait for it to notice.
+func (s *Service) wakeExpiry() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takeExpired removes the sessions that are past the timeout from the table,
+// and returns how long until the next one is due.
+func (s *Service) takeExpired(now time.Time) (map[string]eh.UUID, time.Duration) {
+	timeout, _ := s.GetProperty("session_timeout").(int)
+	lifetime := time.Duration(timeout) * time.Second
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	expired := map[string]eh.UUID{}
+	// nothing to do until a session is opened
+	next := time.Hour
+	for uri, info := range s.sessions {
+		left := info.lastActivity.Add(lifetime).Sub(now)
+		if left <= 0 {
+			expired[uri] = info.id
+			delete(s.sessions, uri)
+			continue
+		}
+		if left < next {
+			next = left
+		}
+	}
+	return expired, next
+}
+
+// runExpiry is the one goroutine that expires all of the sessions. Requests
+// made with a session token restart its clock, and sessions that are removed
+// some other way (ie. DELETE) are forgotten.
+func (s *Service) runExpiry(ctx context.Context, ch eh.CommandHandler, l *utils.EventListener) {
+	defer l.Close()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-l.Inbox():
+			switch data := event.Data().(type) {
+			case XAuthTokenRefreshData:
+				s.touchSession(data.SessionURI)
+			case domain.RedfishResourceRemovedData:
+				s.closeSession(data.ResourceURI)
+			}
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		expired, next := s.takeExpired(time.Now())
+		for sessionURI, id := range expired {
+			s.revoke(sessionURI)
+			ch.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: id, ResourceURI: sessionURI})
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// startExpiry sets up the listener for runExpiry and starts it
+func (s *Service) startExpiry(ctx context.Context, ch eh.CommandHandler, ew *utils.EventWaiter) error {
+	l, err := ew.Listen(ctx, func(event eh.Event) bool {
+		switch event.EventType() {
+		case XAuthTokenRefreshEvent:
+			return true
+		case domain.RedfishResourceRemoved:
+			if data, ok := event.Data().(domain.RedfishResourceRemovedData); ok {
+				return s.isOpen(data.ResourceURI)
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	go s.runExpiry(ctx, ch, l)
+	return nil
+}
+
+func (s *Service) isOpen(sessionURI string) bool {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	_, ok := s.sessions[sessionURI]
+	return ok
+}
@@ -0,0 +1,175 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+// testCommandHandler keeps the sessions that were removed
+type testCommandHandler struct {
+	sync.Mutex
+	removed []string
+}
+
+func (h *testCommandHandler) HandleCommand(ctx context.Context, cmd eh.Command) error {
+	h.Lock()
+	defer h.Unlock()
+	if r, ok := cmd.(*domain.RemoveRedfishResource); ok {
+		h.removed = append(h.removed, r.ResourceURI)
+	}
+	return nil
+}
+
+func (h *testCommandHandler) removedURIs() []string {
+	h.Lock()
+	defer h.Unlock()
+	uris := append([]string{}, h.removed...)
+	sort.Strings(uris)
+	return uris
+}
+
+func sessionURI(i int) string {
+	return fmt.Sprintf("/redfish/v1/SessionService/Sessions/%d", i)
+}
+
+func TestSessionLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		max        int
+		maxPerUser int
+		users      []string
+		refused    string
+	}{
+		{"total", 3, 0, []string{"a", "b", "c"}, "d"},
+		{"per user", 0, 2, []string{"a", "a", "b"}, "a"},
+		{"both", 4, 2, []string{"a", "a", "b", "b"}, "c"},
+		{"no limit", 0, 0, []string{"a", "a", "a", "a", "a"}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := New()
+			s.UpdateProperty("max_sessions", tc.max)
+			s.UpdateProperty("max_sessions_per_user", tc.maxPerUser)
+			for i, user := range tc.users {
+				if rerr := s.openSession(sessionURI(i), eh.NewUUID(), user, time.Now()); rerr != nil {
+					t.Fatalf("session %d for %s refused", i, user)
+				}
+			}
+			if tc.refused == "" {
+				return
+			}
+			rerr := s.openSession(sessionURI(len(tc.users)), eh.NewUUID(), tc.refused, time.Now())
+			if rerr == nil {
+				t.Fatalf("session for %s over the limit", tc.refused)
+			}
+			if rerr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status %d", rerr.StatusCode)
+			}
+
+			// closing one makes room again
+			s.closeSession(sessionURI(0))
+			if rerr := s.openSession(sessionURI(len(tc.users)), eh.NewUUID(), tc.refused, time.Now()); rerr != nil {
+				t.Errorf("refused after a session was closed")
+			}
+		})
+	}
+}
+
+func TestSessionLimitBody(t *testing.T) {
+	s, _ := New()
+	s.UpdateProperty("max_sessions", 1)
+	s.openSession(sessionURI(1), eh.NewUUID(), "a", time.Now())
+	rerr := s.openSession(sessionURI(2), eh.NewUUID(), "b", time.Now())
+	if rerr == nil {
+		t.Fatalf("session over the limit")
+	}
+
+	b, _ := json.Marshal(rerr)
+	var body struct {
+		Error struct {
+			Code         string `json:"code"`
+			ExtendedInfo []struct {
+				MessageID string
+				Message   string
+				Severity  string
+			} `json:"@Message.ExtendedInfo"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Code != "Base.1.0.SessionLimitExceeded" || len(body.Error.ExtendedInfo) != 1 {
+		t.Fatalf("body %s", b)
+	}
+	ei := body.Error.ExtendedInfo[0]
+	if ei.MessageID != "Base.1.0.SessionLimitExceeded" || ei.Severity != "Critical" || ei.Message == "SessionLimitExceeded" {
+		t.Errorf("extended info %+v", ei)
+	}
+}
+
+func TestSessionsOf(t *testing.T) {
+	s, _ := New()
+	for i, user := range []string{"a", "b", "a"} {
+		s.openSession(sessionURI(i), eh.NewUUID(), user, time.Now())
+	}
+	if uris := s.sessionsOf("a"); !reflect.DeepEqual(uris, []string{sessionURI(0), sessionURI(2)}) {
+		t.Errorf("sessions of a: %v", uris)
+	}
+	if uris := s.sessionsOf("c"); len(uris) != 0 {
+		t.Errorf("sessions of c: %v", uris)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s, _ := New()
+	s.UpdateProperty("session_timeout", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ew := utils.NewEventWaiter()
+	ch := &testCommandHandler{}
+	if err := s.startExpiry(ctx, ch, ew); err != nil {
+		t.Fatal(err)
+	}
+
+	// one goroutine expires all of them, the one that is used is kept
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		s.openSession(sessionURI(i), eh.NewUUID(), "a", start)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) && len(ch.removedURIs()) < 3 {
+		ew.Notify(ctx, eh.NewEvent(XAuthTokenRefreshEvent, XAuthTokenRefreshData{SessionURI: sessionURI(3)}, time.Now()))
+		time.Sleep(100 * time.Millisecond)
+	}
+	if removed := ch.removedURIs(); !reflect.DeepEqual(removed, []string{sessionURI(0), sessionURI(1), sessionURI(2)}) {
+		t.Fatalf("removed %v", removed)
+	}
+	for i := 0; i < 3; i++ {
+		if s.isOpen(sessionURI(i)) || !s.isRevoked(sessionURI(i)) {
+			t.Errorf("%s is still open or not revoked", sessionURI(i))
+		}
+	}
+	if !s.isOpen(sessionURI(3)) {
+		t.Errorf("the session that was used expired")
+	}
+
+	// sessions that are removed some other way are forgotten
+	ew.Notify(ctx, eh.NewEvent(domain.RedfishResourceRemoved, domain.RedfishResourceRemovedData{ResourceURI: sessionURI(3)}, time.Now()))
+	for time.Now().Before(deadline) && s.isOpen(sessionURI(3)) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.isOpen(sessionURI(3)) {
+		t.Errorf("a removed session is still open")
+	}
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	plugins "github.com/superchalupa/go-redfish/src/ocp"
+)
+
+// signingKey is an HMAC key for X-Auth-Tokens, found by the "kid" in the
+// token header. Retired keys can still check tokens for the grace period.
+type signingKey struct {
+	ID      string    `json:"kid"`
+	Secret  []byte    `json:"secret"`
+	Created time.Time `json:"created"`
+	Retired time.Time `json:"retired"`
+}
+
+// keyManager keeps the signing keys, saved to a file that only we can read so
+// that tokens still check out after a restart. The last key is the one that
+// new tokens are signed with.
+type keyManager struct {
+	sync.Mutex
+	filename string
+	rotation time.Duration // 0 never rotates
+	grace    time.Duration
+	keys     []*signingKey
+}
+
+func loadKeys(filename string) (*keyManager, error) {
+	k := &keyManager{filename: filename}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &k.keys); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// save writes the keys out, already locked when we get here. The file is
+// written next to the real one and renamed over it so that it is never half
+// written.
+func (k *keyManager) save() error {
+	if k.filename == "" {
+		return nil
+	}
+	return plugins.WriteFileAtomic(k.filename, k.keys, 0600)
+}
+
+func newSigningKey() (*signingKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &signingKey{ID: hex.EncodeToString(id), Secret: secret, Created: time.Now()}, nil
+}
+
+// current returns the key to sign new tokens with, making a new one first if
+// there isn't one or it's due to be rotated.
+func (k *keyManager) current() (*signingKey, error) {
+	k.Lock()
+	defer k.Unlock()
+	if len(k.keys) > 0 {
+		cur := k.keys[len(k.keys)-1]
+		if k.rotation == 0 || time.Since(cur.Created) < k.rotation {
+			return cur, nil
+		}
+	}
+	return k.rotateUnlocked()
+}
+
+// rotateUnlocked replaces the current key, tokens signed with the old one
+// are good for the grace period.
+func (k *keyManager) rotateUnlocked() (*signingKey, error) {
+	key, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	keys := []*signingKey{}
+	for _, old := range k.keys {
+		if old.Retired.IsZero() {
+			old.Retired = now
+		}
+		if now.Sub(old.Retired) < k.grace {
+			keys = append(keys, old)
+		}
+	}
+	k.keys = append(keys, key)
+
+	// a key that isn't saved still works until we restart
+	return key, k.save()
+}
+
+// lookup returns the secret for the kid from a token header
+func (k *keyManager) lookup(kid string) ([]byte, bool) {
+	k.Lock()
+	defer k.Unlock()
+	for _, key := range k.keys {
+		if key.ID != kid {
+			continue
+		}
+		if !key.Retired.IsZero() && time.Since(key.Retired) >= k.grace {
+			return nil, false
+		}
+		return key.Secret, true
+	}
+	return nil, false
+}
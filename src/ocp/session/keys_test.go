@@ -0,0 +1,108 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "keys.json")
+
+	k, err := loadKeys(filename)
+	if err != nil || len(k.keys) != 0 {
+		t.Fatalf("no key file: %v %v", k.keys, err)
+	}
+	k.rotation = time.Hour
+	k.grace = time.Hour
+
+	first, err := k.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := k.current(); again != first {
+		t.Errorf("rotated a new key")
+	}
+
+	// the first key is due, tokens signed with it are good for the grace
+	first.Created = time.Now().Add(-2 * time.Hour)
+	second, err := k.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || first.Retired.IsZero() {
+		t.Fatalf("didn't rotate: %v", first)
+	}
+	for _, key := range []*signingKey{first, second} {
+		if secret, ok := k.lookup(key.ID); !ok || string(secret) != string(key.Secret) {
+			t.Errorf("lookup %s: %v", key.ID, ok)
+		}
+	}
+	if _, ok := k.lookup("nope"); ok {
+		t.Errorf("found an unknown kid")
+	}
+
+	// and not after it
+	first.Retired = time.Now().Add(-2 * time.Hour)
+	if _, ok := k.lookup(first.ID); ok {
+		t.Errorf("found a key past the grace")
+	}
+
+	// a restart finds the same keys, in a file only we can read
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("key file mode %v", fi.Mode())
+	}
+	loaded, err := loadKeys(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.keys) != 2 || loaded.keys[1].ID != second.ID || string(loaded.keys[1].Secret) != string(second.Secret) {
+		t.Errorf("loaded %v", loaded.keys)
+	}
+
+	// keys past the grace are dropped the next time round
+	second.Created = time.Now().Add(-2 * time.Hour)
+	third, err := k.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k.keys) != 2 || k.keys[0] != second || k.keys[1] != third {
+		t.Errorf("keys after the third rotation %v", k.keys)
+	}
+}
+
+func TestKeyNoRotation(t *testing.T) {
+	k := &keyManager{grace: time.Hour}
+	first, err := k.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Created = time.Now().Add(-1000 * time.Hour)
+	if again, _ := k.current(); again != first {
+		t.Errorf("rotated with a rotation of 0")
+	}
+}
+
+func TestLoadKeysBadFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("{")
+	f.Close()
+	if _, err := loadKeys(f.Name()); err == nil {
+		t.Errorf("loaded a broken key file")
+	}
+}
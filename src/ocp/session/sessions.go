@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/superchalupa/go-redfish/src/log"
 	plugins "github.com/superchalupa/go-redfish/src/ocp"
 	domain "github.com/superchalupa/go-redfish/src/redfishresource"
 
@@ -14,8 +16,6 @@ import (
 	"github.com/looplab/eventhorizon/utils"
 )
 
-var SECRET []byte = []byte("happyhappyjoyjoy1234")
-
 type IDGetter interface {
 	HasAggregateID(string) bool
 }
@@ -31,6 +31,22 @@ type uuidObj interface {
 type Service struct {
 	*plugins.Service
 	root uuidObj
+	auth plugins.Authenticator
+
+	keys          *keyManager
+	keyFile       string
+	keyRotation   time.Duration
+	keyGrace      time.Duration
+	tokenLifetime time.Duration
+
+	// sessions that were deleted, until their tokens expire
+	revokedMu sync.Mutex
+	revoked   map[string]time.Time
+
+	// open sessions by URI, they are all expired by one goroutine
+	sessionsMu sync.Mutex
+	sessions   map[string]*sessionInfo
+	wake       chan struct{}
 }
 
 type RedfishClaims struct {
@@ -40,11 +56,6 @@ type RedfishClaims struct {
 	jwt.StandardClaims
 }
 
-func init() {
-	// setup module secret
-	SECRET = createRandSecret(24, characters)
-}
-
 func (a *Service) MakeHandlerFunc(eb eh.EventBus, getter IDGetter, withUser func(string, []string) http.Handler, chain http.Handler) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
 		var userName string
@@ -52,17 +63,34 @@ func (a *Service) MakeHandlerFunc(eb eh.EventBus, getter IDGetter, withUser func
 
 		xauthtoken := req.Header.Get("X-Auth-Token")
 		if xauthtoken != "" {
-			token, _ := jwt.ParseWithClaims(xauthtoken, &RedfishClaims{}, func(token *jwt.Token) (interface{}, error) {
+			token, err := jwt.ParseWithClaims(xauthtoken, &RedfishClaims{}, func(token *jwt.Token) (interface{}, error) {
 				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 				}
-				return SECRET, nil
+				kid, _ := token.Header["kid"].(string)
+				if secret, ok := a.keys.lookup(kid); ok {
+					return secret, nil
+				}
+				return nil, fmt.Errorf("Unknown signing key: %v", kid)
 			})
 
-			if claims, ok := token.Claims.(*RedfishClaims); ok && token.Valid {
+			var claims *RedfishClaims
+			if err == nil && token.Valid {
+				claims, _ = token.Claims.(*RedfishClaims)
+			}
+			// all of our tokens expire
+			if claims != nil && claims.VerifyExpiresAt(time.Now().Unix(), true) && !a.isRevoked(claims.SessionURI) {
 				if getter.HasAggregateID(claims.SessionURI) {
 					userName = claims.UserName
 					privileges = claims.Privileges
+					// look the privileges up again so that changes to the
+					// account or its role apply to sessions right away
+					if pg, ok := a.auth.(plugins.PrivilegeGetter); ok {
+						privileges = nil
+						if userPrivileges, ok := pg.Privileges(userName); ok {
+							privileges = append([]string{"Unauthenticated", "tokenauth"}, userPrivileges...)
+						}
+					}
 					eb.PublishEvent(context.Background(), eh.NewEvent(XAuthTokenRefreshEvent, XAuthTokenRefreshData{SessionURI: claims.SessionURI}, time.Now()))
 				}
 			}
@@ -79,7 +107,13 @@ func (a *Service) MakeHandlerFunc(eb eh.EventBus, getter IDGetter, withUser func
 
 func New(options ...interface{}) (*Service, error) {
 	s := &Service{
-		Service: plugins.NewService(plugins.PluginType(SessionPlugin)),
+		Service:       plugins.NewService(plugins.PluginType(SessionPlugin)),
+		keyRotation:   24 * time.Hour,
+		keyGrace:      24 * time.Hour,
+		tokenLifetime: 24 * time.Hour,
+		revoked:       map[string]time.Time{},
+		sessions:      map[string]*sessionInfo{},
+		wake:          make(chan struct{}, 1),
 	}
 
 	// defaults
@@ -97,6 +131,10 @@ func New(options ...interface{}) (*Service, error) {
 			}
 		})
 
+	// 0 for no limit
+	s.UpdatePropertyUnlocked("max_sessions", 64)
+	s.UpdatePropertyUnlocked("max_sessions_per_user", 8)
+
 	s.ApplyOption(plugins.UUID())
 	s.ApplyOption(options...)
 	return s, nil
@@ -109,12 +147,102 @@ func Root(obj uuidObj) Option {
 	}
 }
 
+// WithAuthenticator is what checks the username and password on login
+func WithAuthenticator(auth plugins.Authenticator) Option {
+	return func(s *Service) error {
+		s.auth = auth
+		return nil
+	}
+}
+
+// KeyFile is where the token signing keys are saved
+func KeyFile(filename string) Option {
+	return func(s *Service) error {
+		s.keyFile = filename
+		return nil
+	}
+}
+
+// KeyRotation is how often a new signing key is made, 0 for never
+func KeyRotation(d time.Duration) Option {
+	return func(s *Service) error {
+		s.keyRotation = d
+		return nil
+	}
+}
+
+// KeyGrace is how long tokens signed with a key are still good after it is
+// rotated. Anything shorter than the TokenLifetime cuts tokens short.
+func KeyGrace(d time.Duration) Option {
+	return func(s *Service) error {
+		s.keyGrace = d
+		return nil
+	}
+}
+
+// TokenLifetime is when X-Auth-Tokens expire, even if the session is in use
+func TokenLifetime(d time.Duration) Option {
+	return func(s *Service) error {
+		s.tokenLifetime = d
+		return nil
+	}
+}
+
+// revoke makes the token for a session useless right away, rather than when
+// the session is noticed to be gone.
+func (s *Service) revoke(sessionURI string) {
+	s.revokedMu.Lock()
+	defer s.revokedMu.Unlock()
+	now := time.Now()
+	for uri, expires := range s.revoked {
+		if now.After(expires) {
+			delete(s.revoked, uri)
+		}
+	}
+	s.revoked[sessionURI] = now.Add(s.tokenLifetime)
+}
+
+func (s *Service) isRevoked(sessionURI string) bool {
+	s.revokedMu.Lock()
+	defer s.revokedMu.Unlock()
+	_, ok := s.revoked[sessionURI]
+	return ok
+}
+
+// PropertyGet fills in the last activity of a session, anything else is a
+// property of the service
+func (s *Service) PropertyGet(ctx context.Context, agg *domain.RedfishResourceAggregate, rrp *domain.RedfishResourceProperty, method string, meta map[string]interface{}) {
+	if _, ok := meta["session"].(string); !ok {
+		s.Service.PropertyGet(ctx, agg, rrp, method, meta)
+		return
+	}
+	if last, ok := s.lastActivity(agg.ResourceURI); ok {
+		rrp.Value = last.UTC().Format(time.RFC3339)
+	}
+}
+
 func (s *Service) Root(obj uuidObj) {
 	s.ApplyOption(Root(obj))
 }
 
 func (s *Service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) {
-	eh.RegisterCommand(func() eh.Command { return &POST{service: s, commandHandler: ch, eventWaiter: ew} })
+	var err error
+	s.keys, err = loadKeys(s.keyFile)
+	if err != nil {
+		// don't save over a file that we couldn't read, keep the keys in memory
+		log.MustLogger("session").Crit("Could not load session keys, new keys will not be saved", "file", s.keyFile, "err", err)
+		s.keys = &keyManager{}
+	}
+	s.keys.rotation = s.keyRotation
+	s.keys.grace = s.keyGrace
+
+	if err := s.startExpiry(ctx, ch, ew); err != nil {
+		log.MustLogger("session").Crit("Could not start the session expiry, sessions will not time out", "err", err)
+	}
+
+	eh.RegisterCommand(func() eh.Command { return &POST{service: s, commandHandler: ch} })
+	eh.RegisterCommand(func() eh.Command { return &GET{service: s} })
+	eh.RegisterCommand(func() eh.Command { return &DELETE{service: s} })
 
 	// Create SessionService aggregate
 	ch.HandleCommand(
@@ -159,8 +287,9 @@ func (s *Service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.E
 			ResourceURI: "/redfish/v1/SessionService/Sessions",
 			Type:        "#SessionCollection.SessionCollection",
 			Context:     "/redfish/v1/$metadata#SessionCollection.SessionCollection",
+			// users that can't manage the sessions only see their own
 			Privileges: map[string]interface{}{
-				"GET":    []string{"ConfigureManager"},
+				"GET":    []string{"Login"},
 				"POST":   []string{"Unauthenticated"},
 				"PUT":    []string{"ConfigureManager"},
 				"PATCH":  []string{"ConfigureManager"},
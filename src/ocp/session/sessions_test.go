@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	eh "github.com/looplab/eventhorizon"
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+)
+
+type testEventBus struct{ events []eh.Event }
+
+func (b *testEventBus) PublishEvent(ctx context.Context, e eh.Event) error {
+	b.events = append(b.events, e)
+	return nil
+}
+func (b *testEventBus) AddHandler(eh.EventMatcher, eh.EventHandler) {}
+
+type testGetter map[string]bool
+
+func (g testGetter) HasAggregateID(uri string) bool { return g[uri] }
+
+// testToken signs a token the way a login does
+func testToken(t *testing.T, key *signingKey, sessionURI string, exp time.Time) string {
+	token := jwt.New(jwt.SigningMethodHS256)
+	token.Header["kid"] = key.ID
+	token.Claims = jwt.MapClaims{
+		"exp":        exp.Unix(),
+		"sub":        "admin",
+		"privileges": []string{"Unauthenticated", "tokenauth", "Login"},
+		"sessionuri": sessionURI,
+	}
+	s, err := token.SignedString(key.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// authenticated returns the user the token is accepted for, if any
+func authenticated(s *Service, getter IDGetter, token string) string {
+	user := ""
+	withUser := func(u string, privileges []string) http.Handler {
+		return http.HandlerFunc(func(http.ResponseWriter, *http.Request) { user = u })
+	}
+	h := s.MakeHandlerFunc(&testEventBus{}, getter, withUser, http.NotFoundHandler())
+	r := httptest.NewRequest("GET", "/redfish/v1", nil)
+	r.Header.Set("X-Auth-Token", token)
+	h(httptest.NewRecorder(), r)
+	return user
+}
+
+func TestTokens(t *testing.T) {
+	s, _ := New()
+	s.keys = &keyManager{rotation: time.Hour, grace: time.Hour}
+	key, err := s.keys.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := newSigningKey()
+	uri := "/redfish/v1/SessionService/Sessions/1"
+	getter := testGetter{uri: true}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token string
+		user  string
+	}{
+		{"good", testToken(t, key, uri, later), "admin"},
+		{"expired", testToken(t, key, uri, time.Now().Add(-time.Minute)), ""},
+		{"unknown key", testToken(t, other, uri, later), ""},
+		{"wrong secret", testToken(t, &signingKey{ID: key.ID, Secret: other.Secret}, uri, later), ""},
+		{"no session", testToken(t, key, "/redfish/v1/SessionService/Sessions/2", later), ""},
+		{"garbage", "x.y.z", ""},
+	}
+	for _, tc := range tests {
+		if user := authenticated(s, getter, tc.token); user != tc.user {
+			t.Errorf("%s: user %q, expected %q", tc.name, user, tc.user)
+		}
+	}
+
+	// tokens from before a rotation are good until the grace is up
+	token := testToken(t, key, uri, later)
+	key.Created = time.Now().Add(-2 * time.Hour)
+	if _, err := s.keys.current(); err != nil {
+		t.Fatal(err)
+	}
+	if user := authenticated(s, getter, token); user != "admin" {
+		t.Errorf("token refused after a rotation")
+	}
+	key.Retired = time.Now().Add(-2 * time.Hour)
+	if user := authenticated(s, getter, token); user != "" {
+		t.Errorf("token accepted after the grace")
+	}
+}
+
+func TestDeleteRevokes(t *testing.T) {
+	s, _ := New()
+	s.keys = &keyManager{grace: time.Hour}
+	key, err := s.keys.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := "/redfish/v1/SessionService/Sessions/1"
+	if rerr := s.openSession(uri, eh.NewUUID(), "admin", time.Now()); rerr != nil {
+		t.Fatal(rerr)
+	}
+	// the session resource is still there while the DELETE is handled
+	getter := testGetter{uri: true}
+	token := testToken(t, key, uri, time.Now().Add(time.Hour))
+	if user := authenticated(s, getter, token); user != "admin" {
+		t.Fatalf("token refused before the DELETE")
+	}
+
+	a := &domain.RedfishResourceAggregate{ResourceURI: uri, Deletable: true}
+	c := &DELETE{service: s}
+	if err := c.Handle(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if !s.isRevoked(uri) || s.isOpen(uri) {
+		t.Errorf("after the DELETE revoked %v, open %v", s.isRevoked(uri), s.isOpen(uri))
+	}
+	if user := authenticated(s, getter, token); user != "" {
+		t.Errorf("token accepted after the DELETE")
+	}
+	removed := false
+	for _, e := range a.EventsToPublish() {
+		removed = removed || e.EventType() == domain.RedfishResourceRemoved
+	}
+	if !removed {
+		t.Errorf("the session resource wasn't removed")
+	}
+
+	// other sessions aren't affected
+	other := strings.Replace(uri, "/1", "/2", 1)
+	getter[other] = true
+	if user := authenticated(s, getter, testToken(t, key, other, time.Now().Add(time.Hour))); user != "admin" {
+		t.Errorf("another session's token refused")
+	}
+}
+
+func TestRevokeForgets(t *testing.T) {
+	s, _ := New(TokenLifetime(time.Hour))
+	s.revoke("/old")
+	s.revoked["/old"] = time.Now().Add(-time.Minute)
+	s.revoke("/new")
+	if s.isRevoked("/old") || !s.isRevoked("/new") {
+		t.Errorf("revoked %v", s.revoked)
+	}
+}
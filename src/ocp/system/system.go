@@ -2,6 +2,7 @@ package system
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/superchalupa/go-redfish/src/log"
@@ -34,6 +35,13 @@ func New(options ...interface{}) (*service, error) {
 		Service: plugins.NewService(plugins.PluginType(OBMC_SystemPlugin)),
 	}
 	s.ApplyOption(plugins.UUID())
+	// boot override defaults, PATCH changes them
+	s.ApplyOption(
+		plugins.UpdateProperty("boot_source_override_enabled", "Once"),
+		plugins.UpdateProperty("boot_source_override_mode", "UEFI"),
+		plugins.UpdateProperty("boot_source_override_target", "Pxe"),
+		plugins.UpdateProperty("uefi_target_boot_source_override", "uefiDevicePath"),
+	)
 	s.ApplyOption(options...)
 	s.ApplyOption(plugins.PropertyOnce("uri", "/redfish/v1/Systems/"+s.GetProperty("unique_name").(string)))
 	return s, nil
@@ -108,10 +116,19 @@ func (s *service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.E
 					"Health": "OK",
 				},
 				"Boot": map[string]interface{}{
-					"BootSourceOverrideEnabled":    "Once",
-					"BootSourceOverrideMode":       "UEFI",
-					"UefiTargetBootSourceOverride": "uefiDevicePath",
-					"BootSourceOverrideTarget":     "Pxe",
+					"BootSourceOverrideEnabled@meta":    s.Meta(plugins.PropGET("boot_source_override_enabled"), plugins.PropPATCH("boot_source_override_enabled")),
+					"BootSourceOverrideMode@meta":       s.Meta(plugins.PropGET("boot_source_override_mode"), plugins.PropPATCH("boot_source_override_mode")),
+					"UefiTargetBootSourceOverride@meta": s.Meta(plugins.PropGET("uefi_target_boot_source_override"), plugins.PropPATCH("uefi_target_boot_source_override")),
+					"BootSourceOverrideTarget@meta":     s.Meta(plugins.PropGET("boot_source_override_target"), plugins.PropPATCH("boot_source_override_target")),
+					"BootSourceOverrideEnabled@Redfish.AllowableValues": []string{
+						"Disabled",
+						"Once",
+						"Continuous",
+					},
+					"BootSourceOverrideMode@Redfish.AllowableValues": []string{
+						"Legacy",
+						"UEFI",
+					},
 					"BootSourceOverrideTarget@Redfish.AllowableValues": []string{
 						"None",
 						"Pxe",
@@ -165,12 +182,11 @@ func (s *service) AddResource(ctx context.Context, ch eh.CommandHandler, eb eh.E
 	sp.RunForever(func(event eh.Event) {
 		log.MustLogger("ocp_bmc").Info("Got action event", "event", event)
 
-		eventData := domain.HTTPCmdProcessedData{
-			CommandID:  event.Data().(ah.GenericActionEventData).CmdID,
-			Results:    map[string]interface{}{"msg": "Not Implemented"},
-			StatusCode: 500,
-			Headers:    map[string]string{},
-		}
+		// default response if nobody has registered a handler for the action
+		eventData := domain.NewErrorResponse(
+			event.Data().(ah.GenericActionEventData).CmdID,
+			domain.NewRedfishError(http.StatusInternalServerError, "ActionNotSupported", "ComputerSystem.Reset"),
+		)
 
 		handler := s.GetProperty("computersystem.reset")
 		if handler != nil {
@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"sync"
 
@@ -89,6 +90,8 @@ type RedfishResourceAggregate struct {
 	ID          eh.UUID
 	ResourceURI string
 	Plugin      string
+	Collection  bool
+	Deletable   bool
 
 	propertiesMu sync.RWMutex
 	properties   RedfishResourceProperty
@@ -97,6 +100,11 @@ type RedfishResourceAggregate struct {
 	// above so that everything can be properly locked
 	PrivilegeMap map[string]interface{}
 	Headers      map[string]string
+
+	// incremented on every change, see ETag()
+	version uint64
+	// serializes commands that are conditional on the ETag
+	conditionalMu sync.Mutex
 }
 
 // PublishEvent registers an event to be published after the aggregate
@@ -152,6 +160,14 @@ type RRCmdHandler interface {
 }
 
 func (a *RedfishResourceAggregate) HandleCommand(ctx context.Context, command eh.Command) error {
+	if ifMatch, ok := ifMatchFromContext(ctx); ok {
+		a.conditionalMu.Lock()
+		defer a.conditionalMu.Unlock()
+		if err := a.checkIfMatch(ifMatch); err != nil {
+			return err
+		}
+	}
+
 	switch command := command.(type) {
 	case RRCmdHandler:
 		return command.Handle(ctx, a)
@@ -185,6 +201,7 @@ func (r *RedfishResourceAggregate) SetProperty(p string, n interface{}) {
 	}
 	rrp.Value = n
 	v[p] = rrp
+	r.bumpVersion()
 }
 
 func (r *RedfishResourceAggregate) DeleteProperty(p string) {
@@ -194,6 +211,7 @@ func (r *RedfishResourceAggregate) DeleteProperty(p string) {
 	// new hotness
 	v := r.properties.Value.(map[string]interface{})
 	delete(v, p)
+	r.bumpVersion()
 }
 
 func (r *RedfishResourceAggregate) EnsureCollection() {
@@ -264,6 +282,31 @@ func (r *RedfishResourceAggregate) RemoveCollectionMember(uri string) {
 	r.UpdateCollectionMemberCount_unlocked()
 }
 
+// IsDeletable is true for resources that were created deletable. Collections
+// can never be deleted.
+func (r *RedfishResourceAggregate) IsDeletable() bool {
+	return r.Deletable && !r.Collection
+}
+
+// collectionMembers returns the uris of the collection members
+func (r *RedfishResourceAggregate) collectionMembers() []string {
+	r.propertiesMu.RLock()
+	defer r.propertiesMu.RUnlock()
+
+	ret := []string{}
+	props, _ := r.properties.Value.(map[string]interface{})
+	members, _ := props["Members"].(RedfishResourceProperty)
+	arr, _ := members.Value.([]map[string]interface{})
+	for _, v := range arr {
+		if rrp, ok := v["@odata.id"].(RedfishResourceProperty); ok {
+			if uri, ok := rrp.Value.(string); ok {
+				ret = append(ret, uri)
+			}
+		}
+	}
+	return ret
+}
+
 func (r *RedfishResourceAggregate) UpdateCollectionMemberCount() {
 	r.propertiesMu.Lock()
 	defer r.propertiesMu.Unlock()
@@ -275,6 +318,7 @@ func (r *RedfishResourceAggregate) UpdateCollectionMemberCount_unlocked() {
 	l := len(members.Value.([]map[string]interface{}))
 	m := r.properties.Value.(map[string]interface{})
 	m["Members@odata.count"] = RedfishResourceProperty{Value: l}
+	r.bumpVersion()
 }
 
 type PropertyGetter interface {
@@ -337,16 +381,26 @@ func (rrp *RedfishResourceProperty) Process(ctx context.Context, agg *RedfishRes
 			result interface{}
 		}
 		reqmap, _ := req.(map[string]interface{})
+		sel := selectorFromContext(ctx)
 		var promised []chan result
 		for property, v := range ret.Value.(map[string]interface{}) {
 			resChan := make(chan result)
 			promised = append(promised, resChan)
-			if vrr, ok := v.(RedfishResourceProperty); ok {
-				go func(property string, v RedfishResourceProperty) {
+			vrr, ok := v.(RedfishResourceProperty)
+			subCtx := ctx
+			if ok && sel != nil {
+				// properties that weren't asked for are passed through as-is
+				// so that their plugins don't run
+				selected, sub := sel.child(property, &vrr)
+				ok = selected
+				subCtx = withPropertySelector(ctx, sub)
+			}
+			if ok {
+				go func(ctx context.Context, property string, v RedfishResourceProperty) {
 					reqitem, ok := reqmap[property]
 					retProp := v.Process(ctx, agg, property, method, reqitem, ok)
 					resChan <- result{property, retProp}
-				}(property, vrr)
+				}(subCtx, property, vrr)
 			} else {
 				go func(property string, v interface{}) {
 					resChan <- result{property, v}
@@ -402,6 +456,12 @@ func (agg *RedfishResourceAggregate) ProcessMeta(ctx context.Context, method str
 	defer agg.propertiesMu.Unlock()
 
 	results = agg.properties.Process(ctx, agg, "", method, request, true)
+
+	// GET only changes the version if a plugin actually changed something,
+	// otherwise If-None-Match would never match
+	if method != "GET" || !reflect.DeepEqual(agg.properties, results) {
+		agg.bumpVersion()
+	}
 	agg.properties = results.(RedfishResourceProperty)
 
 	return
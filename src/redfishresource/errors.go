@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/superchalupa/go-redfish/src/registries"
+)
+
+// MessageRegistryPrefix is the registry name and version that unqualified
+// message IDs (ie. "PropertyUnknown") are in.
+const MessageRegistryPrefix = "Base.1.0"
+
+// ResourceEventRegistryPrefix is the registry of the messages that say a
+// request created, changed or removed a resource.
+const ResourceEventRegistryPrefix = "ResourceEvent.1.0"
+
+// ExtendedInfo is a single entry of the @Message.ExtendedInfo array. Only the
+// message ID and arguments are stored, the text is filled in from the
+// registry when the message is rendered.
+type ExtendedInfo struct {
+	// Base registry messages don't need to be qualified, messages from other
+	// registries (ie. OEM ones) are qualified: "OpenBMC.1.0.Foo"
+	MessageID         string
+	MessageArgs       []string
+	RelatedProperties []string
+
+	// the Accept-Language of the request that the message is rendered for
+	Language string
+}
+
+// NewExtendedInfo builds an ExtendedInfo for the given registry message,
+// stringifying the arguments.
+func NewExtendedInfo(messageID string, args ...interface{}) ExtendedInfo {
+	ei := ExtendedInfo{MessageID: messageID, MessageArgs: []string{}}
+	for _, a := range args {
+		ei.MessageArgs = append(ei.MessageArgs, fmt.Sprint(a))
+	}
+	return ei
+}
+
+// WithRelatedProperties returns a copy of the message that points at the
+// given properties (json pointer syntax, ie. "#/Boot/BootSourceOverrideTarget")
+func (ei ExtendedInfo) WithRelatedProperties(props ...string) ExtendedInfo {
+	ei.RelatedProperties = append([]string{}, props...)
+	return ei
+}
+
+// WithLanguage returns a copy of the message that renders in the best
+// language for the Accept-Language header.
+func (ei ExtendedInfo) WithLanguage(acceptLanguage string) ExtendedInfo {
+	ei.Language = acceptLanguage
+	return ei
+}
+
+// QualifiedID returns the message ID with the registry it is from
+func (ei ExtendedInfo) QualifiedID() string {
+	if strings.Contains(ei.MessageID, ".") {
+		return ei.MessageID
+	}
+	return MessageRegistryPrefix + "." + ei.MessageID
+}
+
+func (ei ExtendedInfo) format() registries.Formatted {
+	f, _ := registries.Format(ei.QualifiedID(), ei.Language, ei.MessageArgs...)
+	return f
+}
+
+// Message returns the message text with the arguments substituted.
+func (ei ExtendedInfo) Message() string {
+	return ei.format().Message
+}
+
+// MarshalJSON renders the message as a #Message.v1_0_0.Message object.
+func (ei ExtendedInfo) MarshalJSON() ([]byte, error) {
+	f := ei.format()
+	ret := map[string]interface{}{
+		"@odata.type": "#Message.v1_0_0.Message",
+		"MessageId":   f.MessageID,
+		"Message":     f.Message,
+		"MessageArgs": f.MessageArgs,
+		"Severity":    f.Severity,
+		"Resolution":  f.Resolution,
+	}
+	if len(ei.RelatedProperties) > 0 {
+		ret["RelatedProperties"] = ei.RelatedProperties
+	}
+	return json.Marshal(ret)
+}
+
+// localizeMessages returns a copy of the messages that render in the best
+// language for the Accept-Language header.
+func localizeMessages(messages []ExtendedInfo, acceptLanguage string) []ExtendedInfo {
+	ret := make([]ExtendedInfo, 0, len(messages))
+	for _, ei := range messages {
+		ret = append(ret, ei.WithLanguage(acceptLanguage))
+	}
+	return ret
+}
+
+// RedfishError is an error that knows the http status code to return along
+// with the Base registry messages that describe it. It renders as a standard
+// redfish error response body.
+type RedfishError struct {
+	StatusCode   int
+	ExtendedInfo []ExtendedInfo
+}
+
+// NewRedfishError constructs a RedfishError with a single message.
+func NewRedfishError(statusCode int, messageID string, args ...interface{}) *RedfishError {
+	return &RedfishError{
+		StatusCode:   statusCode,
+		ExtendedInfo: []ExtendedInfo{NewExtendedInfo(messageID, args...)},
+	}
+}
+
+// WithLanguage returns a copy of the error with the messages rendered in the
+// best language for the Accept-Language header.
+func (e *RedfishError) WithLanguage(acceptLanguage string) *RedfishError {
+	return &RedfishError{StatusCode: e.StatusCode, ExtendedInfo: localizeMessages(e.ExtendedInfo, acceptLanguage)}
+}
+
+// AddExtendedInfo appends more messages to the error.
+func (e *RedfishError) AddExtendedInfo(ei ...ExtendedInfo) *RedfishError {
+	e.ExtendedInfo = append(e.ExtendedInfo, ei...)
+	return e
+}
+
+func (e *RedfishError) Error() string {
+	msgs := []string{}
+	for _, ei := range e.ExtendedInfo {
+		msgs = append(msgs, ei.Message())
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), strings.Join(msgs, " "))
+}
+
+// MarshalJSON renders the standard {"error": {...}} body. When there is
+// exactly one message, it is used for the top level code, otherwise the top
+// level is GeneralError and the details are in the extended info.
+func (e *RedfishError) MarshalJSON() ([]byte, error) {
+	top := NewExtendedInfo("GeneralError")
+	if len(e.ExtendedInfo) > 0 {
+		top.Language = e.ExtendedInfo[0].Language
+	}
+	if len(e.ExtendedInfo) == 1 {
+		top = e.ExtendedInfo[0]
+	}
+	extInfo := e.ExtendedInfo
+	if extInfo == nil {
+		extInfo = []ExtendedInfo{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":                  top.QualifiedID(),
+			"message":               top.Message(),
+			"@Message.ExtendedInfo": extInfo,
+		},
+	})
+}
+
+// localizeResults renders the messages in a response in the best language for
+// the Accept-Language header: error responses, and the @Message.ExtendedInfo
+// annotation on other responses.
+func localizeResults(results interface{}, acceptLanguage string) interface{} {
+	if acceptLanguage == "" {
+		return results
+	}
+	switch r := results.(type) {
+	case *RedfishError:
+		return r.WithLanguage(acceptLanguage)
+	case RedfishResourceProperty:
+		return RedfishResourceProperty{Value: localizeResults(r.Value, acceptLanguage), Meta: r.Meta}
+	case map[string]interface{}:
+		messages, ok := r["@Message.ExtendedInfo"].([]ExtendedInfo)
+		if !ok {
+			return results
+		}
+		ret := map[string]interface{}{}
+		for k, v := range r {
+			ret[k] = v
+		}
+		ret["@Message.ExtendedInfo"] = localizeMessages(messages, acceptLanguage)
+		return ret
+	}
+	return results
+}
+
+// AsRedfishError converts any error into a RedfishError. Errors that aren't
+// already RedfishErrors are reported with the given status code as a
+// GeneralError.
+func AsRedfishError(err error, statusCode int) *RedfishError {
+	if rerr, ok := err.(*RedfishError); ok {
+		return rerr
+	}
+	return NewRedfishError(statusCode, "GeneralError")
+}
+
+// NewErrorResponse builds the HTTPCmdProcessedData for a command that failed
+// with the given error.
+func NewErrorResponse(cmdID eh.UUID, err *RedfishError) HTTPCmdProcessedData {
+	return HTTPCmdProcessedData{
+		CommandID:  cmdID,
+		Results:    err,
+		StatusCode: err.StatusCode,
+		Headers:    map[string]string{},
+	}
+}
@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+func init() {
+	eh.RegisterCommand(func() eh.Command { return &testStatus{} })
+}
+
+const testStatusCommand = eh.CommandType("TestStatus:GET")
+
+// testStatus answers a GET with the status code in the StatusCode property
+// of the resource, and an error body for the failures
+type testStatus struct {
+	ID    eh.UUID `json:"id"`
+	CmdID eh.UUID `json:"cmdid"`
+}
+
+func (c *testStatus) AggregateType() eh.AggregateType { return AggregateType }
+func (c *testStatus) AggregateID() eh.UUID            { return c.ID }
+func (c *testStatus) CommandType() eh.CommandType     { return testStatusCommand }
+func (c *testStatus) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *testStatus) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *testStatus) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	status, _ := a.GetProperty("StatusCode").(int)
+	if status >= 400 {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, NewRedfishError(status, "InternalError")), time.Now()))
+		return nil
+	}
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		Results:    map[string]interface{}{"StatusCode": status},
+		StatusCode: status,
+	}, time.Now()))
+	return nil
+}
+
+func TestRedfishErrorBody(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *RedfishError
+		body string
+	}{
+		{"one message", NewRedfishError(http.StatusBadRequest, "PropertyUnknown", "Nope"), `{"error": {
+			"code": "Base.1.0.PropertyUnknown",
+			"message": "The property Nope is not in the list of valid properties for the resource.",
+			"@Message.ExtendedInfo": [{
+				"@odata.type": "#Message.v1_0_0.Message",
+				"MessageId": "Base.1.0.PropertyUnknown",
+				"Message": "The property Nope is not in the list of valid properties for the resource.",
+				"MessageArgs": ["Nope"],
+				"Severity": "Warning",
+				"Resolution": "Remove the unknown property from the request body and resubmit the request if the operation failed."
+			}]
+		}}`},
+		{"more than one", NewRedfishError(http.StatusBadRequest, "PropertyNotWritable", "Id").AddExtendedInfo(
+			NewExtendedInfo("PropertyValueTypeError", 1, "Name").WithRelatedProperties("#/Name")), `{"error": {
+			"code": "Base.1.0.GeneralError",
+			"message": "A general error has occurred. See ExtendedInfo for more information.",
+			"@Message.ExtendedInfo": [{
+				"@odata.type": "#Message.v1_0_0.Message",
+				"MessageId": "Base.1.0.PropertyNotWritable",
+				"Message": "The property Id is a read only property and cannot be assigned a value.",
+				"MessageArgs": ["Id"],
+				"Severity": "Warning",
+				"Resolution": "Remove the property from the request body and resubmit the request if the operation failed."
+			}, {
+				"@odata.type": "#Message.v1_0_0.Message",
+				"MessageId": "Base.1.0.PropertyValueTypeError",
+				"Message": "The value 1 for the property Name is of a different type than the property can accept.",
+				"MessageArgs": ["1", "Name"],
+				"RelatedProperties": ["#/Name"],
+				"Severity": "Warning",
+				"Resolution": "Correct the value for the property in the request body and resubmit the request if the operation failed."
+			}]
+		}}`},
+		{"no messages", &RedfishError{StatusCode: http.StatusInternalServerError}, `{"error": {
+			"code": "Base.1.0.GeneralError",
+			"message": "A general error has occurred. See ExtendedInfo for more information.",
+			"@Message.ExtendedInfo": []
+		}}`},
+	}
+	for _, tc := range tests {
+		b, err := json.Marshal(tc.err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, expected interface{}
+		json.Unmarshal(b, &got)
+		if err := json.Unmarshal([]byte(tc.body), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: body is\n%s", tc.name, b)
+		}
+	}
+}
+
+func TestExtendedInfoMessage(t *testing.T) {
+	tests := []struct {
+		ei      ExtendedInfo
+		message string
+	}{
+		{NewExtendedInfo("ResourceMissingAtURI", "/redfish/v1/Nope"), "The resource at the URI /redfish/v1/Nope was not found."},
+		{NewExtendedInfo("ActionParameterValueNotInList", "Off", "ResetType", "Reset"),
+			"The value Off for the parameter ResetType in the action Reset is not in the list of acceptable values."},
+		// arguments that are missing are left alone
+		{NewExtendedInfo("PropertyValueTypeError", 1), "The value 1 for the property %2 is of a different type than the property can accept."},
+		{NewExtendedInfo("NotAMessage"), "Base.1.0.NotAMessage"},
+	}
+	for _, tc := range tests {
+		if message := tc.ei.Message(); message != tc.message {
+			t.Errorf("%s: %q", tc.ei.MessageID, message)
+		}
+	}
+}
+
+func TestAsRedfishError(t *testing.T) {
+	rerr := NewRedfishError(http.StatusConflict, "ResourceInUse")
+	if AsRedfishError(rerr, http.StatusBadRequest) != rerr {
+		t.Errorf("a RedfishError wasn't passed through")
+	}
+	rerr = AsRedfishError(errors.New("nope"), http.StatusBadRequest)
+	if rerr.StatusCode != http.StatusBadRequest || len(rerr.ExtendedInfo) != 1 || rerr.ExtendedInfo[0].MessageID != "GeneralError" {
+		t.Errorf("converted to %v", rerr)
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	d := newTestDomain(t)
+	for _, status := range []int{http.StatusOK, http.StatusCreated, http.StatusNotImplemented, http.StatusInternalServerError} {
+		uri := "/redfish/v1/Status/" + strconv.Itoa(status)
+		createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Plugin: "TestStatus", Properties: map[string]interface{}{"StatusCode": status}})
+		w, body := request(t, d, "GET", uri, "")
+		if w.Code != status {
+			t.Errorf("%s: status %d", uri, w.Code)
+		}
+		if ids := messageIDs(body); status >= 400 && (len(ids) != 1 || ids[0] != "InternalError") {
+			t.Errorf("%s: messages %v", uri, ids)
+		}
+	}
+	// commands that don't say are successful
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Status/Unset", Plugin: "TestStatus"})
+	if w, _ := request(t, d, "GET", "/redfish/v1/Status/Unset", ""); w.Code != http.StatusOK {
+		t.Errorf("no status: %d", w.Code)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/HandlerErrors"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{"Name": "x"}})
+
+	tests := []struct {
+		name       string
+		privileges []string
+		method     string
+		uri        string
+		status     int
+		message    string
+	}{
+		{"not found", testUserPrivileges, "GET", "/redfish/v1/Nope", http.StatusNotFound, "ResourceMissingAtURI"},
+		{"not logged in", []string{"Unauthenticated"}, "GET", uri, http.StatusUnauthorized, "NoValidSession"},
+		{"no privileges", nil, "GET", uri, http.StatusUnauthorized, "NoValidSession"},
+		{"not allowed", []string{"Unauthenticated", "ReadOnly"}, "GET", uri, http.StatusForbidden, "InsufficientPrivilege"},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, tc.uri, nil)
+		w := httptest.NewRecorder()
+		NewRedfishHandler(d, testLogger{}, "tester", tc.privileges).ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, expected %d", tc.name, w.Code, tc.status)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("%s: Content-Type %q", tc.name, ct)
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); (challenge != "") != (tc.status == http.StatusUnauthorized) {
+			t.Errorf("%s: WWW-Authenticate %q", tc.name, challenge)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: body isn't json: %s", tc.name, w.Body)
+			continue
+		}
+		if code := lookup(body, "error/code"); code != MessageRegistryPrefix+"."+tc.message {
+			t.Errorf("%s: code %v", tc.name, code)
+		}
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	d := newTestDomain(t)
+	tests := []struct {
+		acceptLanguage string
+		message        string
+		resolution     string
+	}{
+		{"", "The resource at the URI /redfish/v1/Nope was not found.", "Place a valid resource at the URI or correct the URI and resubmit the request."},
+		{"de-DE,de;q=0.9", "Die Ressource unter dem URI /redfish/v1/Nope wurde nicht gefunden.",
+			"Legen Sie eine gültige Ressource unter dem URI an oder korrigieren Sie den URI und senden Sie die Anforderung erneut."},
+		{"fr", "The resource at the URI /redfish/v1/Nope was not found.", "Place a valid resource at the URI or correct the URI and resubmit the request."},
+	}
+	for _, tc := range tests {
+		_, body := request(t, d, "GET", "/redfish/v1/Nope", "", "Accept-Language", tc.acceptLanguage)
+		if message := lookup(body, "error/message"); message != tc.message {
+			t.Errorf("%q: message %v", tc.acceptLanguage, message)
+		}
+		if message := lookup(body, "error/@Message.ExtendedInfo/0/Message"); message != tc.message {
+			t.Errorf("%q: extended info message %v", tc.acceptLanguage, message)
+		}
+		if resolution := lookup(body, "error/@Message.ExtendedInfo/0/Resolution"); resolution != tc.resolution {
+			t.Errorf("%q: resolution %v", tc.acceptLanguage, resolution)
+		}
+		// the id never changes
+		if code := lookup(body, "error/code"); code != "Base.1.0.ResourceMissingAtURI" {
+			t.Errorf("%q: code %v", tc.acceptLanguage, code)
+		}
+	}
+}
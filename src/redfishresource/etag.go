@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// bumpVersion is called for every change to the properties, meta or
+// collection membership of the aggregate. The version is what the ETag is
+// built from.
+func (a *RedfishResourceAggregate) bumpVersion() {
+	atomic.AddUint64(&a.version, 1)
+}
+
+// ETag returns the current entity tag for the resource. The aggregate ID is
+// included so that a resource that is removed and re-created at the same uri
+// doesn't match tags handed out for the old one.
+func (a *RedfishResourceAggregate) ETag() string {
+	id := string(a.ID)
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("\"%s-%d\"", id, atomic.LoadUint64(&a.version))
+}
+
+// GetHeaders returns a copy of the headers to send with a response for this
+// resource, including the current ETag.
+func (a *RedfishResourceAggregate) GetHeaders() map[string]string {
+	ret := map[string]string{}
+	for k, v := range a.Headers {
+		ret[k] = v
+	}
+	ret["ETag"] = a.ETag()
+	return ret
+}
+
+type preconditionKeyType int
+
+const ifMatchKey preconditionKeyType = iota
+
+// withIfMatch adds the If-Match header from the request to the context that
+// the command runs with. The check happens in HandleCommand so that it is
+// done against the aggregate the command is about to change.
+func withIfMatch(ctx context.Context, ifMatch string) context.Context {
+	return context.WithValue(ctx, ifMatchKey, ifMatch)
+}
+
+func ifMatchFromContext(ctx context.Context) (string, bool) {
+	ifMatch, ok := ctx.Value(ifMatchKey).(string)
+	return ifMatch, ok
+}
+
+// etagMatches compares an If-Match or If-None-Match header value (a comma
+// separated list of tags, or "*") against the current tag. Weak tags are
+// compared by their opaque part.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch is run before a conditional command is handled. Callers hold
+// conditionalMu so that two clients with the same tag can't both succeed.
+func (a *RedfishResourceAggregate) checkIfMatch(ifMatch string) error {
+	if !etagMatches(ifMatch, a.ETag()) {
+		return NewRedfishError(http.StatusPreconditionFailed, "PreconditionFailed")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		match  bool
+	}{
+		{`"abc-1"`, true},
+		{`W/"abc-1"`, true},
+		{`"abc-2"`, false},
+		{`"abc-2", "abc-1"`, true},
+		{`"abc-2","abc-3"`, false},
+		{`*`, true},
+		{`abc-1`, false},
+		{``, false},
+	}
+	for _, tc := range tests {
+		if match := etagMatches(tc.header, `"abc-1"`); match != tc.match {
+			t.Errorf("%q matched: %v", tc.header, match)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/ETag"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{
+		"AssetTag":      "one",
+		"AssetTag@meta": testPatchMeta,
+	}})
+
+	w, _ := request(t, d, "GET", uri, "")
+	first := w.Header().Get("ETag")
+	if first == "" {
+		t.Fatal("no ETag")
+	}
+	if w, _ := request(t, d, "GET", uri, "", "If-None-Match", first); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match with the current tag: status %d", w.Code)
+	}
+	// the body with query options isn't what the tag is for
+	for _, query := range []string{"?$select=AssetTag", "?only", "?$top=1"} {
+		if w, _ := request(t, d, "GET", uri+query, "", "If-None-Match", first); w.Code != http.StatusOK {
+			t.Errorf("If-None-Match with %s: status %d", query, w.Code)
+		}
+	}
+	if w, _ := request(t, d, "GET", uri+"?other=1", "", "If-None-Match", first); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match with another query parameter: status %d", w.Code)
+	}
+
+	// each step runs after the ones before it
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		ifMatch  string
+		status   int
+		assetTag string
+	}{
+		{"other tag", "PATCH", `{"AssetTag": "two"}`, `"other"`, http.StatusPreconditionFailed, "one"},
+		{"current tag", "PATCH", `{"AssetTag": "two"}`, first, http.StatusOK, "two"},
+		{"tag from before the change", "PATCH", `{"AssetTag": "three"}`, first, http.StatusPreconditionFailed, "two"},
+		{"PUT with the old tag", "PUT", `{"AssetTag": "three"}`, first, http.StatusPreconditionFailed, "two"},
+		{"any tag", "PATCH", `{"AssetTag": "three"}`, "*", http.StatusOK, "three"},
+		{"no If-Match", "PATCH", `{"AssetTag": "four"}`, "", http.StatusOK, "four"},
+	}
+	for _, tc := range tests {
+		headers := []string{}
+		if tc.ifMatch != "" {
+			headers = append(headers, "If-Match", tc.ifMatch)
+		}
+		w, body := request(t, d, tc.method, uri, tc.body, headers...)
+		if w.Code != tc.status {
+			t.Fatalf("%s: status %d, expected %d\n%s", tc.name, w.Code, tc.status, w.Body)
+		}
+		if tc.status == http.StatusPreconditionFailed {
+			if ids := messageIDs(body); len(ids) != 1 || ids[0] != "PreconditionFailed" {
+				t.Errorf("%s: messages %v", tc.name, ids)
+			}
+		}
+		if _, body := request(t, d, "GET", uri, ""); body["AssetTag"] != tc.assetTag {
+			t.Errorf("%s: AssetTag is %v, expected %s", tc.name, body["AssetTag"], tc.assetTag)
+		}
+	}
+
+	w, _ = request(t, d, "GET", uri, "")
+	if current := w.Header().Get("ETag"); current == first {
+		t.Errorf("the ETag didn't change: %s", current)
+	} else if w, _ := request(t, d, "PATCH", uri, `{"AssetTag": "five"}`, "If-Match", "W/"+current); w.Code != http.StatusOK {
+		t.Errorf("weak current tag: status %d", w.Code)
+	}
+	if w, _ := request(t, d, "GET", uri, "", "If-None-Match", first); w.Code != http.StatusOK {
+		t.Errorf("If-None-Match with an old tag: status %d", w.Code)
+	}
+}
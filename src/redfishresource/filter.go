@@ -0,0 +1,268 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// filterExpr is a parsed $filter expression that can be evaluated against a
+// collection member (in the generic json form from normalizeResults).
+type filterExpr interface {
+	eval(member interface{}) bool
+}
+
+type filterAnd struct{ left, right filterExpr }
+type filterOr struct{ left, right filterExpr }
+type filterNot struct{ expr filterExpr }
+
+type filterCompare struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+func (f filterAnd) eval(m interface{}) bool { return f.left.eval(m) && f.right.eval(m) }
+func (f filterOr) eval(m interface{}) bool  { return f.left.eval(m) || f.right.eval(m) }
+func (f filterNot) eval(m interface{}) bool { return !f.expr.eval(m) }
+
+func (f filterCompare) eval(m interface{}) bool {
+	v := m
+	for _, p := range f.path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			v = nil
+			break
+		}
+		v = obj[p]
+	}
+
+	switch f.op {
+	case "eq":
+		return v == f.value
+	case "ne":
+		return v != f.value
+	}
+
+	// gt/lt only make sense for numbers and strings of the same type
+	switch want := f.value.(type) {
+	case float64:
+		have, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		if f.op == "gt" {
+			return have > want
+		}
+		return have < want
+	case string:
+		have, ok := v.(string)
+		if !ok {
+			return false
+		}
+		if f.op == "gt" {
+			return have > want
+		}
+		return have < want
+	}
+	return false
+}
+
+// parseFilter parses the subset of the OData $filter syntax that we support:
+//
+//	expr       = orExpr
+//	orExpr     = andExpr *( "or" andExpr )
+//	andExpr    = unaryExpr *( "and" unaryExpr )
+//	unaryExpr  = "not" unaryExpr / "(" expr ")" / comparison
+//	comparison = path ( "eq" / "ne" / "gt" / "lt" ) literal
+//
+// Paths use "/" to descend into objects, ie. Status/Health. Literals are
+// 'strings' (quotes doubled to escape), numbers, true, false and null.
+func parseFilter(filter string) (filterExpr, *RedfishError) {
+	fail := NewRedfishError(http.StatusBadRequest, "QueryParameterValueFormatError", filter, "$filter")
+
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, fail
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil || p.pos != len(p.tokens) {
+		return nil, fail
+	}
+	return expr, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeFilter(filter string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{text: string(c)})
+			i++
+		case c == '\'':
+			str := []byte{}
+			i++
+			for {
+				if i >= len(filter) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if filter[i] == '\'' {
+					// '' is an escaped quote
+					if i+1 < len(filter) && filter[i+1] == '\'' {
+						str = append(str, '\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				str = append(str, filter[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{text: string(str), quoted: true})
+		default:
+			start := i
+			for i < len(filter) && !strings.ContainsRune(" \t()'", rune(filter[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{text: filter[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == keyword
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	switch {
+	case p.peek("not"):
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{expr}, nil
+
+	case p.peek("("):
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterExpr, error) {
+	path, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if path.quoted || path.text == "(" || path.text == ")" {
+		return nil, fmt.Errorf("expected property, got %q", path.text)
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case "eq", "ne", "gt", "lt":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op.text)
+	}
+
+	lit, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := filterLiteral(lit)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterCompare{path: strings.Split(path.text, "/"), op: op.text, value: value}, nil
+}
+
+// filterLiteral converts to the same types encoding/json produces so that
+// comparisons against the normalized members work.
+func filterLiteral(t filterToken) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch t.text {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse literal %q", t.text)
+	}
+	return f, nil
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFilterEval(t *testing.T) {
+	var members []interface{}
+	json.Unmarshal([]byte(`[
+		{"Id": "1", "Name": "O'Brien", "Reading": 10, "Enabled": true, "Status": {"Health": "OK"}},
+		{"Id": "2", "Name": "Smith", "Reading": 20.5, "Enabled": false, "Status": {"Health": "Warning"}},
+		{"Id": "3", "Name": "Jones", "Reading": null, "Status": "OK"}
+	]`), &members)
+
+	tests := []struct {
+		filter string
+		ids    []string
+	}{
+		{"Status/Health eq 'OK'", []string{"1"}},
+		{"Status/Health ne 'OK'", []string{"2", "3"}},
+		{"Reading gt 10", []string{"2"}},
+		{"Reading lt 20.5", []string{"1"}},
+		{"Reading eq null", []string{"3"}},
+		{"Enabled eq true", []string{"1"}},
+		{"Enabled eq false", []string{"2"}},
+		{"Name eq 'O''Brien'", []string{"1"}},
+		{"Name gt 'Jones'", []string{"1", "2"}},
+		{"Name gt 5", []string{}},
+		{"Reading gt 'a'", []string{}},
+		{"Nope eq null", []string{"1", "2", "3"}},
+		{"Reading gt 5 and Enabled eq true", []string{"1"}},
+		{"Id eq '1' or Id eq '3'", []string{"1", "3"}},
+		{"not Id eq '1'", []string{"2", "3"}},
+		// and before or, unless there are parentheses
+		{"Id eq '1' or Id eq '2' and Enabled eq true", []string{"1"}},
+		{"(Id eq '1' or Id eq '2') and Enabled eq false", []string{"2"}},
+		{"not (Id eq '1' or Id eq '2')", []string{"3"}},
+	}
+	for _, tc := range tests {
+		expr, rerr := parseFilter(tc.filter)
+		if rerr != nil {
+			t.Errorf("%q: %s", tc.filter, rerr)
+			continue
+		}
+		ids := []string{}
+		for _, m := range members {
+			if expr.eval(m) {
+				ids = append(ids, lookup(m, "Id").(string))
+			}
+		}
+		if !reflect.DeepEqual(ids, tc.ids) {
+			t.Errorf("%q matched %v, expected %v", tc.filter, ids, tc.ids)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"Name",
+		"Name eq",
+		"Name is 'x'",
+		"Name eq 'x",
+		"Name eq x",
+		"'Name' eq 'x'",
+		"(Name eq 'x'",
+		"Name eq 'x')",
+		"Name eq 'x' and",
+		"Name eq 'x' Id eq '1'",
+		"not",
+	} {
+		_, rerr := parseFilter(filter)
+		if rerr == nil {
+			t.Errorf("%q parsed", filter)
+			continue
+		}
+		if rerr.StatusCode != http.StatusBadRequest || rerr.ExtendedInfo[0].MessageID != "QueryParameterValueFormatError" {
+			t.Errorf("%q: %v", filter, rerr)
+		}
+	}
+}
+
+func TestFilterCollection(t *testing.T) {
+	d := newTestDomain(t)
+	createCollection(t, d, "/redfish/v1/Filter", map[string]map[string]interface{}{
+		"1": {"Id": "1", "Status": map[string]interface{}{"Health": "OK"}},
+		"2": {"Id": "2", "Status": map[string]interface{}{"Health": "Critical"}},
+		"3": {"Id": "3", "Status": map[string]interface{}{"Health": "OK"}},
+	})
+
+	tests := []struct {
+		query string
+		uris  []string
+	}{
+		{"$filter=Status/Health eq 'OK'", []string{"/redfish/v1/Filter/1", "/redfish/v1/Filter/3"}},
+		{"$filter=Status/Health eq 'Warning'", []string{}},
+		{"$filter=Id ne '2'&$expand=.", []string{"/redfish/v1/Filter/1", "/redfish/v1/Filter/3"}},
+	}
+	for _, tc := range tests {
+		uri := "/redfish/v1/Filter?" + url.PathEscape(tc.query)
+		w, body := request(t, d, "GET", uri, "")
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", tc.query, w.Code)
+			continue
+		}
+		members, _ := body["Members"].([]interface{})
+		uris := []string{}
+		for _, m := range members {
+			uris = append(uris, lookup(m, "@odata.id").(string))
+		}
+		sort.Strings(uris)
+		if !reflect.DeepEqual(uris, tc.uris) {
+			t.Errorf("%s: members %v, expected %v", tc.query, uris, tc.uris)
+		}
+		if count := body["Members@odata.count"]; count != float64(len(tc.uris)) {
+			t.Errorf("%s: Members@odata.count is %v", tc.query, count)
+		}
+	}
+
+	w, body := request(t, d, "GET", "/redfish/v1/Filter?"+url.PathEscape("$filter=Id eq"), "")
+	if ids := messageIDs(body); w.Code != http.StatusBadRequest || len(ids) != 1 || ids[0] != "QueryParameterValueFormatError" {
+		t.Errorf("bad $filter: status %d, messages %v", w.Code, ids)
+	}
+}
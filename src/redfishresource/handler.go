@@ -7,7 +7,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"path"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	eh "github.com/looplab/eventhorizon"
@@ -32,6 +35,31 @@ type DomainObjects struct {
 
 	collectionsMu sync.RWMutex
 	collections   []string
+
+	// ChildDeletePolicy decides what happens with an http DELETE of a
+	// resource that has other resources under its uri.
+	ChildDeletePolicy ChildDeletePolicy
+
+	// TaskThreshold is how long an http request waits for a command before
+	// returning 202 and continuing as a Task. TaskTimeout is how long the
+	// task waits before giving up, and TaskRetention how long finished tasks
+	// are kept around. 0 means no limit for all of them.
+	TaskThreshold time.Duration
+	TaskTimeout   time.Duration
+	TaskRetention time.Duration
+
+	// CollectionPageSize is the most collection members returned in a single
+	// GET. Longer collections get a Members@odata.nextLink. 0 is unlimited.
+	CollectionPageSize int
+
+	// final responses of the commands that turned into tasks, by task
+	// monitor uri, and the last task id handed out
+	tasksMu     sync.RWMutex
+	taskResults map[string]*HTTPCmdProcessedData
+	taskCounter uint64
+
+	// generated $metadata and odata service document
+	metadataCache metadataCache
 }
 
 // SetupDDDFunctions sets up the full Event Horizon domain
@@ -40,6 +68,7 @@ func NewDomainObjects() (*DomainObjects, error) {
 	d := DomainObjects{}
 
 	d.Tree = make(map[string]eh.UUID)
+	d.taskResults = make(map[string]*HTTPCmdProcessedData)
 
 	// Create the repository and wrap in a version repository.
 	d.Repo = repo.NewRepo()
@@ -91,6 +120,25 @@ func (d *DomainObjects) GetAggregateIDOK(uri string) (id eh.UUID, ok bool) {
 	return
 }
 
+// GetResourceProperty returns the stored value of a top level property of the
+// resource at uri as plain json values (strings, []interface{}...). Plugins
+// are not run, so properties that come from plugins aren't there.
+func (d *DomainObjects) GetResourceProperty(ctx context.Context, uri, property string) (interface{}, bool) {
+	a, ok := d.getAggregate(ctx, uri)
+	if !ok {
+		return nil, false
+	}
+	v := a.GetProperty(property)
+	if v == nil {
+		return nil, false
+	}
+	generic, err := normalizeResults(v)
+	if err != nil {
+		return nil, false
+	}
+	return generic, true
+}
+
 func (d *DomainObjects) SetAggregateID(uri string, ID eh.UUID) {
 	d.treeMu.Lock()
 	defer d.treeMu.Unlock()
@@ -101,22 +149,51 @@ func (d *DomainObjects) DeleteResource(ctx context.Context, uri string) {
 	d.treeMu.Lock()
 	defer d.treeMu.Unlock()
 	if UUID, ok := d.Tree[uri]; ok {
-		as, ok := d.AggregateStore.(eh.WriteRepo)
-		if ok {
-			as.Remove(ctx, UUID)
-		}
+		d.Repo.Remove(ctx, UUID)
 	}
 	delete(d.Tree, uri)
 }
 
+// ChildDeletePolicy is what to do when deleting a resource that has children
+type ChildDeletePolicy int
+
+const (
+	// RefuseDeleteWithChildren fails the DELETE with a 409
+	RefuseDeleteWithChildren ChildDeletePolicy = iota
+	// CascadeDelete removes the children along with the resource
+	CascadeDelete
+)
+
+// Children returns the uris of all of the resources below uri, deepest first.
+func (d *DomainObjects) Children(uri string) []string {
+	d.treeMu.RLock()
+	defer d.treeMu.RUnlock()
+	children := []string{}
+	for k := range d.Tree {
+		if strings.HasPrefix(k, uri+"/") {
+			children = append(children, k)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return len(children[i]) > len(children[j]) })
+	return children
+}
+
 // Notify implements the Notify method of the EventObserver interface.
 func (d *DomainObjects) Notify(ctx context.Context, event eh.Event) {
 	logger := ContextLogger(ctx, "domain")
 	//logger.Debug("Processing event", "event", event)
+	if event.EventType() == RedfishResourcePropertiesUpdated {
+		// links from the service root are what's in the odata service document
+		if data, ok := event.Data().(RedfishResourcePropertiesUpdatedData); ok && data.ResourceURI == ServiceRootURI {
+			d.invalidateMetadata()
+		}
+		return
+	}
 	if event.EventType() == RedfishResourceCreated {
 		if data, ok := event.Data().(RedfishResourceCreatedData); ok {
 			// TODO: handle conflicts (how?)
 			d.SetAggregateID(data.ResourceURI, data.ID)
+			d.invalidateMetadata()
 
 			// TODO: need to split out auto collection management into a plugin
 			if data.Collection {
@@ -179,6 +256,7 @@ func (d *DomainObjects) Notify(ctx context.Context, event eh.Event) {
 
 			// TODO: remove from aggregatestore?
 			d.DeleteResource(ctx, data.ResourceURI)
+			d.invalidateMetadata()
 		}
 		return
 	}
@@ -2,8 +2,8 @@ package domain
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	eh "github.com/looplab/eventhorizon"
@@ -14,36 +14,27 @@ func init() {
 	eh.RegisterCommand(func() eh.Command { return &DELETE{} })
 
 	// TODO: not yet implemented
-	eh.RegisterCommand(func() eh.Command { return &PUT{} })
 	eh.RegisterCommand(func() eh.Command { return &PATCH{} })
-	eh.RegisterCommand(func() eh.Command { return &POST{} })
-	eh.RegisterCommand(func() eh.Command { return &HEAD{} })
-	eh.RegisterCommand(func() eh.Command { return &OPTIONS{} })
 }
 
 const (
 	DELETECommand = eh.CommandType("http:RedfishResource:DELETE")
 
-	PUTCommand     = eh.CommandType("http:RedfishResource:PUT")
-	PATCHCommand   = eh.CommandType("http:RedfishResource:PATCH")
-	POSTCommand    = eh.CommandType("http:RedfishResource:POST")
-	HEADCommand    = eh.CommandType("http:RedfishResource:HEAD")
-	OPTIONSCommand = eh.CommandType("http:RedfishResource:OPTIONS")
+	PATCHCommand = eh.CommandType("http:RedfishResource:PATCH")
 )
 
 // Static type checking for commands to prevent runtime errors due to typos
 var _ = eh.Command(&DELETE{})
 
-var _ = eh.Command(&PUT{})
 var _ = eh.Command(&PATCH{})
-var _ = eh.Command(&POST{})
-var _ = eh.Command(&HEAD{})
-var _ = eh.Command(&OPTIONS{})
 
 // HTTP DELETE Command
 type DELETE struct {
 	ID    eh.UUID `json:"id"`
 	CmdID eh.UUID `json:"cmdid"`
+
+	// set from "Prefer: return=representation"
+	ReturnRepresentation bool `eh:"optional"`
 }
 
 func (c *DELETE) AggregateType() eh.AggregateType { return AggregateType }
@@ -51,27 +42,45 @@ func (c *DELETE) AggregateID() eh.UUID            { return c.ID }
 func (c *DELETE) CommandType() eh.CommandType     { return DELETECommand }
 func (c *DELETE) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *DELETE) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *DELETE) ParseHTTPRequest(r *http.Request) error {
+	for _, prefer := range r.Header["Prefer"] {
+		for _, p := range strings.Split(prefer, ",") {
+			if strings.TrimSpace(p) == "return=representation" {
+				c.ReturnRepresentation = true
+			}
+		}
+	}
+	return nil
+}
 func (c *DELETE) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	// TODO: "Services may return a representation of the just deleted resource in the response body."
-	// - can create a new CMD for GET with an identical CMD ID. Is that cheating?
+	// the handler already refuses these, but internal users can get here too
+	if !a.IsDeletable() {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, NewRedfishError(http.StatusMethodNotAllowed, "ResourceCannotBeDeleted")), time.Now()))
+		return nil
+	}
 
-	// TODO: return http 405 status for undeletable objects. right now we use privileges
+	// "Services may return a representation of the just deleted resource in the response body."
+	data := HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		StatusCode: http.StatusNoContent,
+		Headers:    map[string]string{},
+	}
+	if c.ReturnRepresentation {
+		data.Results, _ = a.ProcessMeta(ctx, "GET", map[string]interface{}{})
+		data.Results = withExtendedInfo(data.Results, []ExtendedInfo{NewExtendedInfo(ResourceEventRegistryPrefix + ".ResourceRemoved")})
+		data.StatusCode = http.StatusOK
+	}
 
 	_, _ = a.ProcessMeta(ctx, "DELETE", map[string]interface{}{})
 
 	// send event to trigger delete
-	a.PublishEvent(eh.NewEvent(RedfishResourceRemoved, &RedfishResourceRemovedData{
+	a.PublishEvent(eh.NewEvent(RedfishResourceRemoved, RedfishResourceRemovedData{
 		ID:          c.ID,
 		ResourceURI: a.ResourceURI,
 	}, time.Now()))
 
 	// send http response
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{},
-		StatusCode: 200,
-		Headers:    map[string]string{},
-	}, time.Now()))
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
 	return nil
 }
 
@@ -89,115 +98,39 @@ func (c *PATCH) CommandType() eh.CommandType     { return PATCHCommand }
 func (c *PATCH) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *PATCH) SetCmdID(id eh.UUID)             { c.CmdID = id }
 func (c *PATCH) ParseHTTPRequest(r *http.Request) error {
-	json.NewDecoder(r.Body).Decode(&c.Body)
+	if err := DecodeJSONBody(r, &c.Body); err != nil {
+		return err
+	}
+	if c.Body == nil {
+		return NewRedfishError(http.StatusBadRequest, "MalformedJSON")
+	}
 	return nil
 }
 func (c *PATCH) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	// only the properties that can be applied are sent to the plugins
+	request, messages := a.validatePatch(c.Body)
+	if len(request) == 0 && len(messages) > 0 {
+		rerr := &RedfishError{StatusCode: http.StatusBadRequest, ExtendedInfo: messages}
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, rerr), time.Now()))
+		return nil
+	}
+
 	// set up the base response data
 	data := HTTPCmdProcessedData{
 		CommandID:  c.CmdID,
 		StatusCode: 200,
 	}
 
-	data.Results, _ = a.ProcessMeta(ctx, "PATCH", c.Body)
-	// TODO: set error status code based on err from ProcessMeta
-	// TODO: This is not thread safe: deep copy
-	data.Headers = a.Headers
+	data.Results, _ = a.ProcessMeta(ctx, "PATCH", request)
+	if len(request) > 0 {
+		// on partial success, the rest of the messages say what wasn't changed
+		messages = append([]ExtendedInfo{NewExtendedInfo(ResourceEventRegistryPrefix + ".ResourceChanged")}, messages...)
+	}
+	if len(messages) > 0 {
+		data.Results = withExtendedInfo(data.Results, messages)
+	}
+	data.Headers = a.GetHeaders()
 
 	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
 	return nil
 }
-
-// HTTP POST Command
-type POST struct {
-	ID    eh.UUID `json:"id"`
-	CmdID eh.UUID `json:"cmdid"`
-	Body  map[string]interface{}
-}
-
-func (c *POST) AggregateType() eh.AggregateType { return AggregateType }
-func (c *POST) AggregateID() eh.UUID            { return c.ID }
-func (c *POST) CommandType() eh.CommandType     { return POSTCommand }
-func (c *POST) SetAggID(id eh.UUID)             { c.ID = id }
-func (c *POST) SetCmdID(id eh.UUID)             { c.CmdID = id }
-func (c *POST) ParseHTTPRequest(r *http.Request) error {
-	json.NewDecoder(r.Body).Decode(&c.Body)
-	return nil
-}
-func (c *POST) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"FOO": "BAR"},
-		StatusCode: 200,
-		Headers:    map[string]string{},
-	}, time.Now()))
-	return nil
-}
-
-// HTTP PUT Command
-type PUT struct {
-	ID    eh.UUID `json:"id"`
-	CmdID eh.UUID `json:"cmdid"`
-	Body  map[string]interface{}
-}
-
-func (c *PUT) AggregateType() eh.AggregateType { return AggregateType }
-func (c *PUT) AggregateID() eh.UUID            { return c.ID }
-func (c *PUT) CommandType() eh.CommandType     { return PUTCommand }
-func (c *PUT) SetAggID(id eh.UUID)             { c.ID = id }
-func (c *PUT) SetCmdID(id eh.UUID)             { c.CmdID = id }
-func (c *PUT) ParseHTTPRequest(r *http.Request) error {
-	json.NewDecoder(r.Body).Decode(&c.Body)
-	return nil
-}
-func (c *PUT) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"ERROR": "This method not yet implemented"},
-		StatusCode: 501, // Not implemented
-		Headers:    map[string]string{},
-	}, time.Now()))
-	return nil
-}
-
-// HTTP HEAD Command
-type HEAD struct {
-	ID    eh.UUID `json:"id"`
-	CmdID eh.UUID `json:"cmdid"`
-}
-
-func (c *HEAD) AggregateType() eh.AggregateType { return AggregateType }
-func (c *HEAD) AggregateID() eh.UUID            { return c.ID }
-func (c *HEAD) CommandType() eh.CommandType     { return HEADCommand }
-func (c *HEAD) SetAggID(id eh.UUID)             { c.ID = id }
-func (c *HEAD) SetCmdID(id eh.UUID)             { c.CmdID = id }
-func (c *HEAD) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"ERROR": "This method not yet implemented"},
-		StatusCode: 501, // Not implemented
-		Headers:    map[string]string{},
-	}, time.Now()))
-	return nil
-}
-
-// HTTP OPTIONS Command
-type OPTIONS struct {
-	ID    eh.UUID `json:"id"`
-	CmdID eh.UUID `json:"cmdid"`
-}
-
-func (c *OPTIONS) AggregateType() eh.AggregateType { return AggregateType }
-func (c *OPTIONS) AggregateID() eh.UUID            { return c.ID }
-func (c *OPTIONS) CommandType() eh.CommandType     { return OPTIONSCommand }
-func (c *OPTIONS) SetAggID(id eh.UUID)             { c.ID = id }
-func (c *OPTIONS) SetCmdID(id eh.UUID)             { c.CmdID = id }
-func (c *OPTIONS) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"ERROR": "This method not yet implemented"},
-		StatusCode: 501, // Not implemented
-		Headers:    map[string]string{},
-	}, time.Now()))
-	return nil
-}
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAllowHeader(t *testing.T) {
+	d := newTestDomain(t)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Allow/Default", Properties: map[string]interface{}{"Name": "x"}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Allow/ReadOnly", Properties: map[string]interface{}{"Name": "x"},
+		Privileges: map[string]interface{}{"GET": []string{"Login"}}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Allow/Delete", Properties: map[string]interface{}{"Name": "x"}, Deletable: true,
+		Privileges: map[string]interface{}{"GET": []string{"Login"}, "DELETE": []string{"ConfigureManager"}}})
+
+	tests := []struct {
+		uri   string
+		allow string
+	}{
+		{"/redfish/v1/Allow/Default", "GET, HEAD, OPTIONS, PUT, PATCH"},
+		{"/redfish/v1/Allow/ReadOnly", "GET, HEAD, OPTIONS"},
+		{"/redfish/v1/Allow/Delete", "GET, HEAD, OPTIONS, DELETE"},
+	}
+	for _, tc := range tests {
+		for _, method := range []string{"GET", "HEAD", "OPTIONS"} {
+			w, _ := request(t, d, method, tc.uri, "")
+			if allow := w.Header().Get("Allow"); allow != tc.allow {
+				t.Errorf("%s %s: Allow is %q, expected %q", method, tc.uri, allow, tc.allow)
+			}
+		}
+	}
+
+	// methods that aren't in the privilege map aren't allowed for anybody
+	w, body := request(t, d, "PATCH", "/redfish/v1/Allow/ReadOnly", `{"Name": "y"}`)
+	if ids := messageIDs(body); w.Code != http.StatusMethodNotAllowed || len(ids) != 1 || ids[0] != "ActionNotSupported" {
+		t.Errorf("PATCH of a read only resource: status %d, messages %v", w.Code, ids)
+	}
+
+	// resources that don't exist don't have an Allow header
+	if w, _ := request(t, d, "GET", "/redfish/v1/Allow/Nope", ""); w.Header().Get("Allow") != "" {
+		t.Errorf("missing resource has Allow %q", w.Header().Get("Allow"))
+	}
+}
+
+func TestHead(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/Head"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{"Name": "head"}})
+
+	get, _ := request(t, d, "GET", uri, "")
+	head, _ := request(t, d, "HEAD", uri, "")
+	if head.Code != get.Code {
+		t.Errorf("HEAD status %d, GET status %d", head.Code, get.Code)
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD has a body: %s", head.Body)
+	}
+	if cl := head.Header().Get("Content-Length"); cl != strconv.Itoa(get.Body.Len()) {
+		t.Errorf("HEAD Content-Length %s, GET body is %d bytes", cl, get.Body.Len())
+	}
+	for _, h := range []string{"Content-Type", "Allow"} {
+		if head.Header().Get(h) != get.Header().Get(h) {
+			t.Errorf("%s: HEAD %q, GET %q", h, head.Header().Get(h), get.Header().Get(h))
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/Options"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{"Name": "options"}})
+
+	w, body := request(t, d, "OPTIONS", uri, "")
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d", w.Code)
+	}
+	if body != nil {
+		t.Errorf("OPTIONS has a body: %v", body)
+	}
+	if w.Header().Get("Allow") == "" {
+		t.Errorf("no Allow header")
+	}
+
+	if w, _ := request(t, d, "OPTIONS", "/redfish/v1/Options/Nope", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing resource: status %d", w.Code)
+	}
+}
+
+// deletePrivileges lets the test user GET and DELETE
+var deletePrivileges = map[string]interface{}{"GET": []string{"Login"}, "DELETE": []string{"ConfigureManager"}}
+
+func TestDelete(t *testing.T) {
+	d := newTestDomain(t)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Delete/Fixed", Privileges: deletePrivileges, Properties: map[string]interface{}{"Name": "x"}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Delete/Gone", Privileges: deletePrivileges, Deletable: true, Properties: map[string]interface{}{"Name": "x"}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Delete/Shown", Privileges: deletePrivileges, Deletable: true, Properties: map[string]interface{}{"Name": "shown"}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Delete/Collection", Privileges: deletePrivileges, Deletable: true, Collection: true})
+
+	for _, uri := range []string{"/redfish/v1/Delete/Fixed", "/redfish/v1/Delete/Collection"} {
+		w, body := request(t, d, "DELETE", uri, "")
+		if ids := messageIDs(body); w.Code != http.StatusMethodNotAllowed || len(ids) != 1 || ids[0] != "ResourceCannotBeDeleted" {
+			t.Errorf("DELETE %s: status %d, messages %v", uri, w.Code, ids)
+		}
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+			t.Errorf("%s: Allow %q", uri, allow)
+		}
+		if !d.HasAggregateID(uri) {
+			t.Errorf("%s was deleted", uri)
+		}
+	}
+
+	uri := "/redfish/v1/Delete/Gone"
+	if w, _ := request(t, d, "GET", uri, ""); w.Header().Get("Allow") != "GET, HEAD, OPTIONS, DELETE" {
+		t.Errorf("%s: Allow %q", uri, w.Header().Get("Allow"))
+	}
+	if w, _ := request(t, d, "DELETE", uri, ""); w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("DELETE %s: status %d\n%s", uri, w.Code, w.Body)
+	}
+	waitFor(t, uri+" to be removed", func() bool { return !d.HasAggregateID(uri) })
+
+	// the representation of the deleted resource, when asked for
+	uri = "/redfish/v1/Delete/Shown"
+	w, body := request(t, d, "DELETE", uri, "", "Prefer", "return=representation")
+	if ids := messageIDs(body); w.Code != http.StatusOK || body["Name"] != "shown" || len(ids) != 1 || ids[0] != "ResourceRemoved" {
+		t.Errorf("DELETE %s with Prefer: status %d, body %v", uri, w.Code, body)
+	}
+	waitFor(t, uri+" to be removed", func() bool { return !d.HasAggregateID(uri) })
+}
+
+func TestDeleteChildren(t *testing.T) {
+	d := newTestDomain(t)
+	defer func(p ChildDeletePolicy) { d.ChildDeletePolicy = p }(d.ChildDeletePolicy)
+
+	create := func(uri string) {
+		createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Privileges: deletePrivileges, Deletable: true, Properties: map[string]interface{}{"Name": "x"}})
+	}
+	for _, uri := range []string{"/redfish/v1/Parent", "/redfish/v1/Parent/Child", "/redfish/v1/Parent/Child/Grandchild", "/redfish/v1/ParentNot"} {
+		create(uri)
+	}
+
+	if children := d.Children("/redfish/v1/Parent"); len(children) != 2 || children[0] != "/redfish/v1/Parent/Child/Grandchild" {
+		t.Errorf("children are %v", children)
+	}
+
+	d.ChildDeletePolicy = RefuseDeleteWithChildren
+	w, body := request(t, d, "DELETE", "/redfish/v1/Parent", "")
+	if ids := messageIDs(body); w.Code != http.StatusConflict || len(ids) != 1 || ids[0] != "ResourceInUse" {
+		t.Errorf("DELETE with children: status %d, messages %v", w.Code, ids)
+	}
+	if !d.HasAggregateID("/redfish/v1/Parent") {
+		t.Errorf("parent was deleted")
+	}
+
+	d.ChildDeletePolicy = CascadeDelete
+	if w, _ := request(t, d, "DELETE", "/redfish/v1/Parent", ""); w.Code != http.StatusNoContent {
+		t.Errorf("cascading DELETE: status %d", w.Code)
+	}
+	for _, uri := range []string{"/redfish/v1/Parent", "/redfish/v1/Parent/Child", "/redfish/v1/Parent/Child/Grandchild"} {
+		uri := uri
+		waitFor(t, uri+" to be removed", func() bool { return !d.HasAggregateID(uri) })
+	}
+	if !d.HasAggregateID("/redfish/v1/ParentNot") {
+		t.Errorf("a resource that only shares a prefix was deleted")
+	}
+}
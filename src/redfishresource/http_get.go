@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	eh "github.com/looplab/eventhorizon"
@@ -22,6 +23,9 @@ var _ = eh.Command(&GET{})
 type GET struct {
 	ID    eh.UUID `json:"id"`
 	CmdID eh.UUID `json:"cmdid"`
+
+	// from $select or excerpt, nil for everything
+	selector propertySelector
 }
 
 func (c *GET) AggregateType() eh.AggregateType { return AggregateType }
@@ -32,6 +36,14 @@ func (c *GET) SetCmdID(id eh.UUID)             { c.CmdID = id }
 func (c *GET) SetUserDetails(u string, p []string) string {
 	return "checkMaster"
 }
+func (c *GET) ParseHTTPRequest(r *http.Request) error {
+	sel, err := parseSelector(r.URL.Query())
+	if err != nil {
+		return err
+	}
+	c.selector = sel
+	return nil
+}
 func (c *GET) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
 	// set up the base response data
 	data := HTTPCmdProcessedData{
@@ -40,12 +52,41 @@ func (c *GET) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
 	}
 	// TODO: Should be able to discern supported methods from the meta and return those
 
+	if c.selector != nil {
+		ctx = withPropertySelector(ctx, c.selector)
+	}
 	data.Results, _ = a.ProcessMeta(ctx, "GET", map[string]interface{}{})
+	if c.selector == nil {
+		// with a selector, the unselected properties haven't been filled in
+		if err := a.validateResults(ctx, data.Results); err != nil {
+			a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, AsRedfishError(err, http.StatusInternalServerError)), time.Now()))
+			return nil
+		}
+	}
+	if rrp, ok := data.Results.(RedfishResourceProperty); ok && c.selector != nil {
+		// the unselected properties are still in there (unprocessed), drop them
+		data.Results = pruneProperty(rrp, c.selector)
+	}
 
 	// TODO: set error status code based on err from ProcessMeta
-	// TODO: This is not thread safe: deep copy
-	data.Headers = a.Headers
+	data.Headers = a.GetHeaders()
 
 	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
 	return nil
 }
+
+func (a *RedfishResourceAggregate) validateResults(ctx context.Context, results interface{}) error {
+	if v, _ := getResourceValidator(); v == nil {
+		return nil
+	}
+	generic, err := normalizeResults(results)
+	if err != nil {
+		return nil
+	}
+	resource, ok := generic.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	odataType, _ := resource["@odata.type"].(string)
+	return validateResource(ctx, a.ResourceURI, odataType, resource, nil, nil)
+}
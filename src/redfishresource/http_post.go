@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+)
+
+func init() {
+	RegisterInitFN(registerPOST)
+}
+
+// the POST command needs the command handler to create collection members
+func registerPOST(ctx context.Context, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) {
+	eh.RegisterCommand(func() eh.Command { return &POST{commandHandler: ch} })
+}
+
+const (
+	POSTCommand = eh.CommandType("http:RedfishResource:POST")
+)
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&POST{})
+
+// HTTP POST Command
+//
+// A POST to a collection that has a CollectionMemberFactory registered
+// creates a new member. Anything else isn't supported.
+type POST struct {
+	commandHandler eh.CommandHandler
+
+	ID    eh.UUID `json:"id"`
+	CmdID eh.UUID `json:"cmdid"`
+	Body  map[string]interface{}
+}
+
+func (c *POST) AggregateType() eh.AggregateType { return AggregateType }
+func (c *POST) AggregateID() eh.UUID            { return c.ID }
+func (c *POST) CommandType() eh.CommandType     { return POSTCommand }
+func (c *POST) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *POST) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *POST) ParseHTTPRequest(r *http.Request) error {
+	return DecodeJSONBody(r, &c.Body)
+}
+func (c *POST) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	factory, ok := getCollectionMemberFactory(a.ResourceURI)
+	if !ok {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, NewRedfishError(http.StatusMethodNotAllowed, "ActionNotSupported", "POST")), time.Now()))
+		return nil
+	}
+
+	create, rerr := factory.reserveMember(a.ResourceURI, a.collectionMembers, c.Body)
+	if rerr != nil {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, rerr), time.Now()))
+		return nil
+	}
+	// once the create is done the member is in the collection (or not created at all)
+	defer factory.release(create.ResourceURI)
+
+	if factory.Create != nil {
+		if err := factory.Create(ctx, create, c.Body); err != nil {
+			a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, AsRedfishError(err, http.StatusBadRequest)), time.Now()))
+			return nil
+		}
+	}
+
+	// DomainObjects.Notify adds the member to this collection when the create event goes out
+	if err := c.commandHandler.HandleCommand(ctx, create); err != nil {
+		ContextLogger(ctx, "POST").Warn("could not create collection member", "collection", a.ResourceURI, "uri", create.ResourceURI, "err", err)
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, AsRedfishError(err, http.StatusInternalServerError)), time.Now()))
+		return nil
+	}
+
+	results := withoutMeta(create.Properties)
+	results["@odata.id"] = create.ResourceURI
+	results["@odata.type"] = create.Type
+	results["@odata.context"] = create.Context
+	results["@Message.ExtendedInfo"] = []ExtendedInfo{NewExtendedInfo(ResourceEventRegistryPrefix + ".ResourceCreated")}
+
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		Results:    results,
+		StatusCode: http.StatusCreated,
+		Headers:    map[string]string{"Location": create.ResourceURI},
+	}, time.Now()))
+	return nil
+}
+
+// withoutMeta copies properties, leaving out the @meta ones at any level
+func withoutMeta(props map[string]interface{}) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for k, v := range props {
+		if strings.HasSuffix(k, "@meta") {
+			continue
+		}
+		if sub, ok := v.(map[string]interface{}); ok {
+			v = withoutMeta(sub)
+		}
+		ret[k] = v
+	}
+	return ret
+}
@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+func init() {
+	eh.RegisterCommand(func() eh.Command { return &PUT{} })
+}
+
+const (
+	PUTCommand = eh.CommandType("http:RedfishResource:PUT")
+)
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&PUT{})
+
+// HTTP PUT Command
+//
+// PUT replaces all of the writable properties of the resource. Writable
+// properties are the ones with a PATCH plugin in their meta, and they are
+// set by running the PATCH plugins. Writable properties that are left out of
+// the body are reset to the "default" from their PATCH meta. Read-only and
+// immutable properties may be included in the body, but only with their
+// current value.
+type PUT struct {
+	ID    eh.UUID `json:"id"`
+	CmdID eh.UUID `json:"cmdid"`
+	Body  map[string]interface{}
+}
+
+func (c *PUT) AggregateType() eh.AggregateType { return AggregateType }
+func (c *PUT) AggregateID() eh.UUID            { return c.ID }
+func (c *PUT) CommandType() eh.CommandType     { return PUTCommand }
+func (c *PUT) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *PUT) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *PUT) ParseHTTPRequest(r *http.Request) error {
+	if err := DecodeJSONBody(r, &c.Body); err != nil {
+		return err
+	}
+	if c.Body == nil {
+		return NewRedfishError(http.StatusBadRequest, "MalformedJSON")
+	}
+	return nil
+}
+func (c *PUT) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	request, rerr := a.buildPutRequest(c.Body)
+	if rerr != nil {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, NewErrorResponse(c.CmdID, rerr), time.Now()))
+		return nil
+	}
+
+	// set up the base response data
+	data := HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		StatusCode: 200,
+	}
+
+	data.Results, _ = a.ProcessMeta(ctx, "PATCH", request)
+	data.Headers = a.GetHeaders()
+
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
+	return nil
+}
+
+// buildPutRequest turns a PUT body into a PATCH request that sets every
+// writable property, or returns an error listing everything wrong with the body.
+func (a *RedfishResourceAggregate) buildPutRequest(body map[string]interface{}) (map[string]interface{}, *RedfishError) {
+	a.propertiesMu.RLock()
+	defer a.propertiesMu.RUnlock()
+
+	props, _ := a.properties.Value.(map[string]interface{})
+	rerr := &RedfishError{StatusCode: http.StatusBadRequest}
+	request := putRequest(props, body, "", rerr)
+	if len(rerr.ExtendedInfo) > 0 {
+		return nil, rerr
+	}
+	return request, nil
+}
+
+func isImmutable(name string) bool {
+	for _, p := range immutableProperties {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func putRequest(props, body map[string]interface{}, path string, rerr *RedfishError) map[string]interface{} {
+	request := map[string]interface{}{}
+
+	for k := range body {
+		// annotations (other than the immutable ones) are informational
+		if strings.Contains(k, "@") && !isImmutable(k) {
+			continue
+		}
+		if _, ok := props[k]; !ok {
+			rerr.AddExtendedInfo(NewExtendedInfo("PropertyUnknown", k).WithRelatedProperties("#" + path + "/" + k))
+		}
+	}
+
+	for k, v := range props {
+		rrp, ok := v.(RedfishResourceProperty)
+		if !ok {
+			continue
+		}
+		bodyValue, present := body[k]
+		related := "#" + path + "/" + k
+
+		if isImmutable(k) {
+			if present && !sameValue(rrp.Value, bodyValue) {
+				rerr.AddExtendedInfo(NewExtendedInfo("PropertyNotWritable", k).WithRelatedProperties(related))
+			}
+			continue
+		}
+
+		// writable: the body value, or the default
+		if meta, ok := rrp.Meta["PATCH"].(map[string]interface{}); ok {
+			if _, ok := meta["plugin"]; ok {
+				if present && valueTypeMismatch(rrp.Value, bodyValue) {
+					rerr.AddExtendedInfo(NewExtendedInfo("PropertyValueTypeError", jsonString(bodyValue), k).WithRelatedProperties(related))
+				} else if present && !isAllowableValue(props[k+allowableValuesSuffix], bodyValue) {
+					rerr.AddExtendedInfo(NewExtendedInfo("PropertyValueNotInList", jsonString(bodyValue), k).WithRelatedProperties(related))
+				} else if present {
+					request[k] = bodyValue
+				} else if def, ok := meta["default"]; ok {
+					request[k] = def
+				} else {
+					rerr.AddExtendedInfo(NewExtendedInfo("PropertyMissing", k).WithRelatedProperties(related))
+				}
+				continue
+			}
+		}
+
+		// objects may have writable properties inside
+		if sub, ok := rrp.Value.(map[string]interface{}); ok {
+			subBody, ok := bodyValue.(map[string]interface{})
+			if present && !ok {
+				rerr.AddExtendedInfo(NewExtendedInfo("PropertyValueTypeError", jsonString(bodyValue), k).WithRelatedProperties(related))
+				continue
+			}
+			if subRequest := putRequest(sub, subBody, path+"/"+k, rerr); len(subRequest) > 0 {
+				request[k] = subRequest
+			}
+			continue
+		}
+
+		// read-only
+		if present && !sameValue(rrp.Value, bodyValue) {
+			rerr.AddExtendedInfo(NewExtendedInfo("PropertyNotWritable", k).WithRelatedProperties(related))
+		}
+	}
+
+	return request
+}
+
+// sameValue compares a (possibly RedfishResourceProperty) value against a
+// value decoded from a request body
+func sameValue(current, body interface{}) bool {
+	generic, err := normalizeResults(current)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(generic, body)
+}
+
+// jsonString renders a request value for use as a message argument
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
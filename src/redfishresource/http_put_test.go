@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// putTestProperties are the properties of a resource, as they are after a
+// CreateRedfishResource
+func putTestProperties() map[string]interface{} {
+	writable := func(def interface{}) map[string]interface{} {
+		meta := map[string]interface{}{"plugin": string(testPluginType)}
+		if def != nil {
+			meta["default"] = def
+		}
+		return map[string]interface{}{"PATCH": meta}
+	}
+	rrp := RedfishResourceProperty{Value: map[string]interface{}{}}
+	rrp.Parse(map[string]interface{}{
+		"@odata.id":                            "/redfish/v1/Put",
+		"Id":                                   "Put",
+		"AssetTag":                             "tag",
+		"AssetTag@meta":                        writable(""),
+		"IndicatorLED":                         "Off",
+		"IndicatorLED@meta":                    writable(nil),
+		"IndicatorLED@Redfish.AllowableValues": []interface{}{"Off", "Lit", "Blinking"},
+		"Boot": map[string]interface{}{
+			"Target":      "None",
+			"Target@meta": writable("None"),
+			"Mode":        "UEFI",
+		},
+		"Status": map[string]interface{}{"Health": "OK"},
+	})
+	return rrp.Value.(map[string]interface{})
+}
+
+func TestPutRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		request  string
+		messages []string
+	}{
+		{"everything", `{"AssetTag": "new", "IndicatorLED": "Lit", "Boot": {"Target": "Pxe"}}`,
+			`{"AssetTag": "new", "IndicatorLED": "Lit", "Boot": {"Target": "Pxe"}}`, nil},
+		{"defaults", `{"IndicatorLED": "Lit"}`,
+			`{"AssetTag": "", "IndicatorLED": "Lit", "Boot": {"Target": "None"}}`, nil},
+		{"read-only with the current values", `{"@odata.id": "/redfish/v1/Put", "Id": "Put", "IndicatorLED": "Lit", "Boot": {"Mode": "UEFI"}, "Status": {"Health": "OK"}}`,
+			`{"AssetTag": "", "IndicatorLED": "Lit", "Boot": {"Target": "None"}}`, nil},
+		{"annotations", `{"IndicatorLED": "Lit", "AssetTag@odata.type": "x", "@odata.etag": "x"}`,
+			`{"AssetTag": "", "IndicatorLED": "Lit", "Boot": {"Target": "None"}}`, nil},
+		{"missing", `{"AssetTag": "new"}`,
+			"", []string{"PropertyMissing"}},
+		{"read-only changed", `{"IndicatorLED": "Lit", "Id": "Other"}`,
+			"", []string{"PropertyNotWritable"}},
+		{"read-only changed inside", `{"IndicatorLED": "Lit", "Boot": {"Mode": "Legacy"}}`,
+			"", []string{"PropertyNotWritable"}},
+		{"immutable changed", `{"IndicatorLED": "Lit", "@odata.id": "/redfish/v1/Other"}`,
+			"", []string{"PropertyNotWritable"}},
+		{"unknown", `{"IndicatorLED": "Lit", "Nope": 1}`,
+			"", []string{"PropertyUnknown"}},
+		{"wrong type", `{"IndicatorLED": 1}`,
+			"", []string{"PropertyValueTypeError"}},
+		{"wrong type object", `{"IndicatorLED": "Lit", "Boot": "Pxe"}`,
+			"", []string{"PropertyValueTypeError"}},
+		{"not allowed", `{"IndicatorLED": "On"}`,
+			"", []string{"PropertyValueNotInList"}},
+		{"all of them", `{"Id": "Other", "Nope": 1, "AssetTag": 1}`,
+			"", []string{"PropertyMissing", "PropertyNotWritable", "PropertyUnknown", "PropertyValueTypeError"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			rerr := &RedfishError{StatusCode: http.StatusBadRequest}
+			request := putRequest(putTestProperties(), body, "", rerr)
+
+			messages := []string{}
+			for _, ei := range rerr.ExtendedInfo {
+				messages = append(messages, ei.MessageID)
+			}
+			sort.Strings(messages)
+			if tc.messages == nil {
+				tc.messages = []string{}
+			}
+			if !reflect.DeepEqual(messages, tc.messages) {
+				t.Fatalf("messages %v, expected %v", messages, tc.messages)
+			}
+			if tc.request == "" {
+				return
+			}
+			var expected map[string]interface{}
+			json.Unmarshal([]byte(tc.request), &expected)
+			if !reflect.DeepEqual(request, expected) {
+				t.Errorf("request is %v, expected %v", request, expected)
+			}
+		})
+	}
+}
+
+func TestPutRelatedProperties(t *testing.T) {
+	var body map[string]interface{}
+	json.Unmarshal([]byte(`{"IndicatorLED": "Lit", "Boot": {"Mode": "Legacy", "Nope": 1}}`), &body)
+	rerr := &RedfishError{StatusCode: http.StatusBadRequest}
+	putRequest(putTestProperties(), body, "", rerr)
+
+	related := []string{}
+	for _, ei := range rerr.ExtendedInfo {
+		related = append(related, ei.RelatedProperties...)
+	}
+	sort.Strings(related)
+	if expected := []string{"#/Boot/Mode", "#/Boot/Nope"}; !reflect.DeepEqual(related, expected) {
+		t.Errorf("related properties %v, expected %v", related, expected)
+	}
+}
+
+func TestPut(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/Put"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{
+		"Id":            "Put",
+		"AssetTag":      "tag",
+		"AssetTag@meta": map[string]interface{}{"PATCH": map[string]interface{}{"plugin": string(testPluginType), "default": ""}},
+		"Location":      "here",
+		"Location@meta": testPatchMeta,
+	}})
+
+	w, body := request(t, d, "PUT", uri, `{"AssetTag": "new"}`)
+	if ids := messageIDs(body); w.Code != http.StatusBadRequest || len(ids) != 1 || ids[0] != "PropertyMissing" {
+		t.Fatalf("PUT without Location: status %d, messages %v", w.Code, ids)
+	}
+	if _, body := request(t, d, "GET", uri, ""); body["AssetTag"] != "tag" {
+		t.Errorf("a PUT that failed changed AssetTag to %v", body["AssetTag"])
+	}
+
+	w, body = request(t, d, "PUT", uri, `{"Location": "there", "Id": "Put"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: status %d\n%s", w.Code, w.Body)
+	}
+	if body["AssetTag"] != "" || body["Location"] != "there" {
+		t.Errorf("after the PUT: %v", body)
+	}
+
+	for _, put := range []string{``, `[]`, `{"Location": `} {
+		w, body := request(t, d, "PUT", uri, put)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %q: status %d, messages %v", put, w.Code, messageIDs(body))
+		}
+	}
+}
@@ -47,6 +47,8 @@ type CreateRedfishResource struct {
 	Meta       map[string]interface{} `eh:"optional"`
 	Private    map[string]interface{} `eh:"optional"`
 	Collection bool                   `eh:"optional"`
+	// Deletable resources can be removed with an http DELETE
+	Deletable bool `eh:"optional"`
 }
 
 func (c *CreateRedfishResource) AggregateType() eh.AggregateType { return AggregateType }
@@ -61,9 +63,21 @@ func (c *CreateRedfishResource) Handle(ctx context.Context, a *RedfishResourceAg
 		requestLogger.Error("Aggregate already exists!", "command", "CreateRedfishResource", "UUID", a.ID, "URI", a.ResourceURI, "request_URI", c.ResourceURI)
 		return errors.New("Already created!")
 	}
+
+	dynamic, writable := map[string]bool{}, map[string]bool{}
+	if c.Collection {
+		dynamic["/Members"] = true
+	}
+	resource := validationInput(c.Properties, "", dynamic, writable)
+	if err := validateResource(ctx, c.ResourceURI, c.Type, resource, dynamic, writable); err != nil {
+		return err
+	}
+
 	a.ID = c.ID
 	a.ResourceURI = c.ResourceURI
 	a.Plugin = c.Plugin
+	a.Collection = c.Collection
+	a.Deletable = c.Deletable
 	if a.Plugin == "" {
 		a.Plugin = "RedfishResource"
 	}
@@ -154,8 +168,6 @@ func (c *UpdateRedfishResourceProperties) CommandType() eh.CommandType {
 	return UpdateRedfishResourcePropertiesCommand
 }
 func (c *UpdateRedfishResourceProperties) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	// TODO: Need to send property updated on update
-
 	// ensure no collisions with immutable properties
 	for _, p := range immutableProperties {
 		delete(c.Properties, p)
@@ -166,6 +178,9 @@ func (c *UpdateRedfishResourceProperties) Handle(ctx context.Context, a *Redfish
 		ResourceURI:   a.ResourceURI,
 		PropertyNames: []string{},
 	}
+	for k := range c.Properties {
+		d.PropertyNames = append(d.PropertyNames, k)
+	}
 	e := RedfishResourcePropertyMetaUpdatedData{
 		ID:          c.ID,
 		ResourceURI: a.ResourceURI,
@@ -175,6 +190,7 @@ func (c *UpdateRedfishResourceProperties) Handle(ctx context.Context, a *Redfish
 	a.propertiesMu.Lock()
 	a.properties.Parse(c.Properties)
 	a.propertiesMu.Unlock()
+	a.bumpVersion()
 
 	if len(d.PropertyNames) > 0 {
 		a.PublishEvent(eh.NewEvent(RedfishResourcePropertiesUpdated, d, time.Now()))
@@ -0,0 +1,207 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// IDPolicy picks the Id (last uri component) for a new collection member.
+// It gets the uris of the existing members and the POST body.
+type IDPolicy func(members []string, body map[string]interface{}) (string, error)
+
+// UUIDPolicy gives each member a new UUID
+func UUIDPolicy(members []string, body map[string]interface{}) (string, error) {
+	return string(eh.NewUUID()), nil
+}
+
+// SequentialIDPolicy numbers members 1, 2, 3... using one more than the
+// highest numbered member that exists.
+func SequentialIDPolicy(members []string, body map[string]interface{}) (string, error) {
+	max := 0
+	for _, m := range members {
+		if n, err := strconv.Atoi(path.Base(m)); err == nil && n > max {
+			max = n
+		}
+	}
+	return strconv.Itoa(max + 1), nil
+}
+
+// PropertyIDPolicy uses the value of a (required) string property from the
+// body, ie. UserName for accounts.
+func PropertyIDPolicy(property string) IDPolicy {
+	return func(members []string, body map[string]interface{}) (string, error) {
+		id, ok := body[property].(string)
+		if !ok || id == "" || strings.Contains(id, "/") {
+			return "", NewRedfishError(http.StatusBadRequest, "PropertyValueFormatError", jsonString(body[property]), property)
+		}
+		return id, nil
+	}
+}
+
+// CollectionMemberFactory describes the members that a POST to a collection
+// creates. Properties is the template for the new member, the POST body is
+// applied over it. Only properties in the template or in Required may be
+// in the body.
+type CollectionMemberFactory struct {
+	Type       string
+	Context    string
+	Privileges map[string]interface{}
+	Plugin     string
+	Properties map[string]interface{}
+	Meta       map[string]interface{}
+
+	// IDPolicy defaults to UUIDPolicy
+	IDPolicy IDPolicy
+	Required []string
+
+	// Deletable members can be removed with an http DELETE
+	Deletable bool
+
+	// Create, if set, is called with the new member before it is created. It
+	// can check the body, change the properties (ie. take out secrets that
+	// are kept elsewhere) and save the member. An error refuses the POST.
+	Create func(ctx context.Context, create *CreateRedfishResource, body map[string]interface{}) error
+
+	// uris of the members that are being created, they aren't in the
+	// collection yet but their ids are taken
+	reservedMu sync.Mutex
+	reserved   map[string]bool
+}
+
+var memberFactories = map[string]*CollectionMemberFactory{}
+var memberFactoriesMu sync.RWMutex
+
+// RegisterCollectionMemberFactory enables POST on the collection at
+// collectionURI. The collection should be created with "Collection: true" so
+// that new members are added to it.
+func RegisterCollectionMemberFactory(collectionURI string, f *CollectionMemberFactory) {
+	memberFactoriesMu.Lock()
+	defer memberFactoriesMu.Unlock()
+	memberFactories[collectionURI] = f
+}
+
+// UnregisterCollectionMemberFactory turns off POST on the collection
+func UnregisterCollectionMemberFactory(collectionURI string) {
+	memberFactoriesMu.Lock()
+	defer memberFactoriesMu.Unlock()
+	delete(memberFactories, collectionURI)
+}
+
+func getCollectionMemberFactory(collectionURI string) (*CollectionMemberFactory, bool) {
+	memberFactoriesMu.RLock()
+	defer memberFactoriesMu.RUnlock()
+	f, ok := memberFactories[collectionURI]
+	return f, ok
+}
+
+// reserveMember is newMember for concurrent POSTs: the members of the
+// collection and the ones other POSTs are still creating count as taken, and
+// the new member's id stays taken until release is called after its create
+// command is done.
+func (f *CollectionMemberFactory) reserveMember(collectionURI string, members func() []string, body map[string]interface{}) (*CreateRedfishResource, *RedfishError) {
+	f.reservedMu.Lock()
+	defer f.reservedMu.Unlock()
+
+	taken := members()
+	for uri := range f.reserved {
+		taken = append(taken, uri)
+	}
+	create, rerr := f.newMember(collectionURI, taken, body)
+	if rerr != nil {
+		return nil, rerr
+	}
+	if f.reserved == nil {
+		f.reserved = map[string]bool{}
+	}
+	f.reserved[create.ResourceURI] = true
+	return create, nil
+}
+
+func (f *CollectionMemberFactory) release(uri string) {
+	f.reservedMu.Lock()
+	defer f.reservedMu.Unlock()
+	delete(f.reserved, uri)
+}
+
+// newMember validates the POST body and builds the command that creates the member
+func (f *CollectionMemberFactory) newMember(collectionURI string, members []string, body map[string]interface{}) (*CreateRedfishResource, *RedfishError) {
+	rerr := &RedfishError{StatusCode: http.StatusBadRequest}
+
+	for _, p := range f.Required {
+		if _, ok := body[p]; !ok {
+			rerr.AddExtendedInfo(NewExtendedInfo("PropertyMissing", p).WithRelatedProperties("#/" + p))
+		}
+	}
+
+	properties := map[string]interface{}{}
+	for k, v := range f.Properties {
+		properties[k] = v
+	}
+	for k, v := range body {
+		switch {
+		case isImmutable(k) || k == "Id":
+			rerr.AddExtendedInfo(NewExtendedInfo("PropertyNotWritable", k).WithRelatedProperties("#/" + k))
+		case strings.Contains(k, "@"):
+			// annotations are informational
+		case f.isAllowed(k):
+			properties[k] = v
+		default:
+			rerr.AddExtendedInfo(NewExtendedInfo("PropertyUnknown", k).WithRelatedProperties("#/" + k))
+		}
+	}
+	if len(rerr.ExtendedInfo) > 0 {
+		return nil, rerr
+	}
+
+	policy := f.IDPolicy
+	if policy == nil {
+		policy = UUIDPolicy
+	}
+	id, err := policy(members, body)
+	if err != nil {
+		return nil, AsRedfishError(err, http.StatusBadRequest)
+	}
+
+	uri := collectionURI + "/" + id
+	for _, m := range members {
+		if m == uri {
+			return nil, NewRedfishError(http.StatusConflict, "ResourceAlreadyExists", f.Type, "Id", id)
+		}
+	}
+	properties["Id"] = id
+
+	privileges := map[string]interface{}{}
+	for k, v := range f.Privileges {
+		privileges[k] = v
+	}
+
+	return &CreateRedfishResource{
+		ID:          eh.NewUUID(),
+		ResourceURI: uri,
+		Type:        f.Type,
+		Context:     f.Context,
+		Privileges:  privileges,
+		Plugin:      f.Plugin,
+		Properties:  properties,
+		Meta:        f.Meta,
+		Deletable:   f.Deletable,
+	}, nil
+}
+
+func (f *CollectionMemberFactory) isAllowed(property string) bool {
+	if _, ok := f.Properties[property]; ok {
+		return true
+	}
+	for _, p := range f.Required {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIDPolicies(t *testing.T) {
+	members := []string{"/redfish/v1/C/1", "/redfish/v1/C/7", "/redfish/v1/C/x"}
+
+	if id, _ := SequentialIDPolicy(members, nil); id != "8" {
+		t.Errorf("SequentialIDPolicy: %q", id)
+	}
+	if id, _ := SequentialIDPolicy(nil, nil); id != "1" {
+		t.Errorf("SequentialIDPolicy with no members: %q", id)
+	}
+
+	a, _ := UUIDPolicy(members, nil)
+	b, _ := UUIDPolicy(members, nil)
+	if a == "" || a == b {
+		t.Errorf("UUIDPolicy: %q then %q", a, b)
+	}
+
+	policy := PropertyIDPolicy("UserName")
+	if id, err := policy(members, map[string]interface{}{"UserName": "bob"}); err != nil || id != "bob" {
+		t.Errorf("PropertyIDPolicy: %q, %v", id, err)
+	}
+	for _, body := range []map[string]interface{}{{}, {"UserName": ""}, {"UserName": 1}, {"UserName": "a/b"}} {
+		if _, err := policy(members, body); err == nil {
+			t.Errorf("PropertyIDPolicy accepted %v", body)
+		}
+	}
+}
+
+func TestNewMember(t *testing.T) {
+	f := &CollectionMemberFactory{
+		Type:       "#Test.v1_0_0.Test",
+		Properties: map[string]interface{}{"Name": "default", "Enabled": true},
+		Required:   []string{"UserName"},
+		IDPolicy:   PropertyIDPolicy("UserName"),
+	}
+	members := []string{"/redfish/v1/C/taken"}
+
+	tests := []struct {
+		name     string
+		body     map[string]interface{}
+		status   int
+		messages []string
+	}{
+		{"ok", map[string]interface{}{"UserName": "bob", "Name": "Bob", "Name@odata.type": "x"}, 0, nil},
+		{"missing", map[string]interface{}{"Name": "Bob"}, http.StatusBadRequest, []string{"PropertyMissing"}},
+		{"unknown", map[string]interface{}{"UserName": "bob", "Nope": 1}, http.StatusBadRequest, []string{"PropertyUnknown"}},
+		{"not writable", map[string]interface{}{"UserName": "bob", "Id": "x", "@odata.id": "x"}, http.StatusBadRequest, []string{"PropertyNotWritable", "PropertyNotWritable"}},
+		{"bad id", map[string]interface{}{"UserName": 1}, http.StatusBadRequest, []string{"PropertyValueFormatError"}},
+		{"exists", map[string]interface{}{"UserName": "taken"}, http.StatusConflict, []string{"ResourceAlreadyExists"}},
+	}
+	for _, tc := range tests {
+		create, rerr := f.newMember("/redfish/v1/C", members, tc.body)
+		if tc.status == 0 {
+			if rerr != nil {
+				t.Errorf("%s: %v", tc.name, rerr.ExtendedInfo)
+				continue
+			}
+			expected := map[string]interface{}{"Id": "bob", "UserName": "bob", "Name": "Bob", "Enabled": true}
+			if create.ResourceURI != "/redfish/v1/C/bob" || !reflect.DeepEqual(create.Properties, expected) {
+				t.Errorf("%s: created %s with %v", tc.name, create.ResourceURI, create.Properties)
+			}
+			continue
+		}
+		if rerr == nil {
+			t.Errorf("%s: no error", tc.name)
+			continue
+		}
+		messages := []string{}
+		for _, ei := range rerr.ExtendedInfo {
+			messages = append(messages, ei.MessageID)
+		}
+		sort.Strings(messages)
+		if rerr.StatusCode != tc.status || !reflect.DeepEqual(messages, tc.messages) {
+			t.Errorf("%s: status %d, messages %v", tc.name, rerr.StatusCode, messages)
+		}
+	}
+
+	// the template isn't changed by the members made from it
+	if f.Properties["Name"] != "default" {
+		t.Errorf("template Name changed to %v", f.Properties["Name"])
+	}
+}
+
+func TestMemberFactoryRegistry(t *testing.T) {
+	uri := "/redfish/v1/Registry"
+	if _, ok := getCollectionMemberFactory(uri); ok {
+		t.Fatal("factory before it was registered")
+	}
+	f := &CollectionMemberFactory{}
+	RegisterCollectionMemberFactory(uri, f)
+	if got, ok := getCollectionMemberFactory(uri); !ok || got != f {
+		t.Errorf("registered factory not found")
+	}
+	UnregisterCollectionMemberFactory(uri)
+	if _, ok := getCollectionMemberFactory(uri); ok {
+		t.Errorf("factory after it was unregistered")
+	}
+}
+
+func TestPost(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/Post"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Collection: true,
+		Privileges: map[string]interface{}{"GET": []string{"Login"}, "POST": []string{"ConfigureManager"}}})
+
+	// no factory, no POST
+	if w, _ := request(t, d, "POST", uri, `{"Name": "x"}`); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST without a factory: status %d", w.Code)
+	}
+
+	RegisterCollectionMemberFactory(uri, &CollectionMemberFactory{
+		Type:       "#Test.v1_0_0.Test",
+		Context:    "/redfish/v1/$metadata#Test.Test",
+		Privileges: map[string]interface{}{"GET": []string{"Login"}},
+		Properties: map[string]interface{}{"Name": "default"},
+		IDPolicy:   SequentialIDPolicy,
+	})
+	defer UnregisterCollectionMemberFactory(uri)
+
+	for i, id := range []string{"1", "2"} {
+		w, body := request(t, d, "POST", uri, `{"Name": "member"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST: status %d\n%s", w.Code, w.Body)
+		}
+		if loc := w.Header().Get("Location"); loc != uri+"/"+id || body["@odata.id"] != loc || body["Id"] != id || body["Name"] != "member" {
+			t.Errorf("POST: Location %q, body %v", loc, body)
+		}
+		if ids := messageIDs(body); len(ids) != 1 || ids[0] != "ResourceCreated" {
+			t.Errorf("POST: messages %v", ids)
+		}
+		waitFor(t, "the member to be in the collection", func() bool {
+			_, body := request(t, d, "GET", uri, "")
+			list, _ := body["Members"].([]interface{})
+			return len(list) == i+1
+		})
+	}
+	if _, body := request(t, d, "GET", uri+"/2", ""); body["Name"] != "member" {
+		t.Errorf("member is %v", body)
+	}
+
+	w, body := request(t, d, "POST", uri, `{"Nope": 1}`)
+	if ids := messageIDs(body); w.Code != http.StatusBadRequest || len(ids) != 1 || ids[0] != "PropertyUnknown" {
+		t.Errorf("bad POST: status %d, messages %v", w.Code, ids)
+	}
+}
+
+func TestConcurrentPost(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/ConcurrentPost"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Collection: true,
+		Privileges: map[string]interface{}{"GET": []string{"Login"}, "POST": []string{"ConfigureManager"}}})
+	RegisterCollectionMemberFactory(uri, &CollectionMemberFactory{
+		Type:       "#Test.v1_0_0.Test",
+		Context:    "/redfish/v1/$metadata#Test.Test",
+		Privileges: map[string]interface{}{"GET": []string{"Login"}},
+		Properties: map[string]interface{}{"Name": "default"},
+		IDPolicy:   SequentialIDPolicy,
+		// slow, like hashing a password, so that the POSTs overlap
+		Create: func(ctx context.Context, create *CreateRedfishResource, body map[string]interface{}) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		},
+	})
+	defer UnregisterCollectionMemberFactory(uri)
+
+	const n = 20
+	locations := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest("POST", uri, strings.NewReader(`{"Name": "member"}`))
+			w := httptest.NewRecorder()
+			NewRedfishHandler(d, testLogger{}, "tester", testUserPrivileges).ServeHTTP(w, r)
+			if w.Code != http.StatusCreated {
+				t.Errorf("POST: status %d\n%s", w.Code, w.Body)
+			}
+			locations <- w.Header().Get("Location")
+		}()
+	}
+	wg.Wait()
+	close(locations)
+
+	seen := map[string]bool{}
+	for loc := range locations {
+		if seen[loc] {
+			t.Errorf("%s was handed out twice", loc)
+		}
+		seen[loc] = true
+	}
+	for i := 1; i <= n; i++ {
+		if !seen[uri+"/"+strconv.Itoa(i)] {
+			t.Errorf("no member %d: %v", i, seen)
+		}
+	}
+	_, body := request(t, d, "GET", uri, "")
+	if list, _ := body["Members"].([]interface{}); len(list) != n {
+		t.Errorf("%d members in the collection", len(list))
+	}
+}
+
+func TestReserveMember(t *testing.T) {
+	f := &CollectionMemberFactory{IDPolicy: SequentialIDPolicy, Properties: map[string]interface{}{"Name": ""}}
+	members := func() []string { return []string{"/redfish/v1/C/1"} }
+
+	a, _ := f.reserveMember("/redfish/v1/C", members, nil)
+	b, _ := f.reserveMember("/redfish/v1/C", members, nil)
+	if a.ResourceURI != "/redfish/v1/C/2" || b.ResourceURI != "/redfish/v1/C/3" {
+		t.Fatalf("reserved %s and %s", a.ResourceURI, b.ResourceURI)
+	}
+
+	// a create that failed gives its id back
+	f.release(b.ResourceURI)
+	if c, _ := f.reserveMember("/redfish/v1/C", members, nil); c.ResourceURI != b.ResourceURI {
+		t.Errorf("reserved %s after %s was released", c.ResourceURI, b.ResourceURI)
+	}
+
+	// ids from the body conflict until the first create is done
+	f = &CollectionMemberFactory{IDPolicy: PropertyIDPolicy("UserName"), Required: []string{"UserName"}}
+	body := map[string]interface{}{"UserName": "bob"}
+	a, _ = f.reserveMember("/redfish/v1/C", members, body)
+	if _, rerr := f.reserveMember("/redfish/v1/C", members, body); rerr == nil || rerr.StatusCode != http.StatusConflict {
+		t.Errorf("the same id was reserved twice: %v", rerr)
+	}
+	f.release(a.ResourceURI)
+	if _, rerr := f.reserveMember("/redfish/v1/C", members, body); rerr != nil {
+		t.Errorf("released id refused: %v", rerr)
+	}
+}
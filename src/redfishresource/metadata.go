@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// $metadata and the odata service document are generated from the resources
+// in the tree. Both are cached, and the cache is dropped when resources are
+// created or removed, or when the properties of the service root change.
+
+const (
+	ServiceRootURI = "/redfish/v1"
+
+	// where the schema files are served from
+	SchemaURIPrefix = "/schemas/v1/"
+)
+
+type metadataCache struct {
+	sync.Mutex
+	metadata []byte
+	odata    []byte
+}
+
+func (d *DomainObjects) invalidateMetadata() {
+	d.metadataCache.Lock()
+	defer d.metadataCache.Unlock()
+	d.metadataCache.metadata = nil
+	d.metadataCache.odata = nil
+}
+
+// GetMetadataHandler returns the http handler for /redfish/v1/$metadata
+func (d *DomainObjects) GetMetadataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.metadataCache.Lock()
+		if d.metadataCache.metadata == nil {
+			d.metadataCache.metadata = d.buildMetadata(r.Context())
+		}
+		b := d.metadataCache.metadata
+		d.metadataCache.Unlock()
+
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("OData-Version", "4.0")
+		w.Write(b)
+	})
+}
+
+// GetODataServiceHandler returns the http handler for /redfish/v1/odata
+func (d *DomainObjects) GetODataServiceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.metadataCache.Lock()
+		if d.metadataCache.odata == nil {
+			d.metadataCache.odata = d.buildODataService(r.Context())
+		}
+		b := d.metadataCache.odata
+		d.metadataCache.Unlock()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("OData-Version", "4.0")
+		w.Write(b)
+	})
+}
+
+func (d *DomainObjects) getAggregate(ctx context.Context, uri string) (*RedfishResourceAggregate, bool) {
+	aggID, ok := d.GetAggregateIDOK(uri)
+	if !ok {
+		return nil, false
+	}
+	agg, _ := d.AggregateStore.Load(ctx, AggregateType, aggID)
+	a, ok := agg.(*RedfishResourceAggregate)
+	return a, ok
+}
+
+// odataTypeNamespaces splits an @odata.type into the schema file name and the
+// namespaces to include: "#Chassis.v1_2_0.Chassis" gives "Chassis" and
+// ["Chassis", "Chassis.v1_2_0"]. Types that don't look like redfish types
+// (ie. the "Action" placeholders) give "".
+func odataTypeNamespaces(odataType string) (string, []string) {
+	if !strings.HasPrefix(odataType, "#") {
+		return "", nil
+	}
+	parts := strings.Split(strings.TrimPrefix(odataType, "#"), ".")
+	if len(parts) < 2 || parts[0] == "" {
+		return "", nil
+	}
+	namespaces := []string{parts[0]}
+	if len(parts) > 2 {
+		namespaces = append(namespaces, parts[0]+"."+strings.Join(parts[1:len(parts)-1], "."))
+	}
+	return parts[0], namespaces
+}
+
+func (d *DomainObjects) buildMetadata(ctx context.Context) []byte {
+	d.treeMu.RLock()
+	uris := make([]string, 0, len(d.Tree))
+	for uri := range d.Tree {
+		uris = append(uris, uri)
+	}
+	d.treeMu.RUnlock()
+
+	// schema file -> set of namespaces
+	schemas := map[string]map[string]bool{}
+	for _, uri := range uris {
+		a, ok := d.getAggregate(ctx, uri)
+		if !ok {
+			continue
+		}
+		odataType, _ := a.GetProperty("@odata.type").(string)
+		schema, namespaces := odataTypeNamespaces(odataType)
+		if schema == "" {
+			continue
+		}
+		if schemas[schema] == nil {
+			schemas[schema] = map[string]bool{}
+		}
+		for _, ns := range namespaces {
+			schemas[schema][ns] = true
+		}
+	}
+
+	names := make([]string, 0, len(schemas))
+	for schema := range schemas {
+		names = append(names, schema)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<edmx:Edmx xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx" Version="4.0">` + "\n\n")
+	for _, schema := range names {
+		fmt.Fprintf(&b, "  <edmx:Reference Uri=\"%s%s_v1.xml\">\n", SchemaURIPrefix, schema)
+		namespaces := make([]string, 0, len(schemas[schema]))
+		for ns := range schemas[schema] {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		for _, ns := range namespaces {
+			fmt.Fprintf(&b, "    <edmx:Include Namespace=\"%s\"/>\n", ns)
+		}
+		b.WriteString("  </edmx:Reference>\n")
+	}
+	fmt.Fprintf(&b, "  <edmx:Reference Uri=\"%sRedfishExtensions_v1.xml\">\n", SchemaURIPrefix)
+	b.WriteString("    <edmx:Include Namespace=\"RedfishExtensions.v1_0_0\" Alias=\"Redfish\"/>\n")
+	b.WriteString("  </edmx:Reference>\n\n")
+
+	// the entity container extends the one for the version of the service root we have
+	container := "ServiceRoot.v1_0_0.ServiceContainer"
+	if root, ok := d.getAggregate(ctx, ServiceRootURI); ok {
+		odataType, _ := root.GetProperty("@odata.type").(string)
+		if _, namespaces := odataTypeNamespaces(odataType); len(namespaces) > 1 {
+			container = namespaces[1] + ".ServiceContainer"
+		}
+	}
+
+	b.WriteString("  <edmx:DataServices>\n\n")
+	b.WriteString("    <Schema xmlns=\"http://docs.oasis-open.org/odata/ns/edm\" Namespace=\"Service\">\n")
+	fmt.Fprintf(&b, "      <EntityContainer Name=\"Service\" Extends=\"%s\"/>\n", container)
+	b.WriteString("    </Schema>\n\n")
+	b.WriteString("  </edmx:DataServices>\n")
+	b.WriteString("</edmx:Edmx>\n")
+	return b.Bytes()
+}
+
+type odataServiceEntry struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+func (d *DomainObjects) buildODataService(ctx context.Context) []byte {
+	entries := []odataServiceEntry{{Name: "Service", Kind: "Singleton", URL: ServiceRootURI + "/"}}
+
+	if root, ok := d.getAggregate(ctx, ServiceRootURI); ok {
+		links := root.linkedResources()
+		names := make([]string, 0, len(links))
+		for name := range links {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			entries = append(entries, odataServiceEntry{Name: name, Kind: "Singleton", URL: links[name]})
+		}
+	}
+
+	b, err := json.MarshalIndent(map[string]interface{}{
+		"@odata.context": ServiceRootURI + "/$metadata",
+		"value":          entries,
+	}, "", "    ")
+	if err != nil {
+		return []byte("{}")
+	}
+	return b
+}
+
+// linkedResources returns the top level properties that are links to other
+// resources, by property name.
+func (r *RedfishResourceAggregate) linkedResources() map[string]string {
+	r.propertiesMu.RLock()
+	generic, err := normalizeResults(r.properties)
+	r.propertiesMu.RUnlock()
+
+	links := map[string]string{}
+	props, ok := generic.(map[string]interface{})
+	if err != nil || !ok {
+		return links
+	}
+	for name, v := range props {
+		if uri, ok := isReference(v); ok {
+			links[name] = uri
+		}
+	}
+	return links
+}
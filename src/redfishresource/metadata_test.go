@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestODataTypeNamespaces(t *testing.T) {
+	tests := []struct {
+		odataType  string
+		schema     string
+		namespaces []string
+	}{
+		{"#Chassis.v1_2_0.Chassis", "Chassis", []string{"Chassis", "Chassis.v1_2_0"}},
+		{"#ChassisCollection.ChassisCollection", "ChassisCollection", []string{"ChassisCollection"}},
+		{"#Oem.v1_0_0.Vendor.Thing", "Oem", []string{"Oem", "Oem.v1_0_0.Vendor"}},
+		{"Action", "", nil},
+		{"#Nope", "", nil},
+		{"", "", nil},
+	}
+	for _, tc := range tests {
+		schema, namespaces := odataTypeNamespaces(tc.odataType)
+		if schema != tc.schema || !reflect.DeepEqual(namespaces, tc.namespaces) {
+			t.Errorf("%q: %q %v", tc.odataType, schema, namespaces)
+		}
+	}
+}
+
+// getMetadata fetches $metadata and the odata service document
+func getMetadata(d *DomainObjects) (string, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	d.GetMetadataHandler().ServeHTTP(w, httptest.NewRequest("GET", "/redfish/v1/$metadata", nil))
+	metadata := w.Body.String()
+
+	w = httptest.NewRecorder()
+	d.GetODataServiceHandler().ServeHTTP(w, httptest.NewRequest("GET", "/redfish/v1/odata", nil))
+	var odata map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &odata)
+	return metadata, odata
+}
+
+func TestMetadata(t *testing.T) {
+	d := newTestDomain(t)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: ServiceRootURI, Type: "#ServiceRoot.v1_1_0.ServiceRoot",
+		Properties: map[string]interface{}{"Name": "Root", "Chassis": map[string]interface{}{"@odata.id": "/redfish/v1/Chassis"}}})
+
+	metadata, odata := getMetadata(d)
+	for _, s := range []string{
+		`<edmx:Reference Uri="/schemas/v1/ServiceRoot_v1.xml">`,
+		`<edmx:Include Namespace="ServiceRoot.v1_1_0"/>`,
+		`<edmx:Reference Uri="/schemas/v1/Test_v1.xml">`,
+		`<edmx:Include Namespace="Test.v1_0_0"/>`,
+		`<edmx:Include Namespace="RedfishExtensions.v1_0_0" Alias="Redfish"/>`,
+		`Extends="ServiceRoot.v1_1_0.ServiceContainer"`,
+	} {
+		if !strings.Contains(metadata, s) {
+			t.Errorf("$metadata doesn't have %s\n%s", s, metadata)
+		}
+	}
+	if lookup(odata, "value/1/name") != "Chassis" || lookup(odata, "value/1/url") != "/redfish/v1/Chassis" {
+		t.Errorf("odata is %v", odata)
+	}
+
+	// new resources and links show up
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Metadata", Type: "#Metadata.v1_0_0.Metadata"})
+	if metadata, _ := getMetadata(d); !strings.Contains(metadata, `<edmx:Include Namespace="Metadata.v1_0_0"/>`) {
+		t.Errorf("$metadata doesn't have the new resource\n%s", metadata)
+	}
+	rootID, _ := d.GetAggregateIDOK(ServiceRootURI)
+	d.CommandHandler.HandleCommand(context.Background(), &UpdateRedfishResourceProperties{ID: rootID,
+		Properties: map[string]interface{}{"Systems": map[string]interface{}{"@odata.id": "/redfish/v1/Systems"}}})
+	waitFor(t, "Systems in the service document", func() bool {
+		_, odata := getMetadata(d)
+		return lookup(odata, "value/2/name") == "Systems"
+	})
+
+	// and go away again
+	aggID, _ := d.GetAggregateIDOK("/redfish/v1/Metadata")
+	d.CommandHandler.HandleCommand(context.Background(), &RemoveRedfishResource{ID: aggID, ResourceURI: "/redfish/v1/Metadata"})
+	waitFor(t, "the removed resource to leave $metadata", func() bool {
+		metadata, _ := getMetadata(d)
+		return !strings.Contains(metadata, "Metadata.v1_0_0")
+	})
+}
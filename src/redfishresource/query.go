@@ -0,0 +1,376 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// queryOptions holds the parsed redfish/odata query parameters that are
+// processed by the RedfishHandler after the GET command returns.
+type queryOptions struct {
+	// $expand: "" (none), "." (subordinate links), "*" (all links), or "~" (only links under "Links")
+	expand       string
+	expandLevels int
+
+	// only: return the member instead of the collection when there is exactly one
+	only bool
+
+	// $filter, nil when not specified
+	filter filterExpr
+
+	// $skip and $top, top is -1 when not specified
+	skip int
+	top  int
+
+	// used to build Members@odata.nextLink
+	path   string
+	values url.Values
+
+	// any query options at all, $select included: the body isn't the whole
+	// resource that the ETag is for
+	present bool
+}
+
+// queryParameters are the query options that change the body of a GET
+var queryParameters = []string{"$select", "$expand", "$filter", "$skip", "$top", "only"}
+
+func parseQueryOptions(r *http.Request) (*queryOptions, *RedfishError) {
+	values := r.URL.Query()
+	q := &queryOptions{top: -1, path: r.URL.Path, values: values}
+	for _, p := range queryParameters {
+		if _, ok := values[p]; ok {
+			q.present = true
+		}
+	}
+
+	if expand, ok := values["$expand"]; ok && len(expand) > 0 {
+		if err := q.parseExpand(expand[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := values["only"]; ok {
+		q.only = true
+	}
+
+	if filter, ok := values["$filter"]; ok && len(filter) > 0 {
+		expr, err := parseFilter(filter[0])
+		if err != nil {
+			return nil, err
+		}
+		q.filter = expr
+	}
+
+	if skip, ok := values["$skip"]; ok && len(skip) > 0 {
+		n, err := parseNonNegative(skip[0], "$skip")
+		if err != nil {
+			return nil, err
+		}
+		q.skip = n
+	}
+
+	if top, ok := values["$top"]; ok && len(top) > 0 {
+		n, err := parseNonNegative(top[0], "$top")
+		if err != nil {
+			return nil, err
+		}
+		q.top = n
+	}
+
+	return q, nil
+}
+
+func parseNonNegative(value, param string) (int, *RedfishError) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, NewRedfishError(http.StatusBadRequest, "QueryParameterValueTypeError", value, param)
+	}
+	if n < 0 {
+		return 0, NewRedfishError(http.StatusBadRequest, "QueryParameterOutOfRange", value, param, "0-"+strconv.Itoa(math.MaxInt32))
+	}
+	return n, nil
+}
+
+// parseExpand handles the redfish $expand syntax: "$expand=.($levels=2)"
+func (q *queryOptions) parseExpand(expand string) *RedfishError {
+	q.expandLevels = 1
+
+	mode := expand
+	if i := strings.Index(expand, "("); i >= 0 {
+		mode = expand[:i]
+		opts := strings.TrimSuffix(expand[i+1:], ")")
+		if !strings.HasSuffix(expand, ")") || !strings.HasPrefix(opts, "$levels=") {
+			return NewRedfishError(http.StatusBadRequest, "QueryParameterValueFormatError", expand, "$expand")
+		}
+		levels, err := strconv.Atoi(strings.TrimPrefix(opts, "$levels="))
+		if err != nil {
+			return NewRedfishError(http.StatusBadRequest, "QueryParameterValueTypeError", opts, "$levels")
+		}
+		if levels < 1 || levels > maxExpandLevels {
+			return NewRedfishError(http.StatusBadRequest, "QueryParameterOutOfRange", levels, "$levels", "1-"+strconv.Itoa(maxExpandLevels))
+		}
+		q.expandLevels = levels
+	}
+
+	switch mode {
+	case ".", "*", "~":
+		q.expand = mode
+	default:
+		return NewRedfishError(http.StatusBadRequest, "QueryParameterValueFormatError", expand, "$expand")
+	}
+	return nil
+}
+
+// maxExpandLevels bounds the amount of work a single request can make us do
+const maxExpandLevels = 6
+
+// applyQueryOptions post-processes the results of a GET command
+func (rh *RedfishHandler) applyQueryOptions(ctx context.Context, q *queryOptions, results interface{}) interface{} {
+	if q.expand == "" && !q.only && q.filter == nil && !rh.mightPage(q, results) {
+		return results
+	}
+
+	generic, err := normalizeResults(results)
+	if err != nil {
+		rh.logger.Error("could not normalize results for query processing", "err", err)
+		return results
+	}
+
+	if q.only {
+		generic = rh.only(ctx, generic)
+	}
+	if q.filter != nil {
+		rh.filter(ctx, q, generic)
+	}
+	rh.page(q, generic)
+	if q.expand == "" {
+		return generic
+	}
+
+	visited := map[string]bool{}
+	if m, ok := generic.(map[string]interface{}); ok {
+		if self, ok := m["@odata.id"].(string); ok {
+			visited[self] = true
+		}
+	}
+	return rh.expand(ctx, q, generic, q.expandLevels, false, visited)
+}
+
+// only replaces a collection that has exactly one member with that member.
+// Anything else is returned unchanged.
+func (rh *RedfishHandler) only(ctx context.Context, v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	members, ok := m["Members"].([]interface{})
+	if !ok || len(members) != 1 {
+		return v
+	}
+	uri, ok := isReference(members[0])
+	if !ok {
+		return v
+	}
+
+	data, rerr := rh.runCommand(ctx, eh.NewUUID(), "GET", uri, nil)
+	if rerr != nil || data.StatusCode >= 300 {
+		return v
+	}
+	sub, err := normalizeResults(data.Results)
+	if err != nil {
+		return v
+	}
+	return sub
+}
+
+// filter drops the collection members that don't match $filter. Each member
+// is fetched to evaluate the expression, members that can't be fetched don't
+// match. Members@odata.count is updated to the number that matched.
+func (rh *RedfishHandler) filter(ctx context.Context, q *queryOptions, v interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	members, ok := m["Members"].([]interface{})
+	if !ok {
+		return
+	}
+
+	matched := []interface{}{}
+	for _, member := range members {
+		body := member
+		if uri, ok := isReference(member); ok {
+			data, rerr := rh.runCommand(ctx, eh.NewUUID(), "GET", uri, nil)
+			if rerr != nil || data.StatusCode >= 300 {
+				continue
+			}
+			sub, err := normalizeResults(data.Results)
+			if err != nil {
+				continue
+			}
+			body = sub
+		}
+		if q.filter.eval(body) {
+			matched = append(matched, member)
+		}
+	}
+
+	m["Members"] = matched
+	if _, ok := m["Members@odata.count"]; ok {
+		m["Members@odata.count"] = len(matched)
+	}
+}
+
+// mightPage is true if page could change the results, so that everything
+// else skips the round trip through normalizeResults.
+func (rh *RedfishHandler) mightPage(q *queryOptions, results interface{}) bool {
+	if q.skip > 0 || q.top >= 0 {
+		return true
+	}
+	if rh.d.CollectionPageSize <= 0 {
+		return false
+	}
+	count, ok := memberCount(results)
+	return ok && count > rh.d.CollectionPageSize
+}
+
+// memberCount returns the length of the Members array of results that
+// haven't been through normalizeResults, ok is false if there isn't one.
+func memberCount(results interface{}) (int, bool) {
+	if rrp, ok := results.(RedfishResourceProperty); ok {
+		results = rrp.Value
+	}
+	m, ok := results.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	members := m["Members"]
+	if rrp, ok := members.(RedfishResourceProperty); ok {
+		members = rrp.Value
+	}
+	v := reflect.ValueOf(members)
+	if v.Kind() != reflect.Slice {
+		return 0, false
+	}
+	return v.Len(), true
+}
+
+// page trims the Members of a collection down to what was asked for with
+// $skip/$top, limited to the server page size. If there are more members
+// than we return, a Members@odata.nextLink pointing at the rest is added.
+// Members@odata.count is left alone so that it reports the full collection.
+func (rh *RedfishHandler) page(q *queryOptions, v interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	members, ok := m["Members"].([]interface{})
+	if !ok {
+		return
+	}
+
+	// -1 for no limit
+	limit := rh.d.CollectionPageSize
+	if limit <= 0 {
+		limit = -1
+	}
+	if q.top >= 0 && (limit < 0 || q.top < limit) {
+		limit = q.top
+	}
+
+	start := q.skip
+	if start > len(members) {
+		start = len(members)
+	}
+	end := len(members)
+	if limit >= 0 && start+limit < end {
+		end = start + limit
+	}
+	m["Members"] = members[start:end]
+
+	// more left, and the client didn't limit it to this page with $top
+	returned := end - start
+	if end >= len(members) || (q.top >= 0 && returned >= q.top) {
+		return
+	}
+
+	next := url.Values{}
+	for k, v := range q.values {
+		next[k] = v
+	}
+	next.Set("$skip", strconv.Itoa(end))
+	if q.top >= 0 {
+		next.Set("$top", strconv.Itoa(q.top-returned))
+	}
+	m["Members@odata.nextLink"] = q.path + "?" + next.Encode()
+}
+
+// normalizeResults converts the output of ProcessMeta (a tree of
+// RedfishResourceProperty) to plain json types so that it can be walked.
+func normalizeResults(results interface{}) (generic interface{}, err error) {
+	b, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &generic)
+	return
+}
+
+// isReference returns the uri if the value is a bare navigation link: {"@odata.id": "/redfish/v1/..."}
+func isReference(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	uri, ok := m["@odata.id"].(string)
+	return uri, ok
+}
+
+// expand walks the results and replaces navigation links with the GET output
+// of the linked resource. Links that the user doesn't have privileges to see,
+// or that can't be found, are left alone. visited holds the uris of the
+// resources above us to prevent loops.
+func (rh *RedfishHandler) expand(ctx context.Context, q *queryOptions, v interface{}, levels int, inLinks bool, visited map[string]bool) interface{} {
+	if uri, ok := isReference(v); ok {
+		wanted := q.expand == "*" || (q.expand == "." && !inLinks) || (q.expand == "~" && inLinks)
+		if !wanted || visited[uri] {
+			return v
+		}
+
+		data, rerr := rh.runCommand(ctx, eh.NewUUID(), "GET", uri, nil)
+		if rerr != nil || data.StatusCode >= 300 {
+			return v
+		}
+		sub, err := normalizeResults(data.Results)
+		if err != nil {
+			return v
+		}
+		if levels <= 1 {
+			return sub
+		}
+
+		visited[uri] = true
+		defer delete(visited, uri)
+		return rh.expand(ctx, q, sub, levels-1, false, visited)
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range t {
+			t[k] = rh.expand(ctx, q, sub, levels, inLinks || k == "Links", visited)
+		}
+	case []interface{}:
+		for i, sub := range t {
+			t[i] = rh.expand(ctx, q, sub, levels, inLinks, visited)
+		}
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"net/http"
+	"testing"
+)
+
+func link(uri string) map[string]interface{} {
+	return map[string]interface{}{"@odata.id": uri}
+}
+
+func TestExpand(t *testing.T) {
+	d := newTestDomain(t)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/ExpandSub/C", Properties: map[string]interface{}{
+		"Name": "C",
+		"Next": link("/redfish/v1/ExpandSub/D"),
+	}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/ExpandSub/D", Properties: map[string]interface{}{"Name": "D"}})
+	createResource(t, d, &CreateRedfishResource{
+		ResourceURI: "/redfish/v1/ExpandSecret",
+		Privileges:  map[string]interface{}{"GET": []string{"ConfigureUsers"}},
+		Properties:  map[string]interface{}{"Name": "Secret"},
+	})
+	createCollection(t, d, "/redfish/v1/Expand", map[string]map[string]interface{}{
+		"A": {
+			"Name":   "A",
+			"Sub":    link("/redfish/v1/ExpandSub/C"),
+			"Secret": link("/redfish/v1/ExpandSecret"),
+			"Gone":   link("/redfish/v1/ExpandNowhere"),
+			"Links": map[string]interface{}{
+				"Peer": link("/redfish/v1/Expand/B"),
+				"Self": link("/redfish/v1/Expand/A"),
+			},
+		},
+		"B": {"Name": "B"},
+	})
+
+	tests := []struct {
+		name     string
+		uri      string
+		expanded []string
+		links    []string
+	}{
+		{"none", "/redfish/v1/Expand/A",
+			nil, []string{"Sub", "Links/Peer"}},
+		{"subordinate", "/redfish/v1/Expand/A?$expand=.",
+			[]string{"Sub"}, []string{"Sub/Next", "Links/Peer", "Links/Self", "Secret", "Gone"}},
+		{"links", "/redfish/v1/Expand/A?$expand=~",
+			[]string{"Links/Peer"}, []string{"Sub", "Links/Self"}},
+		{"all", "/redfish/v1/Expand/A?$expand=*",
+			[]string{"Sub", "Links/Peer"}, []string{"Sub/Next", "Links/Self", "Secret", "Gone"}},
+		{"levels", "/redfish/v1/Expand/A?$expand=.($levels=2)",
+			[]string{"Sub", "Sub/Next"}, []string{"Links/Peer"}},
+		{"collection", "/redfish/v1/Expand?$expand=.",
+			[]string{"Members/0", "Members/1"}, nil},
+		{"collection levels", "/redfish/v1/Expand?$expand=*($levels=2)",
+			[]string{"Members/0", "Members/1"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w, body := request(t, d, "GET", tc.uri, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d\n%s", w.Code, w.Body)
+			}
+			for _, path := range tc.expanded {
+				if lookup(body, path+"/Name") == nil {
+					t.Errorf("%s wasn't expanded: %v", path, lookup(body, path))
+				}
+			}
+			for _, path := range tc.links {
+				v, _ := lookup(body, path).(map[string]interface{})
+				if _, ok := isReference(v); !ok {
+					t.Errorf("%s isn't a link: %v", path, lookup(body, path))
+				}
+			}
+		})
+	}
+
+	// the members of A, which links back to itself, are expanded two levels
+	// down without looping
+	_, body := request(t, d, "GET", "/redfish/v1/Expand?$expand=*($levels=3)", "")
+	for _, member := range body["Members"].([]interface{}) {
+		if lookup(member, "Name") == "A" {
+			if lookup(member, "Sub/Next/Name") != "D" || lookup(member, "Links/Peer/Name") != "B" {
+				t.Errorf("A wasn't expanded all the way: %v", member)
+			}
+			if _, ok := isReference(lookup(member, "Links/Self")); !ok {
+				t.Errorf("A was expanded inside itself: %v", lookup(member, "Links/Self"))
+			}
+		}
+	}
+}
+
+func TestExpandBadRequest(t *testing.T) {
+	d := newTestDomain(t)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/ExpandBad", Properties: map[string]interface{}{"Name": "Bad"}})
+
+	tests := []struct {
+		expand  string
+		message string
+	}{
+		{"x", "QueryParameterValueFormatError"},
+		{"", "QueryParameterValueFormatError"},
+		{".(levels=1)", "QueryParameterValueFormatError"},
+		{".($levels=1", "QueryParameterValueFormatError"},
+		{".($levels=x)", "QueryParameterValueTypeError"},
+		{".($levels=0)", "QueryParameterOutOfRange"},
+		{".($levels=7)", "QueryParameterOutOfRange"},
+	}
+	for _, tc := range tests {
+		w, body := request(t, d, "GET", "/redfish/v1/ExpandBad?$expand="+tc.expand, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("$expand=%s: status %d", tc.expand, w.Code)
+			continue
+		}
+		if ids := messageIDs(body); len(ids) != 1 || ids[0] != tc.message {
+			t.Errorf("$expand=%s: messages %v, expected %s", tc.expand, ids, tc.message)
+		}
+	}
+}
+
+func TestPaging(t *testing.T) {
+	d := newTestDomain(t)
+	members := map[string]map[string]interface{}{}
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		members[id] = map[string]interface{}{"Name": id}
+	}
+	createCollection(t, d, "/redfish/v1/Page", members)
+	defer func(size int) { d.CollectionPageSize = size }(d.CollectionPageSize)
+
+	tests := []struct {
+		pageSize int
+		query    string
+		members  int
+		next     bool
+	}{
+		{0, "", 5, false},
+		{0, "$top=2", 2, false},
+		{0, "$top=0", 0, false},
+		{0, "$skip=3", 2, false},
+		{0, "$skip=9", 0, false},
+		{0, "$skip=1&$top=2", 2, false},
+		{2, "", 2, true},
+		{2, "$skip=4", 1, false},
+		{2, "$top=3", 2, true},
+		{2, "$top=1", 1, false},
+		{2, "$skip=2&$top=2", 2, false},
+	}
+	for _, tc := range tests {
+		d.CollectionPageSize = tc.pageSize
+		w, body := request(t, d, "GET", "/redfish/v1/Page?"+tc.query, "")
+		if w.Code != http.StatusOK {
+			t.Errorf("%d %s: status %d", tc.pageSize, tc.query, w.Code)
+			continue
+		}
+		list, _ := body["Members"].([]interface{})
+		_, next := body["Members@odata.nextLink"]
+		if len(list) != tc.members || next != tc.next {
+			t.Errorf("%d %s: %d members, nextLink %v", tc.pageSize, tc.query, len(list), body["Members@odata.nextLink"])
+		}
+		if count := body["Members@odata.count"]; count != float64(5) {
+			t.Errorf("%d %s: Members@odata.count is %v", tc.pageSize, tc.query, count)
+		}
+	}
+
+	// following the links gets every member once, and no more than $top
+	for query, expected := range map[string]int{"": 5, "$top=3": 3, "$skip=1": 4} {
+		d.CollectionPageSize = 2
+		seen := map[string]bool{}
+		uri := "/redfish/v1/Page?" + query
+		for pages := 0; uri != ""; pages++ {
+			if pages > 5 {
+				t.Fatalf("%s: too many pages", query)
+			}
+			_, body := request(t, d, "GET", uri, "")
+			list, _ := body["Members"].([]interface{})
+			for _, m := range list {
+				id, _ := isReference(m)
+				if seen[id] {
+					t.Errorf("%s: %s is on more than one page", query, id)
+				}
+				seen[id] = true
+			}
+			uri, _ = body["Members@odata.nextLink"].(string)
+		}
+		if len(seen) != expected {
+			t.Errorf("%s: %d members, expected %d", query, len(seen), expected)
+		}
+	}
+
+	for query, message := range map[string]string{
+		"$top=-1": "QueryParameterOutOfRange",
+		"$skip=x": "QueryParameterValueTypeError",
+	} {
+		w, body := request(t, d, "GET", "/redfish/v1/Page?"+query, "")
+		if ids := messageIDs(body); w.Code != http.StatusBadRequest || len(ids) != 1 || ids[0] != message {
+			t.Errorf("%s: status %d, messages %v", query, w.Code, ids)
+		}
+	}
+}
+
+func TestMightPage(t *testing.T) {
+	members := func(n int) []interface{} { return make([]interface{}, n) }
+	tests := []struct {
+		name     string
+		pageSize int
+		q        queryOptions
+		results  interface{}
+		page     bool
+	}{
+		{"no paging", 0, queryOptions{top: -1}, map[string]interface{}{"Members": members(5)}, false},
+		{"$top", 0, queryOptions{top: 2}, map[string]interface{}{"Name": "x"}, true},
+		{"$skip", 0, queryOptions{top: -1, skip: 1}, map[string]interface{}{"Name": "x"}, true},
+		{"fits on a page", 5, queryOptions{top: -1}, map[string]interface{}{"Members": members(5)}, false},
+		{"more than a page", 5, queryOptions{top: -1}, map[string]interface{}{"Members": members(6)}, true},
+		{"property", 5, queryOptions{top: -1}, RedfishResourceProperty{Value: map[string]interface{}{
+			"Members": RedfishResourceProperty{Value: []map[string]interface{}{{}, {}, {}, {}, {}, {}}}}}, true},
+		{"not a collection", 5, queryOptions{top: -1}, map[string]interface{}{"Name": "x"}, false},
+		{"not a list", 5, queryOptions{top: -1}, map[string]interface{}{"Members": "x"}, false},
+	}
+	d := newTestDomain(t)
+	defer func(size int) { d.CollectionPageSize = size }(d.CollectionPageSize)
+	rh := NewRedfishHandler(d, testLogger{}, "tester", testUserPrivileges)
+	for _, tc := range tests {
+		d.CollectionPageSize = tc.pageSize
+		if page := rh.mightPage(&tc.q, tc.results); page != tc.page {
+			t.Errorf("%s: mightPage is %v", tc.name, page)
+		}
+	}
+}
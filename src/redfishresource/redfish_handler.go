@@ -1,9 +1,12 @@
 package domain
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	eh "github.com/looplab/eventhorizon"
 	log "github.com/superchalupa/go-redfish/src/log"
@@ -19,6 +22,12 @@ type AggIDSetter interface {
 	SetAggID(eh.UUID)
 }
 
+// DomainObjectsSetter interface is for commands that need the domain objects
+// of the handler running them
+type DomainObjectsSetter interface {
+	SetDomainObjects(*DomainObjects)
+}
+
 // UserDetailsSetter is the interface that commands should implement to tell the handler if they handle authorization or std code should do it.
 type UserDetailsSetter interface {
 	SetUserDetails(string, []string) string
@@ -34,6 +43,13 @@ type HTTPParser interface {
 	ParseHTTPRequest(*http.Request) error
 }
 
+// ActionParameterGetter is the interface for commands that run actions. The
+// parameters are checked against the @Redfish.AllowableValues annotations in
+// the action's entry in the Actions property of the resource.
+type ActionParameterGetter interface {
+	ActionParameters() map[string]interface{}
+}
+
 // NewRedfishHandler is the constructor that returns a new RedfishHandler object.
 func NewRedfishHandler(dobjs *DomainObjects, logger log.Logger, u string, p []string) *RedfishHandler {
 	return &RedfishHandler{UserName: u, Privileges: p, d: dobjs, logger: logger}
@@ -70,41 +86,170 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cmdID := eh.NewUUID()
 	reqCtx := WithRequestID(r.Context(), cmdID)
 
-	// All operations have to be on URLs that exist, so look it up in the tree
-	aggID, ok := rh.d.GetAggregateIDOK(r.URL.Path)
-	if !ok {
-		http.Error(w, "Could not find URL: "+r.URL.Path, http.StatusNotFound)
+	if allowed := rh.allowedMethods(reqCtx, r.URL.Path); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+
+	// HEAD is a GET without the body
+	if r.Method == "HEAD" {
+		w = headResponseWriter{w}
+	}
+
+	// OPTIONS only needs to be authorized like a GET, the Allow header is the answer
+	if r.Method == "OPTIONS" {
+		if _, rerr := rh.prepareCommand(reqCtx, cmdID, "GET", r.URL.Path); rerr != nil {
+			rh.writeError(w, r, rerr)
+			return
+		}
+		rh.writeResponse(w, r, HTTPCmdProcessedData{StatusCode: http.StatusOK})
 		return
 	}
 
+	query, rerr := parseQueryOptions(r)
+	if rerr != nil {
+		rh.writeError(w, r, rerr)
+		return
+	}
+
+	data, rerr := rh.runCommand(reqCtx, cmdID, commandMethod(r.Method), r.URL.Path, r)
+	if rerr != nil {
+		rh.writeError(w, r, rerr)
+		return
+	}
+
+	if (r.Method == "GET" || r.Method == "HEAD") && succeeded(data.StatusCode) {
+		// the ETag is for the whole resource, not what the query options make of it
+		if inm := r.Header.Get("If-None-Match"); inm != "" && !query.present && etagMatches(inm, data.Headers["ETag"]) {
+			rh.writeResponse(w, r, HTTPCmdProcessedData{StatusCode: http.StatusNotModified, Headers: data.Headers})
+			return
+		}
+		data.Results = rh.applyQueryOptions(reqCtx, query, data.Results)
+	}
+
+	rh.writeResponse(w, r, data)
+}
+
+// allMethods is the order methods are listed in the Allow header
+var allMethods = []string{"GET", "HEAD", "OPTIONS", "PUT", "PATCH", "POST", "DELETE"}
+
+// commandMethod maps the http method to the command that handles it. HEAD
+// and OPTIONS are run by the handler using the GET command and privileges.
+func commandMethod(method string) string {
+	switch method {
+	case "HEAD", "OPTIONS":
+		return "GET"
+	}
+	return method
+}
+
+// commandSearchPath is the list of command types that are tried, in order,
+// to find the command that handles the method for the resource.
+func commandSearchPath(redfishResource *RedfishResourceAggregate, method string) []eh.CommandType {
 	search := []eh.CommandType{}
+	if redfishResource != nil {
+		// prepend the plugins to the search path
+		search = append(search, eh.CommandType(redfishResource.ResourceURI+":"+method))
+		search = append(search, eh.CommandType(redfishResource.GetProperty("@odata.type").(string)+":"+method))
+		search = append(search, eh.CommandType(redfishResource.GetProperty("@odata.context").(string)+":"+method))
+		search = append(search, eh.CommandType(redfishResource.Plugin+":"+method))
+	}
+	search = append(search, eh.CommandType("http:RedfishResource:"+method))
+	return search
+}
+
+// findCommand creates the first command in the search path that exists
+func findCommand(redfishResource *RedfishResourceAggregate, method string) (cmd eh.Command) {
+	for _, cmdType := range commandSearchPath(redfishResource, method) {
+		cmd, err := eh.CreateCommand(cmdType)
+		if err == nil {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// privilegeList converts Privileges from []interface{} to []string (way more
+// code than there should be for something this simple)
+func privilegeList(privs interface{}) (t []string) {
+	switch privs := privs.(type) {
+	case []string:
+		t = append(t, privs...)
+	case []interface{}:
+		for _, v := range privs {
+			if a, ok := v.(string); ok {
+				t = append(t, a)
+			}
+		}
+	default:
+	}
+	return
+}
+
+// allowedMethods returns the methods for the Allow header: those that the
+// PrivilegeMap grants to anybody and that have a command to handle them.
+func (rh *RedfishHandler) allowedMethods(ctx context.Context, uri string) []string {
+	aggID, ok := rh.d.GetAggregateIDOK(uri)
+	if !ok {
+		return nil
+	}
+	agg, _ := rh.d.AggregateStore.Load(ctx, AggregateType, aggID)
+	redfishResource, ok := agg.(*RedfishResourceAggregate)
+	if !ok {
+		return nil
+	}
+
+	allowed := []string{}
+	for _, method := range allMethods {
+		m := commandMethod(method)
+		if len(privilegeList(redfishResource.PrivilegeMap[m])) == 0 {
+			continue
+		}
+		cmd := findCommand(redfishResource, m)
+		if cmd == nil || notDeletable(redfishResource, cmd) {
+			continue
+		}
+		allowed = append(allowed, method)
+	}
+	return allowed
+}
+
+// notDeletable is true if the command is the standard DELETE, and the resource
+// isn't marked deletable. Resources with their own DELETE command decide for
+// themselves.
+func notDeletable(redfishResource *RedfishResourceAggregate, cmd eh.Command) bool {
+	return cmd.CommandType() == DELETECommand && !redfishResource.IsDeletable()
+}
+
+// prepareCommand looks up the resource at uri, finds the command to run for
+// the method and checks authorization.
+func (rh *RedfishHandler) prepareCommand(reqCtx context.Context, cmdID eh.UUID, method, uri string) (cmd eh.Command, rerr *RedfishError) {
+	// All operations have to be on URLs that exist, so look it up in the tree
+	aggID, ok := rh.d.GetAggregateIDOK(uri)
+	if !ok {
+		return nil, NewRedfishError(http.StatusNotFound, "ResourceMissingAtURI", uri)
+	}
 
 	// load the aggregate for the URL we are operating on
-	agg, err := rh.d.AggregateStore.Load(reqCtx, AggregateType, aggID)
+	agg, _ := rh.d.AggregateStore.Load(reqCtx, AggregateType, aggID)
 	// type assertion to get real aggregate
 	redfishResource, ok := agg.(*RedfishResourceAggregate)
-	if ok {
-		// prepend the plugins to the search path
-		search = append(search, eh.CommandType(redfishResource.ResourceURI+":"+r.Method))
-		search = append(search, eh.CommandType(redfishResource.GetProperty("@odata.type").(string)+":"+r.Method))
-		search = append(search, eh.CommandType(redfishResource.GetProperty("@odata.context").(string)+":"+r.Method))
-		search = append(search, eh.CommandType(redfishResource.Plugin+":"+r.Method))
+	if !ok {
+		return nil, NewRedfishError(http.StatusInternalServerError, "InternalError")
 	}
-	search = append(search, eh.CommandType("http:RedfishResource:"+r.Method))
 
 	// search through the commands until we find one that exists
-	var cmd eh.Command
-	for _, cmdType := range search {
-		cmd, err = eh.CreateCommand(cmdType)
-		if err == nil {
-			break
-		}
-	}
+	cmd = findCommand(redfishResource, method)
 
 	// with a proper error if we couldnt create a command of any kind
 	if cmd == nil {
-		http.Error(w, "could not create command", http.StatusBadRequest)
-		return
+		return nil, NewRedfishError(http.StatusMethodNotAllowed, "ActionNotSupported", method)
+	}
+	if notDeletable(redfishResource, cmd) {
+		return nil, NewRedfishError(http.StatusMethodNotAllowed, "ResourceCannotBeDeleted")
+	}
+	// nobody can do it, same as allowedMethods leaves it out of Allow
+	if len(privilegeList(redfishResource.PrivilegeMap[method])) == 0 {
+		return nil, NewRedfishError(http.StatusMethodNotAllowed, "ActionNotSupported", method)
 	}
 
 	// some optional interfaces that the commands might implement
@@ -114,6 +259,9 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if t, ok := cmd.(AggIDSetter); ok {
 		t.SetAggID(aggID)
 	}
+	if t, ok := cmd.(DomainObjectsSetter); ok {
+		t.SetDomainObjects(rh.d)
+	}
 
 	// Choices: command can process Authorization, or we can process authorization, or both
 	// If command implements UserDetailsSetter interface, we'll go ahead and call that.
@@ -125,28 +273,30 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	// if command does not implement userdetails setter, we always check privs here
 	if !implementsAuthorization || authAction == "checkMaster" {
-		privsToCheck := redfishResource.PrivilegeMap[r.Method]
-
-		// convert Privileges from []interface{} to []string (way more code than there should be for something this simple)
-		var t []string
-		switch privs := privsToCheck.(type) {
-		case []string:
-			t = append(t, privs...)
-		case []interface{}:
-			for _, v := range privs {
-				if a, ok := v.(string); ok {
-					t = append(t, a)
-				}
-			}
-		default:
+		authAction = rh.isAuthorized(privilegeList(redfishResource.PrivilegeMap[method]))
+	}
+
+	if authAction != "authorized" {
+		if rh.isAuthenticated() {
+			return nil, NewRedfishError(http.StatusForbidden, "InsufficientPrivilege")
 		}
+		return nil, NewRedfishError(http.StatusUnauthorized, "NoValidSession")
+	}
 
-		authAction = rh.isAuthorized(t)
+	if method == "DELETE" && rh.d.ChildDeletePolicy == RefuseDeleteWithChildren && len(rh.d.Children(uri)) > 0 {
+		return nil, NewRedfishError(http.StatusConflict, "ResourceInUse")
 	}
 
-	if authAction != "authorized" {
-		http.Error(w, "Not authorized to access this resource: ", http.StatusUnauthorized)
-		return
+	return cmd, nil
+}
+
+// runCommand prepares the command for the method on the resource at uri, and
+// then waits for it to be processed. The http request is only used for
+// commands that parse the body, internal sub-requests (ie. $expand) pass nil.
+func (rh *RedfishHandler) runCommand(reqCtx context.Context, cmdID eh.UUID, method, uri string, r *http.Request) (data HTTPCmdProcessedData, rerr *RedfishError) {
+	cmd, rerr := rh.prepareCommand(reqCtx, cmdID, method, uri)
+	if rerr != nil {
+		return data, rerr
 	}
 
 	// to avoid races, set up our listener first
@@ -162,38 +312,126 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return false
 	})
 	if err != nil {
-		http.Error(w, "could not create waiter"+err.Error(), http.StatusInternalServerError)
-		return
+		rh.logger.Error("could not create waiter", "err", err)
+		return data, NewRedfishError(http.StatusInternalServerError, "InternalError")
 	}
-	defer l.Close()
+	// the listener belongs to the task if we hand off to one
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			l.Close()
+		}
+	}()
 
 	// don't run parse until after privilege checks have been done
-	if t, ok := cmd.(HTTPParser); ok {
+	if t, ok := cmd.(HTTPParser); ok && r != nil {
 		err := t.ParseHTTPRequest(r)
 		if err != nil {
-			http.Error(w, "Problems parsing http request: "+err.Error(), http.StatusBadRequest)
-			return
+			return data, AsRedfishError(err, http.StatusBadRequest)
+		}
+	}
+	if t, ok := cmd.(ActionParameterGetter); ok {
+		if rerr := rh.checkActionParameters(reqCtx, uri, t.ActionParameters()); rerr != nil {
+			return data, rerr
 		}
 	}
 
 	ctx := WithRequestID(context.Background(), cmdID)
+	if r != nil {
+		switch method {
+		case "PATCH", "PUT", "DELETE":
+			if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+				ctx = withIfMatch(ctx, ifMatch)
+			}
+		}
+	}
 	if err := rh.d.CommandHandler.HandleCommand(ctx, cmd); err != nil {
-		http.Error(w, "redfish handler could not handle command (type: "+string(cmd.CommandType())+"): "+err.Error(), http.StatusBadRequest)
-		return
+		rh.logger.Info("redfish handler could not handle command", "type", cmd.CommandType(), "err", err)
+		return data, AsRedfishError(err, http.StatusBadRequest)
+	}
+
+	waitCtx := reqCtx
+	if rh.d.TaskThreshold > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(reqCtx, rh.d.TaskThreshold)
+		defer cancel()
 	}
 
-	event, err := l.Wait(reqCtx)
+	event, err := l.Wait(waitCtx)
+	if err != nil && waitCtx.Err() == context.DeadlineExceeded && reqCtx.Err() == nil {
+		// internal sub-requests just give up, real requests turn into a task
+		if r == nil {
+			return data, NewRedfishError(http.StatusInternalServerError, "InternalError")
+		}
+		rh.logger.Info("command is taking too long, starting task", "type", cmd.CommandType(), "uri", uri)
+		handedOff = true
+		return rh.startTask(cmdID, l, func(ctx context.Context, data HTTPCmdProcessedData) {
+			rh.commandDone(ctx, method, uri, data)
+		}), nil
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		rh.logger.Error("error waiting for command to be processed", "type", cmd.CommandType(), "err", err)
+		return data, NewRedfishError(http.StatusInternalServerError, "InternalError")
 	}
 
 	data, ok := event.Data().(HTTPCmdProcessedData)
 	if !ok {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return data, NewRedfishError(http.StatusInternalServerError, "InternalError")
 	}
+	// commands that don't say are successful
+	if data.StatusCode == 0 {
+		data.StatusCode = http.StatusOK
+	}
+
+	rh.commandDone(reqCtx, method, uri, data)
+	return data, nil
+}
+
+// succeeded is true for the responses of commands that are done and worked.
+// A 202 isn't one of them, the command is still running (ie. as a task).
+func succeeded(status int) bool {
+	return status >= 200 && status < 300 && status != http.StatusAccepted
+}
+
+// commandDone does what has to wait until the command has finished, which is
+// when the task completes for commands that were handed off to one.
+func (rh *RedfishHandler) commandDone(ctx context.Context, method, uri string, data HTTPCmdProcessedData) {
+	if method == "DELETE" && succeeded(data.StatusCode) && rh.d.ChildDeletePolicy == CascadeDelete {
+		rh.removeChildren(ctx, uri)
+	}
+}
+
+// removeChildren cleans up everything under a resource that was deleted
+func (rh *RedfishHandler) removeChildren(ctx context.Context, uri string) {
+	for _, child := range rh.d.Children(uri) {
+		aggID, ok := rh.d.GetAggregateIDOK(child)
+		if !ok {
+			continue
+		}
+		err := rh.d.CommandHandler.HandleCommand(ctx, &RemoveRedfishResource{ID: aggID, ResourceURI: child})
+		if err != nil {
+			rh.logger.Warn("could not remove child of deleted resource", "uri", uri, "child", child, "err", err)
+		}
+	}
+}
+
+// isAuthenticated is used to pick between 401 and 403 when authorization
+// fails: anybody that has more than the "Unauthenticated" privilege has
+// successfully logged in some way.
+func (rh *RedfishHandler) isAuthenticated() bool {
+	for _, p := range rh.Privileges {
+		if p != "Unauthenticated" {
+			return true
+		}
+	}
+	return false
+}
 
+func (rh *RedfishHandler) writeError(w http.ResponseWriter, r *http.Request, err *RedfishError) {
+	rh.writeResponse(w, r, HTTPCmdProcessedData{Results: err, StatusCode: err.StatusCode})
+}
+
+func (rh *RedfishHandler) writeResponse(w http.ResponseWriter, r *http.Request, data HTTPCmdProcessedData) {
 	// set headers first
 	w.Header().Add("Strict-Transport-Security", "max-age=63072000; includeSubDomains") // for A+ SSL Labs score
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -204,9 +442,34 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add(k, v)
 	}
 
-	// and then encode response
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	enc.Encode(data.Results)
-	return
+	// commands that never set a status are successful
+	if data.StatusCode == 0 {
+		data.StatusCode = http.StatusOK
+	}
+	// RFC 7235 requires a challenge with every 401
+	if data.StatusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Redfish"`)
+	}
+	// encode first so that we can send Content-Length. Responses without
+	// results (304, OPTIONS) have no body.
+	body := &bytes.Buffer{}
+	if data.Results != nil {
+		enc := json.NewEncoder(body)
+		enc.SetIndent("", "  ")
+		enc.Encode(localizeResults(data.Results, r.Header.Get("Accept-Language")))
+	}
+	if data.StatusCode != http.StatusNotModified {
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	}
+
+	w.WriteHeader(data.StatusCode)
+	w.Write(body.Bytes())
 }
+
+// headResponseWriter drops the body so that HEAD gets the same headers
+// (including Content-Length) as the GET would have.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (h headResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
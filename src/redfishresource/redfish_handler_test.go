@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/superchalupa/go-redfish/src/log"
+)
+
+// testLogger throws everything away
+type testLogger struct{}
+
+func (l testLogger) New(ctx ...interface{}) log.Logger    { return l }
+func (l testLogger) Debug(msg string, ctx ...interface{}) {}
+func (l testLogger) Info(msg string, ctx ...interface{})  {}
+func (l testLogger) Warn(msg string, ctx ...interface{})  {}
+func (l testLogger) Error(msg string, ctx ...interface{}) {}
+func (l testLogger) Crit(msg string, ctx ...interface{})  {}
+
+const testPluginType = PluginType("test")
+
+// testPlugin sets the properties that have it as their PATCH plugin to
+// whatever was asked for
+type testPlugin struct{}
+
+func (testPlugin) PluginType() PluginType { return testPluginType }
+
+func (testPlugin) PropertyPatch(ctx context.Context, agg *RedfishResourceAggregate, rrp *RedfishResourceProperty, method string, meta map[string]interface{}, body interface{}, present bool) {
+	if present {
+		rrp.Value = body
+	}
+}
+
+// testPatchMeta makes a property writable with the test plugin
+var testPatchMeta = map[string]interface{}{"PATCH": map[string]interface{}{"plugin": string(testPluginType)}}
+
+var (
+	testDomain     *DomainObjects
+	testDomainOnce sync.Once
+)
+
+// newTestDomain returns the domain objects that all the tests share, the
+// commands and plugins can only be registered once. Tests use their own uris.
+func newTestDomain(t *testing.T) *DomainObjects {
+	testDomainOnce.Do(func() {
+		log.GlobalLogger = testLogger{}
+		d, err := NewDomainObjects()
+		if err != nil {
+			return
+		}
+		InitDomain(context.Background(), d.CommandHandler, d.EventBus, d.EventWaiter)
+		RegisterPlugin(func() Plugin { return testPlugin{} })
+		testDomain = d
+	})
+	if testDomain == nil {
+		t.Fatal("could not set up the domain objects")
+	}
+	return testDomain
+}
+
+// the privileges of the test user
+var testUserPrivileges = []string{"Login", "ConfigureManager"}
+
+// createResource creates the resource, with anything that isn't filled in
+// set to something that works, and waits until it is in the tree
+func createResource(t *testing.T, d *DomainObjects, c *CreateRedfishResource) {
+	if c.ID == "" {
+		c.ID = eh.NewUUID()
+	}
+	if c.Type == "" {
+		c.Type = "#Test.v1_0_0.Test"
+	}
+	if c.Context == "" {
+		c.Context = "/redfish/v1/$metadata#Test.Test"
+	}
+	if c.Privileges == nil {
+		c.Privileges = map[string]interface{}{
+			"GET":   []string{"Login"},
+			"PATCH": []string{"ConfigureManager"},
+			"PUT":   []string{"ConfigureManager"},
+		}
+	}
+	if err := d.CommandHandler.HandleCommand(context.Background(), c); err != nil {
+		t.Fatalf("creating %s: %s", c.ResourceURI, err)
+	}
+	waitFor(t, c.ResourceURI+" to be created", func() bool { return d.HasAggregateID(c.ResourceURI) })
+	if c.Collection {
+		waitFor(t, c.ResourceURI+" to be a collection", func() bool {
+			d.collectionsMu.RLock()
+			defer d.collectionsMu.RUnlock()
+			for _, uri := range d.collections {
+				if uri == c.ResourceURI {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// createCollection creates a collection and its members, and waits until they
+// are all in its Members
+func createCollection(t *testing.T, d *DomainObjects, uri string, members map[string]map[string]interface{}) {
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Collection: true, Properties: map[string]interface{}{"Name": uri}})
+	for id, props := range members {
+		createResource(t, d, &CreateRedfishResource{ResourceURI: uri + "/" + id, Properties: props})
+	}
+	waitFor(t, uri+" to have its members", func() bool {
+		_, body := request(t, d, "GET", uri, "")
+		list, _ := body["Members"].([]interface{})
+		return len(list) == len(members)
+	})
+}
+
+// waitFor waits for things that happen when events are handled
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// request runs a request as the test user, headers are name, value pairs. The
+// body of the response is decoded if it is json.
+func request(t *testing.T, d *DomainObjects, method, uri, body string, headers ...string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	NewRedfishHandler(d, testLogger{}, "tester", testUserPrivileges).ServeHTTP(w, r)
+
+	var decoded map[string]interface{}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s: response isn't json: %s\n%s", method, uri, err, w.Body)
+		}
+	}
+	return w, decoded
+}
+
+// lookup walks down a decoded response, ie. "Members/0/Name". nil if it isn't there.
+func lookup(v interface{}, path string) interface{} {
+	for _, p := range strings.Split(path, "/") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			v = t[p]
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			v = t[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// messageIDs returns the MessageIds in the error, or the @Message.ExtendedInfo
+// of a response, without the registry prefix
+func messageIDs(body map[string]interface{}) []string {
+	info, ok := lookup(body, "error/@Message.ExtendedInfo").([]interface{})
+	if !ok {
+		info, _ = body["@Message.ExtendedInfo"].([]interface{})
+	}
+	ids := []string{}
+	for _, m := range info {
+		id, _ := lookup(m, "MessageId").(string)
+		if i := strings.LastIndex(id, "."); i >= 0 {
+			id = id[i+1:]
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
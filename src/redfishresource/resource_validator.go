@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ResourceValidator checks resources against their schema. It gets the
+// resource rendered as plain json types. Paths (ie. "/Status/Health") in
+// dynamic are filled in by plugins at GET time, paths in writable have a
+// PATCH plugin. It returns a description of each problem found.
+type ResourceValidator interface {
+	ValidateResource(odataType string, resource map[string]interface{}, dynamic, writable map[string]bool) []string
+}
+
+type ValidationMode int
+
+const (
+	// ValidationLog logs the problems (dev mode)
+	ValidationLog ValidationMode = iota
+	// ValidationStrict also refuses to create the resource, or to return the GET
+	ValidationStrict
+)
+
+var resourceValidator ResourceValidator
+var resourceValidationMode ValidationMode
+var resourceValidatorMu sync.RWMutex
+
+// SetResourceValidator turns on checking of every CreateRedfishResource and
+// every GET result. nil turns it off.
+func SetResourceValidator(v ResourceValidator, mode ValidationMode) {
+	resourceValidatorMu.Lock()
+	defer resourceValidatorMu.Unlock()
+	resourceValidator = v
+	resourceValidationMode = mode
+}
+
+func getResourceValidator() (ResourceValidator, ValidationMode) {
+	resourceValidatorMu.RLock()
+	defer resourceValidatorMu.RUnlock()
+	return resourceValidator, resourceValidationMode
+}
+
+// validateResource logs the problems with the resource, and returns an error
+// if there are any and we are in strict mode.
+func validateResource(ctx context.Context, uri, odataType string, resource map[string]interface{}, dynamic, writable map[string]bool) error {
+	v, mode := getResourceValidator()
+	if v == nil {
+		return nil
+	}
+
+	problems := v.ValidateResource(odataType, resource, dynamic, writable)
+	if len(problems) == 0 {
+		return nil
+	}
+	logger := ContextLogger(ctx, "schema")
+	for _, p := range problems {
+		logger.Warn("Resource does not match schema", "uri", uri, "type", odataType, "problem", p)
+	}
+	if mode == ValidationStrict {
+		return NewRedfishError(http.StatusInternalServerError, "InternalError")
+	}
+	return nil
+}
+
+// validationInput renders the properties from a CreateRedfishResource for
+// validation. Properties with @meta get their values from plugins, so they
+// are only recorded in dynamic (and writable if they have a PATCH plugin).
+func validationInput(props map[string]interface{}, path string, dynamic, writable map[string]bool) map[string]interface{} {
+	resource := map[string]interface{}{}
+	for k, v := range props {
+		if strings.HasSuffix(k, "@meta") {
+			name := path + "/" + strings.TrimSuffix(k, "@meta")
+			dynamic[name] = true
+			if meta, ok := v.(map[string]interface{}); ok {
+				if _, ok := meta["PATCH"]; ok {
+					writable[name] = true
+				}
+			}
+			continue
+		}
+		if sub, ok := v.(map[string]interface{}); ok {
+			resource[k] = validationInput(sub, path+"/"+k, dynamic, writable)
+			continue
+		}
+		generic, err := normalizeResults(v)
+		if err != nil {
+			continue
+		}
+		resource[k] = generic
+	}
+	return resource
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/superchalupa/go-redfish/src/schema"
+)
+
+func TestValidationInput(t *testing.T) {
+	dynamic, writable := map[string]bool{}, map[string]bool{}
+	resource := validationInput(map[string]interface{}{
+		"Name":            "x",
+		"AssetTag@meta":   testPatchMeta,
+		"PowerState@meta": map[string]interface{}{"GET": map[string]interface{}{"plugin": "power"}},
+		"Status": map[string]interface{}{
+			"Health":     "OK",
+			"State@meta": map[string]interface{}{"GET": map[string]interface{}{"plugin": "state"}},
+		},
+		"Links": map[string]interface{}{"Chassis": []map[string]interface{}{{"@odata.id": "/redfish/v1/Chassis/1"}}},
+	}, "", dynamic, writable)
+
+	expected := map[string]interface{}{
+		"Name":   "x",
+		"Status": map[string]interface{}{"Health": "OK"},
+		"Links":  map[string]interface{}{"Chassis": []interface{}{map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/1"}}},
+	}
+	if !reflect.DeepEqual(resource, expected) {
+		t.Errorf("resource is %v", resource)
+	}
+	if expected := map[string]bool{"/AssetTag": true, "/PowerState": true, "/Status/State": true}; !reflect.DeepEqual(dynamic, expected) {
+		t.Errorf("dynamic is %v", dynamic)
+	}
+	if expected := map[string]bool{"/AssetTag": true}; !reflect.DeepEqual(writable, expected) {
+		t.Errorf("writable is %v", writable)
+	}
+}
+
+func TestResourceValidation(t *testing.T) {
+	d := newTestDomain(t)
+	schemas, err := schema.Load("../../v1/schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetResourceValidator(schemas, ValidationStrict)
+	defer SetResourceValidator(nil, ValidationLog)
+
+	chassis := func(uri string, changes map[string]interface{}, remove []string) *CreateRedfishResource {
+		props := map[string]interface{}{
+			"Id":          "1",
+			"Name":        "Chassis",
+			"ChassisType": "RackMount",
+			"Status":      map[string]interface{}{"State": "Enabled", "Health": "OK"},
+			"Links":       map[string]interface{}{},
+		}
+		for k, v := range changes {
+			props[k] = v
+		}
+		for _, k := range remove {
+			delete(props, k)
+		}
+		return &CreateRedfishResource{ID: eh.NewUUID(), ResourceURI: uri, Type: "#Chassis.v1_0_0.Chassis", Context: "/redfish/v1/$metadata#Chassis.Chassis",
+			Privileges: map[string]interface{}{"GET": []string{"Login"}}, Properties: props}
+	}
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		remove  []string
+		ok      bool
+	}{
+		{"valid", nil, nil, true},
+		{"wrong type", map[string]interface{}{"Name": 1}, nil, false},
+		{"missing required", nil, []string{"ChassisType"}, false},
+		{"filled in by a plugin", map[string]interface{}{"ChassisType@meta": map[string]interface{}{"GET": map[string]interface{}{"plugin": "x"}}}, []string{"ChassisType"}, true},
+		{"bad enum", map[string]interface{}{"ChassisType": "Spaceship"}, nil, false},
+	}
+	for i, tc := range tests {
+		err := d.CommandHandler.HandleCommand(context.Background(), chassis("/redfish/v1/Validate/"+strconv.Itoa(i), tc.changes, tc.remove))
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: error %v", tc.name, err)
+		}
+	}
+
+	// results are checked too
+	uri := "/redfish/v1/Validate/0"
+	waitFor(t, uri+" to be created", func() bool { return d.HasAggregateID(uri) })
+	if w, _ := request(t, d, "GET", uri, ""); w.Code != http.StatusOK {
+		t.Errorf("GET valid resource: status %d\n%s", w.Code, w.Body)
+	}
+	aggID, _ := d.GetAggregateIDOK(uri)
+	d.CommandHandler.HandleCommand(context.Background(), &UpdateRedfishResourceProperties{ID: aggID, Properties: map[string]interface{}{"ChassisType": "Spaceship"}})
+	waitFor(t, "the GET to fail", func() bool {
+		w, _ := request(t, d, "GET", uri, "")
+		return w.Code == http.StatusInternalServerError
+	})
+
+	// only logged when not strict
+	SetResourceValidator(schemas, ValidationLog)
+	if w, _ := request(t, d, "GET", uri, ""); w.Code != http.StatusOK {
+		t.Errorf("GET in log mode: status %d", w.Code)
+	}
+}
@@ -0,0 +1,218 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// propertySelector decides which properties of a resource are processed and
+// returned for a GET. It's used for $select and for excerpt. Properties that
+// aren't selected don't have their plugins run.
+type propertySelector interface {
+	// child returns whether the named property is selected and the selector
+	// that applies to its children (nil selects all children)
+	child(name string, rrp *RedfishResourceProperty) (selected bool, sub propertySelector)
+}
+
+type selectorKeyType int
+
+const selectorKey selectorKeyType = iota
+
+// withPropertySelector returns a context that Process will use to skip properties
+func withPropertySelector(ctx context.Context, sel propertySelector) context.Context {
+	return context.WithValue(ctx, selectorKey, sel)
+}
+
+func selectorFromContext(ctx context.Context) propertySelector {
+	if ctx == nil {
+		return nil
+	}
+	sel, _ := ctx.Value(selectorKey).(propertySelector)
+	return sel
+}
+
+// these are always returned, no matter what was selected
+func alwaysSelected(name string) bool {
+	switch name {
+	case "@odata.id", "@odata.type", "@odata.context", "@odata.etag":
+		return true
+	}
+	return false
+}
+
+// selectTree is the parsed form of $select=Name,Status/Health. A nil subtree
+// means the whole property was selected.
+type selectTree map[string]selectTree
+
+func parseSelect(sel string) (selectTree, *RedfishError) {
+	tree := selectTree{}
+	for _, item := range strings.Split(sel, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, NewRedfishError(http.StatusBadRequest, "QueryParameterValueFormatError", sel, "$select")
+		}
+
+		t := tree
+		parts := strings.Split(item, "/")
+		for i, p := range parts {
+			if p == "" {
+				return nil, NewRedfishError(http.StatusBadRequest, "QueryParameterValueFormatError", sel, "$select")
+			}
+			sub, ok := t[p]
+			if ok && sub == nil {
+				// already selected the whole thing
+				break
+			}
+			if i == len(parts)-1 {
+				t[p] = nil
+				break
+			}
+			if !ok {
+				sub = selectTree{}
+				t[p] = sub
+			}
+			t = sub
+		}
+	}
+	return tree, nil
+}
+
+func (t selectTree) child(name string, rrp *RedfishResourceProperty) (bool, propertySelector) {
+	if alwaysSelected(name) {
+		return true, nil
+	}
+	// annotations go along with their property, ie. Members@odata.count
+	if i := strings.Index(name, "@"); i > 0 {
+		name = name[:i]
+	}
+	sub, ok := t[name]
+	if !ok {
+		return false, nil
+	}
+	if sub == nil {
+		return true, nil
+	}
+	return true, sub
+}
+
+// excerptSelector selects the properties that have "excerpt": true in their
+// meta, along with any objects that contain such properties.
+type excerptSelector struct{}
+
+func isExcerpt(rrp *RedfishResourceProperty) bool {
+	if rrp == nil {
+		return false
+	}
+	excerpt, _ := rrp.Meta["excerpt"].(bool)
+	return excerpt
+}
+
+func hasExcerpt(v interface{}) bool {
+	switch t := v.(type) {
+	case RedfishResourceProperty:
+		return isExcerpt(&t) || hasExcerpt(t.Value)
+	case map[string]interface{}:
+		for _, sub := range t {
+			if hasExcerpt(sub) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, sub := range t {
+			if hasExcerpt(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (excerptSelector) child(name string, rrp *RedfishResourceProperty) (bool, propertySelector) {
+	if alwaysSelected(name) || isExcerpt(rrp) {
+		return true, nil
+	}
+	if rrp != nil && hasExcerpt(rrp.Value) {
+		return true, excerptSelector{}
+	}
+	return false, nil
+}
+
+// parseSelector pulls $select or excerpt out of the query parameters. Returns
+// a nil selector if neither was requested.
+func parseSelector(values url.Values) (propertySelector, *RedfishError) {
+	sel, hasSelect := values["$select"]
+	_, hasExcerpt := values["excerpt"]
+
+	switch {
+	case hasSelect && hasExcerpt:
+		return nil, NewRedfishError(http.StatusBadRequest, "QueryCombinationInvalid")
+	case hasSelect && len(sel) > 0:
+		tree, err := parseSelect(sel[0])
+		if err != nil {
+			return nil, err
+		}
+		return tree, nil
+	case hasExcerpt:
+		return excerptSelector{}, nil
+	}
+	return nil, nil
+}
+
+// pruneProperty returns a copy of the processed property containing only the
+// selected parts. The input is not modified, it is what gets saved back into
+// the aggregate.
+func pruneProperty(rrp RedfishResourceProperty, sel propertySelector) RedfishResourceProperty {
+	if sel == nil {
+		return rrp
+	}
+
+	ret := RedfishResourceProperty{Meta: rrp.Meta}
+	switch t := rrp.Value.(type) {
+	case map[string]interface{}:
+		newMap := map[string]interface{}{}
+		for k, v := range t {
+			var vrrp *RedfishResourceProperty
+			if r, ok := v.(RedfishResourceProperty); ok {
+				vrrp = &r
+			}
+			selected, sub := sel.child(k, vrrp)
+			if !selected {
+				continue
+			}
+			newMap[k] = pruneValue(v, sub)
+		}
+		ret.Value = newMap
+	case []interface{}:
+		newArr := []interface{}{}
+		for _, v := range t {
+			newArr = append(newArr, pruneValue(v, sel))
+		}
+		ret.Value = newArr
+	case []map[string]interface{}:
+		// ie. Members, keep the type
+		newArr := []map[string]interface{}{}
+		for _, m := range t {
+			pruned, _ := pruneProperty(RedfishResourceProperty{Value: m}, sel).Value.(map[string]interface{})
+			newArr = append(newArr, pruned)
+		}
+		ret.Value = newArr
+	default:
+		ret.Value = rrp.Value
+	}
+	return ret
+}
+
+func pruneValue(v interface{}, sel propertySelector) interface{} {
+	if sel == nil {
+		return v
+	}
+	switch t := v.(type) {
+	case RedfishResourceProperty:
+		return pruneProperty(t, sel)
+	case map[string]interface{}:
+		return pruneProperty(RedfishResourceProperty{Value: t}, sel).Value
+	}
+	return v
+}
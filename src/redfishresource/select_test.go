@@ -0,0 +1,266 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+const countingPluginType = PluginType("test_counting")
+
+// countingPlugin keeps the names of the properties it is run for
+type countingPlugin struct {
+	sync.Mutex
+	ran map[string]int
+}
+
+func (p *countingPlugin) PluginType() PluginType { return countingPluginType }
+
+func (p *countingPlugin) PropertyGet(ctx context.Context, agg *RedfishResourceAggregate, rrp *RedfishResourceProperty, method string, meta map[string]interface{}) {
+	p.Lock()
+	defer p.Unlock()
+	name, _ := meta["name"].(string)
+	p.ran[name]++
+}
+
+func (p *countingPlugin) runs() map[string]int {
+	p.Lock()
+	defer p.Unlock()
+	ran := p.ran
+	p.ran = map[string]int{}
+	return ran
+}
+
+var (
+	counting     = &countingPlugin{ran: map[string]int{}}
+	countingOnce sync.Once
+)
+
+// countingMeta runs the counting plugin for GET
+func countingMeta(name string) map[string]interface{} {
+	return map[string]interface{}{"GET": map[string]interface{}{"plugin": string(countingPluginType), "name": name}}
+}
+
+func TestParseSelect(t *testing.T) {
+	tests := []struct {
+		sel  string
+		tree selectTree
+	}{
+		{"Name", selectTree{"Name": nil}},
+		{"Name, Status/Health", selectTree{"Name": nil, "Status": selectTree{"Health": nil}}},
+		{"Status/Health,Status/State", selectTree{"Status": selectTree{"Health": nil, "State": nil}}},
+		// the whole thing wins, whichever order they come in
+		{"Status,Status/Health", selectTree{"Status": nil}},
+		{"Status/Health,Status", selectTree{"Status": nil}},
+		{"A/B/C", selectTree{"A": selectTree{"B": selectTree{"C": nil}}}},
+		{"", nil},
+		{"Name,", nil},
+		{"Status//Health", nil},
+		{"/Name", nil},
+	}
+	for _, tc := range tests {
+		tree, err := parseSelect(tc.sel)
+		if tc.tree == nil {
+			if err == nil {
+				t.Errorf("%q parsed as %v", tc.sel, tree)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tc.sel, err)
+			continue
+		}
+		if !reflect.DeepEqual(tree, tc.tree) {
+			t.Errorf("%q parsed as %v, expected %v", tc.sel, tree, tc.tree)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		query string
+		sel   propertySelector
+		err   string
+	}{
+		{"", nil, ""},
+		{"$select=Name", selectTree{"Name": nil}, ""},
+		{"excerpt", excerptSelector{}, ""},
+		{"$select=Name&excerpt", nil, "QueryCombinationInvalid"},
+		{"$select=", nil, "QueryParameterValueFormatError"},
+	}
+	for _, tc := range tests {
+		values, _ := url.ParseQuery(tc.query)
+		sel, rerr := parseSelector(values)
+		if tc.err != "" {
+			if rerr == nil || len(rerr.ExtendedInfo) != 1 || rerr.ExtendedInfo[0].MessageID != tc.err {
+				t.Errorf("%q: error %v, expected %s", tc.query, rerr, tc.err)
+			}
+			continue
+		}
+		if rerr != nil || !reflect.DeepEqual(sel, tc.sel) {
+			t.Errorf("%q: %v %v, expected %v", tc.query, sel, rerr, tc.sel)
+		}
+	}
+}
+
+func TestPruneProperty(t *testing.T) {
+	list := func(names ...string) []map[string]interface{} {
+		ret := []map[string]interface{}{}
+		for _, name := range names {
+			ret = append(ret, map[string]interface{}{
+				"Name":    RedfishResourceProperty{Value: name},
+				"Reading": RedfishResourceProperty{Value: 1},
+			})
+		}
+		return ret
+	}
+	rrp := RedfishResourceProperty{Value: map[string]interface{}{
+		"Sensors": RedfishResourceProperty{Value: list("a", "b")},
+		"Other":   RedfishResourceProperty{Value: []interface{}{map[string]interface{}{"Name": "c", "Reading": 2}}},
+	}}
+	pruned := pruneProperty(rrp, selectTree{"Sensors": selectTree{"Name": nil}, "Other": selectTree{"Name": nil}})
+
+	sensors, ok := pruned.Value.(map[string]interface{})["Sensors"].(RedfishResourceProperty).Value.([]map[string]interface{})
+	if !ok || len(sensors) != 2 {
+		t.Fatalf("Sensors is %#v", pruned.Value)
+	}
+	for i, name := range []string{"a", "b"} {
+		if !reflect.DeepEqual(sensors[i], map[string]interface{}{"Name": RedfishResourceProperty{Value: name}}) {
+			t.Errorf("Sensors/%d is %v", i, sensors[i])
+		}
+	}
+	other, _ := pruned.Value.(map[string]interface{})["Other"].(RedfishResourceProperty).Value.([]interface{})
+	if !reflect.DeepEqual(other, []interface{}{map[string]interface{}{"Name": "c"}}) {
+		t.Errorf("Other is %v", other)
+	}
+
+	// the input is what is saved back, it keeps everything
+	if sensors := rrp.Value.(map[string]interface{})["Sensors"].(RedfishResourceProperty).Value; !reflect.DeepEqual(sensors, list("a", "b")) {
+		t.Errorf("pruning changed the input: %v", sensors)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	d := newTestDomain(t)
+	countingOnce.Do(func() { RegisterPlugin(func() Plugin { return counting }) })
+	createResource(t, d, &CreateRedfishResource{ResourceURI: "/redfish/v1/Select", Properties: map[string]interface{}{
+		"Name":         "Select",
+		"Name@meta":    map[string]interface{}{"excerpt": true},
+		"Description":  "not selected",
+		"Reading":      1,
+		"Reading@meta": countingMeta("Reading"),
+		"Status":       map[string]interface{}{"Health": "OK", "State": "Enabled", "Reason@meta": countingMeta("Status/Reason"), "Reason": ""},
+		"Location":     map[string]interface{}{"Rack": "1", "Rack@meta": map[string]interface{}{"excerpt": true}, "Slot": 2},
+		"Links":        map[string]interface{}{"Chassis": []interface{}{link("/redfish/v1/Chassis/1")}},
+		"Links@meta":   countingMeta("Links"),
+		"Oem":          map[string]interface{}{},
+	}})
+	createCollection(t, d, "/redfish/v1/SelectCollection", map[string]map[string]interface{}{"1": {"Name": "1"}})
+	counting.runs()
+
+	always := []string{"@odata.context", "@odata.id", "@odata.type"}
+	tests := []struct {
+		name  string
+		uri   string
+		props []string
+		paths map[string]interface{}
+		ran   []string
+	}{
+		{"everything", "/redfish/v1/Select",
+			[]string{"Name", "Description", "Reading", "Status", "Location", "Links", "Oem"},
+			nil, []string{"Links", "Reading", "Status/Reason"}},
+		{"one", "/redfish/v1/Select?$select=Name",
+			[]string{"Name"}, nil, []string{}},
+		{"plugin", "/redfish/v1/Select?$select=Reading,Description",
+			[]string{"Reading", "Description"}, nil, []string{"Reading"}},
+		{"inside", "/redfish/v1/Select?$select=Status/Health",
+			[]string{"Status"}, map[string]interface{}{"Status/Health": "OK", "Status/State": nil, "Status/Reason": nil}, []string{}},
+		{"inside plugin", "/redfish/v1/Select?$select=Status/Reason,Status/State",
+			[]string{"Status"}, map[string]interface{}{"Status/Health": nil, "Status/State": "Enabled", "Status/Reason": ""}, []string{"Status/Reason"}},
+		{"whole", "/redfish/v1/Select?$select=Status/Health,Status",
+			[]string{"Status"}, map[string]interface{}{"Status/Health": "OK", "Status/State": "Enabled"}, []string{"Status/Reason"}},
+		{"unknown", "/redfish/v1/Select?$select=Nope",
+			[]string{}, nil, []string{}},
+		{"excerpt", "/redfish/v1/Select?excerpt",
+			[]string{"Name", "Location"}, map[string]interface{}{"Location/Rack": "1", "Location/Slot": nil}, []string{}},
+		{"members annotations", "/redfish/v1/SelectCollection?$select=Members",
+			[]string{"Members", "Members@odata.count"}, nil, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w, body := request(t, d, "GET", tc.uri, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d\n%s", w.Code, w.Body)
+			}
+			props := []string{}
+			for k := range body {
+				props = append(props, k)
+			}
+			expected := append(append([]string{}, always...), tc.props...)
+			sort.Strings(props)
+			sort.Strings(expected)
+			if !reflect.DeepEqual(props, expected) {
+				t.Errorf("properties are %v, expected %v", props, expected)
+			}
+			for path, v := range tc.paths {
+				if got := lookup(body, path); !reflect.DeepEqual(got, v) {
+					t.Errorf("%s is %v, expected %v", path, got, v)
+				}
+			}
+
+			ran := counting.runs()
+			if tc.ran == nil {
+				return
+			}
+			names := []string{}
+			for name := range ran {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.ran) {
+				t.Errorf("plugins ran for %v, expected %v", names, tc.ran)
+			}
+		})
+	}
+
+	// the properties that weren't selected are still there afterwards
+	_, body := request(t, d, "GET", "/redfish/v1/Select", "")
+	if body["Description"] != "not selected" || lookup(body, "Status/State") != "Enabled" {
+		t.Errorf("$select changed the resource: %v", body)
+	}
+
+	w, body := request(t, d, "GET", "/redfish/v1/Select?$select=Name&excerpt", "")
+	if ids := messageIDs(body); w.Code != http.StatusBadRequest || len(ids) != 1 || ids[0] != "QueryCombinationInvalid" {
+		t.Errorf("$select with excerpt: status %d, messages %v", w.Code, ids)
+	}
+}
+
+func TestOnly(t *testing.T) {
+	d := newTestDomain(t)
+	createCollection(t, d, "/redfish/v1/OnlyOne", map[string]map[string]interface{}{"1": {"Name": "the one"}})
+	createCollection(t, d, "/redfish/v1/OnlyTwo", map[string]map[string]interface{}{"1": {"Name": "1"}, "2": {"Name": "2"}})
+
+	tests := []struct {
+		uri string
+		id  string
+	}{
+		{"/redfish/v1/OnlyOne?only", "/redfish/v1/OnlyOne/1"},
+		{"/redfish/v1/OnlyOne", "/redfish/v1/OnlyOne"},
+		{"/redfish/v1/OnlyTwo?only", "/redfish/v1/OnlyTwo"},
+		{"/redfish/v1/OnlyOne/1?only", "/redfish/v1/OnlyOne/1"},
+	}
+	for _, tc := range tests {
+		w, body := request(t, d, "GET", tc.uri, "")
+		if w.Code != http.StatusOK || body["@odata.id"] != tc.id {
+			t.Errorf("%s: status %d, got %v, expected %s", tc.uri, w.Code, body["@odata.id"], tc.id)
+		}
+	}
+	if _, body := request(t, d, "GET", "/redfish/v1/OnlyOne?only", ""); body["Name"] != "the one" {
+		t.Errorf("only: %v", body)
+	}
+}
@@ -0,0 +1,266 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/utils"
+)
+
+// Commands that take longer than DomainObjects.TaskThreshold to publish their
+// HTTPCmdProcessed event are turned into Tasks: the client gets a 202 with a
+// Location header pointing at the Task resource, and the Task tracks the
+// command until it finishes. The final response is kept, and is returned by a
+// GET on the task monitor (the TaskMonitor property of the Task). Only the
+// user who made the request, and managers, can see the Task and its result.
+
+const (
+	TaskCollectionURI = "/redfish/v1/TaskService/Tasks"
+
+	// Commands can publish this while they run to update PercentComplete and
+	// add Messages to the task for the command.
+	HTTPCmdProgress = eh.EventType("HTTPCmdProgress")
+
+	TaskMonitorPlugin = PluginType("TaskMonitor")
+
+	// the task life cycle messages are from this registry
+	TaskEventRegistryPrefix = "TaskEvent.1.0"
+)
+
+type HTTPCmdProgressData struct {
+	CommandID       eh.UUID
+	PercentComplete int
+	Messages        []ExtendedInfo
+}
+
+func init() {
+	eh.RegisterEventData(HTTPCmdProgress, func() eh.EventData { return &HTTPCmdProgressData{} })
+	RegisterInitFN(registerTaskMonitor)
+}
+
+func registerTaskMonitor(ctx context.Context, ch eh.CommandHandler, eb eh.EventBus, ew *utils.EventWaiter) {
+	eh.RegisterCommand(func() eh.Command { return &GETTaskMonitor{} })
+}
+
+// startTask creates the Task for a command that is taking too long, and
+// returns the 202 response for it. The listener is handed off to a
+// goroutine that waits for the command to finish, and then calls done with
+// the final response.
+func (rh *RedfishHandler) startTask(cmdID eh.UUID, l *utils.EventListener, done func(context.Context, HTTPCmdProcessedData)) HTTPCmdProcessedData {
+	ctx := WithRequestID(context.Background(), cmdID)
+
+	rh.d.tasksMu.Lock()
+	rh.d.taskCounter++
+	id := strconv.FormatUint(rh.d.taskCounter, 10)
+	taskURI := TaskCollectionURI + "/" + id
+	monitorURI := taskURI + "/Monitor"
+	rh.d.taskResults[monitorURI] = nil
+	rh.d.tasksMu.Unlock()
+	taskID := eh.NewUUID()
+	monitorID := eh.NewUUID()
+
+	props := map[string]interface{}{
+		"Id":              id,
+		"Name":            "Task " + id,
+		"TaskState":       "Running",
+		"TaskStatus":      "OK",
+		"StartTime":       time.Now().Format(time.RFC3339),
+		"PercentComplete": 0,
+		"Messages":        messageList([]ExtendedInfo{NewExtendedInfo(TaskEventRegistryPrefix+".TaskStarted", id)}),
+		"TaskMonitor":     monitorURI,
+	}
+	// the result can have anything in it that the request could see
+	privileges := map[string]interface{}{"GET": []string{"ConfigureSelf_" + rh.UserName, "ConfigureManager"}}
+
+	rh.d.CommandHandler.HandleCommand(ctx, &CreateRedfishResource{
+		ID:          taskID,
+		ResourceURI: taskURI,
+		Type:        "#Task.v1_0_0.Task",
+		Context:     "/redfish/v1/$metadata#Task.Task",
+		Privileges:  privileges,
+		Properties:  props,
+	})
+	rh.d.CommandHandler.HandleCommand(ctx, &CreateRedfishResource{
+		ID:          monitorID,
+		ResourceURI: monitorURI,
+		Type:        "#Task.v1_0_0.Task",
+		Context:     "/redfish/v1/$metadata#Task.Task",
+		Privileges:  privileges,
+		Plugin:      string(TaskMonitorPlugin),
+	})
+
+	progress, err := rh.d.EventWaiter.Listen(ctx, func(event eh.Event) bool {
+		if event.EventType() != HTTPCmdProgress {
+			return false
+		}
+		data, ok := event.Data().(HTTPCmdProgressData)
+		return ok && data.CommandID == cmdID
+	})
+	if err != nil {
+		progress = nil
+	}
+
+	go rh.waitTask(ctx, taskID, taskURI, monitorID, monitorURI, l, progress, done)
+
+	results := map[string]interface{}{}
+	for k, v := range props {
+		results[k] = v
+	}
+	results["@odata.id"] = taskURI
+	results["@odata.type"] = "#Task.v1_0_0.Task"
+	results["@odata.context"] = "/redfish/v1/$metadata#Task.Task"
+
+	return HTTPCmdProcessedData{
+		CommandID:  cmdID,
+		Results:    results,
+		StatusCode: http.StatusAccepted,
+		Headers:    map[string]string{"Location": taskURI},
+	}
+}
+
+func (rh *RedfishHandler) waitTask(ctx context.Context, taskID eh.UUID, taskURI string, monitorID eh.UUID, monitorURI string, l *utils.EventListener, progress *utils.EventListener, done func(context.Context, HTTPCmdProcessedData)) {
+	defer l.Close()
+	var progressInbox <-chan eh.Event
+	if progress != nil {
+		defer progress.Close()
+		progressInbox = progress.Inbox()
+	}
+
+	var timeout <-chan time.Time
+	if rh.d.TaskTimeout > 0 {
+		timeout = time.After(rh.d.TaskTimeout)
+	}
+
+	var result HTTPCmdProcessedData
+loop:
+	for {
+		select {
+		case event := <-l.Inbox():
+			data, ok := event.Data().(HTTPCmdProcessedData)
+			if !ok {
+				result = NewErrorResponse("", NewRedfishError(http.StatusInternalServerError, "InternalError"))
+			} else {
+				result = data
+			}
+			break loop
+
+		case event := <-progressInbox:
+			data, ok := event.Data().(HTTPCmdProgressData)
+			if !ok {
+				continue
+			}
+			rh.d.CommandHandler.HandleCommand(ctx, &UpdateRedfishResourceProperties{
+				ID: taskID,
+				Properties: map[string]interface{}{
+					"PercentComplete": data.PercentComplete,
+					"Messages":        messageList(data.Messages),
+				},
+			})
+
+		case <-timeout:
+			ContextLogger(ctx, "task").Warn("Task timed out waiting for command to finish", "task", taskURI)
+			result = NewErrorResponse("", NewRedfishError(http.StatusInternalServerError, "InternalError"))
+			break loop
+		}
+	}
+
+	if result.StatusCode == 0 {
+		result.StatusCode = http.StatusOK
+	}
+	// before the task says it is done, so that the client sees all of it
+	done(ctx, result)
+
+	rh.d.tasksMu.Lock()
+	rh.d.taskResults[monitorURI] = &result
+	rh.d.tasksMu.Unlock()
+
+	id := path.Base(taskURI)
+	state, status := "Completed", "OK"
+	messages := []ExtendedInfo{NewExtendedInfo(TaskEventRegistryPrefix+".TaskCompletedOK", id)}
+	if result.StatusCode >= 400 {
+		state, status = "Exception", "Critical"
+		messages = []ExtendedInfo{NewExtendedInfo(TaskEventRegistryPrefix+".TaskAborted", id)}
+		if rerr, ok := result.Results.(*RedfishError); ok {
+			messages = append(messages, rerr.ExtendedInfo...)
+		} else {
+			messages = append(messages, NewExtendedInfo("GeneralError"))
+		}
+	}
+
+	rh.d.CommandHandler.HandleCommand(ctx, &UpdateRedfishResourceProperties{
+		ID: taskID,
+		Properties: map[string]interface{}{
+			"TaskState":       state,
+			"TaskStatus":      status,
+			"EndTime":         time.Now().Format(time.RFC3339),
+			"PercentComplete": 100,
+			"Messages":        messageList(messages),
+		},
+	})
+
+	if rh.d.TaskRetention <= 0 {
+		return
+	}
+	time.Sleep(rh.d.TaskRetention)
+
+	rh.d.CommandHandler.HandleCommand(ctx, &RemoveRedfishResource{ID: monitorID, ResourceURI: monitorURI})
+	rh.d.CommandHandler.HandleCommand(ctx, &RemoveRedfishResource{ID: taskID, ResourceURI: taskURI})
+	rh.d.tasksMu.Lock()
+	delete(rh.d.taskResults, monitorURI)
+	rh.d.tasksMu.Unlock()
+}
+
+// messageList converts messages to plain json types so they can be stored as properties
+func messageList(messages []ExtendedInfo) []interface{} {
+	generic, err := normalizeResults(messages)
+	if err != nil {
+		return []interface{}{}
+	}
+	list, _ := generic.([]interface{})
+	return list
+}
+
+// GETTaskMonitor returns 202 while the task is running, and the response of
+// the command once it is done.
+type GETTaskMonitor struct {
+	ID    eh.UUID `json:"id"`
+	CmdID eh.UUID `json:"cmdid"`
+	d     *DomainObjects
+}
+
+const GETTaskMonitorCommand = eh.CommandType(string(TaskMonitorPlugin) + ":GET")
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&GETTaskMonitor{})
+
+func (c *GETTaskMonitor) AggregateType() eh.AggregateType   { return AggregateType }
+func (c *GETTaskMonitor) AggregateID() eh.UUID              { return c.ID }
+func (c *GETTaskMonitor) CommandType() eh.CommandType       { return GETTaskMonitorCommand }
+func (c *GETTaskMonitor) SetAggID(id eh.UUID)               { c.ID = id }
+func (c *GETTaskMonitor) SetCmdID(id eh.UUID)               { c.CmdID = id }
+func (c *GETTaskMonitor) SetDomainObjects(d *DomainObjects) { c.d = d }
+func (c *GETTaskMonitor) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	var result *HTTPCmdProcessedData
+	if c.d != nil {
+		c.d.tasksMu.RLock()
+		result = c.d.taskResults[a.ResourceURI]
+		c.d.tasksMu.RUnlock()
+	}
+
+	data := HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		StatusCode: http.StatusAccepted,
+		Headers:    map[string]string{"Location": a.ResourceURI},
+	}
+	if result != nil {
+		data = *result
+		data.CommandID = c.CmdID
+	}
+
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
+	return nil
+}
@@ -0,0 +1,304 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+func init() {
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		method := method
+		eh.RegisterCommand(func() eh.Command { return &testSlow{method: method} })
+	}
+}
+
+// testSlow is a command that doesn't finish until the test says so: every
+// percentage sent on the channel for the resource is published as progress,
+// and closing it publishes the response.
+type testSlow struct {
+	ID     eh.UUID `json:"id"`
+	CmdID  eh.UUID `json:"cmdid"`
+	method string
+}
+
+var (
+	testSlowProgress   = map[string]chan int{}
+	testSlowProgressMu sync.Mutex
+)
+
+func (c *testSlow) AggregateType() eh.AggregateType { return AggregateType }
+func (c *testSlow) AggregateID() eh.UUID            { return c.ID }
+func (c *testSlow) CommandType() eh.CommandType     { return eh.CommandType("TestSlow:" + c.method) }
+func (c *testSlow) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *testSlow) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *testSlow) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	testSlowProgressMu.Lock()
+	progress := testSlowProgress[a.ResourceURI]
+	testSlowProgressMu.Unlock()
+	status, _ := a.GetProperty("StatusCode").(int)
+
+	go func() {
+		for p := range progress {
+			testDomain.EventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProgress, HTTPCmdProgressData{CommandID: c.CmdID, PercentComplete: p}, time.Now()))
+		}
+		data := HTTPCmdProcessedData{CommandID: c.CmdID, Results: map[string]interface{}{"Done": true}, StatusCode: status}
+		if status >= 400 {
+			data = NewErrorResponse(c.CmdID, NewRedfishError(status, "InternalError"))
+		}
+		testDomain.EventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
+	}()
+	return nil
+}
+
+// slowProgress returns the channel that drives the slow commands of the
+// resource at uri
+func slowProgress(uri string) chan int {
+	progress := make(chan int)
+	testSlowProgressMu.Lock()
+	testSlowProgress[uri] = progress
+	testSlowProgressMu.Unlock()
+	return progress
+}
+
+// createSlow creates a resource with the slow PATCH, and returns the channel
+// that drives it
+func createSlow(t *testing.T, d *DomainObjects, uri string, status int) chan int {
+	progress := slowProgress(uri)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Plugin: "TestSlow", Properties: map[string]interface{}{"StatusCode": status}})
+	return progress
+}
+
+// taskSettings changes the task settings for a test, and returns the function
+// that puts them back
+func taskSettings(d *DomainObjects, threshold, timeout, retention time.Duration) func() {
+	old := []time.Duration{d.TaskThreshold, d.TaskTimeout, d.TaskRetention}
+	d.TaskThreshold, d.TaskTimeout, d.TaskRetention = threshold, timeout, retention
+	return func() { d.TaskThreshold, d.TaskTimeout, d.TaskRetention = old[0], old[1], old[2] }
+}
+
+func TestTask(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, 20*time.Millisecond, 0, 0)()
+	uri := "/redfish/v1/Slow/Task"
+	progress := createSlow(t, d, uri, http.StatusOK)
+
+	w, body := request(t, d, "PATCH", uri, `{}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("PATCH: status %d\n%s", w.Code, w.Body)
+	}
+	taskURI := w.Header().Get("Location")
+	monitorURI, _ := body["TaskMonitor"].(string)
+	if body["@odata.id"] != taskURI || body["TaskState"] != "Running" || monitorURI == "" {
+		t.Fatalf("task is %v", body)
+	}
+	waitFor(t, "the task to be created", func() bool { return d.HasAggregateID(taskURI) && d.HasAggregateID(monitorURI) })
+
+	// the monitor keeps saying 202 until the command is done
+	if w, _ := request(t, d, "GET", monitorURI, ""); w.Code != http.StatusAccepted || w.Header().Get("Location") != monitorURI {
+		t.Errorf("running monitor: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	progress <- 50
+	waitFor(t, "the task progress", func() bool {
+		_, body := request(t, d, "GET", taskURI, "")
+		return body["PercentComplete"] == 50.0
+	})
+
+	close(progress)
+	waitFor(t, "the task to complete", func() bool {
+		_, body := request(t, d, "GET", taskURI, "")
+		return body["TaskState"] == "Completed"
+	})
+	_, body = request(t, d, "GET", taskURI, "")
+	if body["TaskStatus"] != "OK" || body["PercentComplete"] != 100.0 || body["EndTime"] == nil {
+		t.Errorf("completed task is %v", body)
+	}
+
+	w, body = request(t, d, "GET", monitorURI, "")
+	if w.Code != http.StatusOK || body["Done"] != true {
+		t.Errorf("finished monitor: status %d, body %v", w.Code, body)
+	}
+}
+
+func TestTaskFailed(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, 20*time.Millisecond, 0, 0)()
+	uri := "/redfish/v1/Slow/Failed"
+	progress := createSlow(t, d, uri, http.StatusConflict)
+
+	w, body := request(t, d, "PATCH", uri, `{}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("PATCH: status %d", w.Code)
+	}
+	taskURI := w.Header().Get("Location")
+	monitorURI, _ := body["TaskMonitor"].(string)
+	waitFor(t, "the task to be created", func() bool { return d.HasAggregateID(monitorURI) })
+	close(progress)
+
+	waitFor(t, "the task to fail", func() bool {
+		_, body := request(t, d, "GET", taskURI, "")
+		return body["TaskState"] == "Exception"
+	})
+	w, body = request(t, d, "GET", monitorURI, "")
+	if ids := messageIDs(body); w.Code != http.StatusConflict || len(ids) != 1 || ids[0] != "InternalError" {
+		t.Errorf("failed monitor: status %d, messages %v", w.Code, ids)
+	}
+}
+
+func TestTaskTimeoutAndRetention(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, 20*time.Millisecond, 50*time.Millisecond, 100*time.Millisecond)()
+	uri := "/redfish/v1/Slow/Timeout"
+	progress := createSlow(t, d, uri, http.StatusOK)
+	// let the command finish after the test, nobody is listening any more
+	defer close(progress)
+
+	w, body := request(t, d, "PATCH", uri, `{}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("PATCH: status %d", w.Code)
+	}
+	taskURI := w.Header().Get("Location")
+	monitorURI, _ := body["TaskMonitor"].(string)
+
+	waitFor(t, "the task to time out", func() bool {
+		_, body := request(t, d, "GET", taskURI, "")
+		return body["TaskState"] == "Exception"
+	})
+	if w, _ := request(t, d, "GET", monitorURI, ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("timed out monitor: status %d", w.Code)
+	}
+
+	// and then it goes away
+	waitFor(t, "the task to be removed", func() bool { return !d.HasAggregateID(taskURI) && !d.HasAggregateID(monitorURI) })
+}
+
+func TestNoTask(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, time.Second, 0, 0)()
+	uri := "/redfish/v1/Slow/Quick"
+	progress := createSlow(t, d, uri, http.StatusOK)
+	close(progress)
+
+	// commands that finish before the threshold answer directly
+	if w, body := request(t, d, "PATCH", uri, `{}`); w.Code != http.StatusOK || body["Done"] != true {
+		t.Errorf("PATCH: status %d, body %v", w.Code, body)
+	}
+}
+
+// requestAs makes a request as a user other than the tester
+func requestAs(d *DomainObjects, user string, privileges []string, method, uri, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	NewRedfishHandler(d, testLogger{}, user, privileges).ServeHTTP(w, r)
+	return w
+}
+
+func TestTaskPrivileges(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, 20*time.Millisecond, 0, 0)()
+	uri := "/redfish/v1/Slow/Privileges"
+	progress := slowProgress(uri)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Plugin: "TestSlow", Properties: map[string]interface{}{"StatusCode": http.StatusOK},
+		Privileges: map[string]interface{}{"GET": []string{"Login"}, "PATCH": []string{"Login"}}})
+
+	alice := []string{"Unauthenticated", "Login", "ConfigureSelf_alice"}
+	w := requestAs(d, "alice", alice, "PATCH", uri, `{}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("PATCH: status %d", w.Code)
+	}
+	taskURI := w.Header().Get("Location")
+	waitFor(t, "the task to be created", func() bool { return d.HasAggregateID(taskURI) })
+	close(progress)
+	waitFor(t, "the task to complete", func() bool {
+		_, body := request(t, d, "GET", taskURI, "")
+		return body["TaskState"] == "Completed"
+	})
+	monitorURI := taskURI + "/Monitor"
+
+	tests := []struct {
+		name       string
+		user       string
+		privileges []string
+		status     int
+	}{
+		{"requester", "alice", alice, http.StatusOK},
+		{"manager", "root", []string{"Unauthenticated", "Login", "ConfigureSelf_root", "ConfigureManager"}, http.StatusOK},
+		{"another user", "bob", []string{"Unauthenticated", "Login", "ConfigureSelf_bob"}, http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		for _, u := range []string{taskURI, monitorURI} {
+			if w := requestAs(d, tc.user, tc.privileges, "GET", u, ""); w.Code != tc.status {
+				t.Errorf("%s: GET %s status %d, expected %d", tc.name, u, w.Code, tc.status)
+			}
+		}
+	}
+}
+
+func TestTaskDeleteCascade(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, 20*time.Millisecond, 0, 0)()
+	defer func(p ChildDeletePolicy) { d.ChildDeletePolicy = p }(d.ChildDeletePolicy)
+	d.ChildDeletePolicy = CascadeDelete
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		state  string
+	}{
+		{"Deleted", http.StatusOK, "Completed"},
+		{"Failed", http.StatusConflict, "Exception"},
+	} {
+		uri := "/redfish/v1/Slow/Cascade" + tc.name
+		child := uri + "/Child"
+		progress := slowProgress(uri)
+		createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Plugin: "TestSlow", Properties: map[string]interface{}{"StatusCode": tc.status},
+			Deletable: true, Privileges: deletePrivileges})
+		createResource(t, d, &CreateRedfishResource{ResourceURI: child, Properties: map[string]interface{}{"Name": "child"}})
+
+		w, _ := request(t, d, "DELETE", uri, "")
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("%s: DELETE status %d", tc.name, w.Code)
+		}
+		// nothing is removed while the DELETE is still running
+		taskURI := w.Header().Get("Location")
+		waitFor(t, "the task to be created", func() bool { return d.HasAggregateID(taskURI) })
+		if !d.HasAggregateID(child) {
+			t.Errorf("%s: the child was removed before the DELETE finished", tc.name)
+		}
+
+		close(progress)
+		waitFor(t, "the task to finish", func() bool {
+			_, body := request(t, d, "GET", taskURI, "")
+			return body["TaskState"] == tc.state
+		})
+		// the children are gone by the time the task says it is done
+		if removed := !d.HasAggregateID(child); removed != (tc.status < 300) {
+			t.Errorf("%s: child removed %v", tc.name, removed)
+		}
+	}
+}
+
+func TestTaskGET(t *testing.T) {
+	d := newTestDomain(t)
+	defer taskSettings(d, 20*time.Millisecond, 0, 0)()
+	uri := "/redfish/v1/Slow/GET"
+	progress := slowProgress(uri)
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Plugin: "TestSlow", Properties: map[string]interface{}{"StatusCode": http.StatusOK}})
+	defer close(progress)
+
+	// the 202 is about the task, not the resource: no 304 and no query options
+	w, body := request(t, d, "GET", uri+"?$select=Name", "", "If-None-Match", "*")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("GET: status %d", w.Code)
+	}
+	if body["TaskMonitor"] == nil || body["TaskState"] != "Running" {
+		t.Errorf("task is %v", body)
+	}
+}
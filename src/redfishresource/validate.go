@@ -0,0 +1,259 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const allowableValuesSuffix = "@Redfish.AllowableValues"
+
+// MaxRequestBodySize is the largest request body that DecodeJSONBody will accept
+var MaxRequestBodySize int64 = 1 << 20
+
+// DecodeJSONBody is for commands that parse a json request body. An empty
+// body leaves v alone, it's up to the caller to decide if that's ok. The
+// errors returned are RedfishErrors.
+func DecodeJSONBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxRequestBodySize+1))
+	if err != nil {
+		return NewRedfishError(http.StatusBadRequest, "UnrecognizedRequestBody")
+	}
+	if int64(len(body)) > MaxRequestBodySize {
+		return NewRedfishError(http.StatusRequestEntityTooLarge, "GeneralError")
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(body, v)
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		// valid json, just not what we were expecting (ie. an array instead of an object)
+		return NewRedfishError(http.StatusBadRequest, "UnrecognizedRequestBody")
+	}
+	if err != nil {
+		return NewRedfishError(http.StatusBadRequest, "MalformedJSON")
+	}
+	return nil
+}
+
+// jsonKind returns the json type of a value decoded by encoding/json
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "unknown"
+}
+
+// valueTypeMismatch checks a value from a request body against the current
+// value of the property. Properties that are currently null, and requests
+// to set null, can't be checked.
+func valueTypeMismatch(current, body interface{}) bool {
+	generic, err := normalizeResults(current)
+	if err != nil {
+		return false
+	}
+	if generic == nil || body == nil {
+		return false
+	}
+	return jsonKind(generic) != jsonKind(body)
+}
+
+// validatePatch splits a PATCH body into the request that can be applied and
+// messages for the properties that can't.
+func (a *RedfishResourceAggregate) validatePatch(body map[string]interface{}) (map[string]interface{}, []ExtendedInfo) {
+	a.propertiesMu.RLock()
+	defer a.propertiesMu.RUnlock()
+
+	props, _ := a.properties.Value.(map[string]interface{})
+	messages := []ExtendedInfo{}
+	request := patchRequest(props, body, "", &messages)
+	return request, messages
+}
+
+func patchRequest(props, body map[string]interface{}, path string, messages *[]ExtendedInfo) map[string]interface{} {
+	request := map[string]interface{}{}
+
+	for k, v := range body {
+		related := "#" + path + "/" + k
+		add := func(id string, args ...interface{}) {
+			*messages = append(*messages, NewExtendedInfo(id, args...).WithRelatedProperties(related))
+		}
+
+		// annotations (other than the immutable ones) are informational
+		if strings.Contains(k, "@") && !isImmutable(k) {
+			continue
+		}
+
+		rrp, ok := props[k].(RedfishResourceProperty)
+		if !ok {
+			add("PropertyUnknown", k)
+			continue
+		}
+		if isImmutable(k) {
+			add("PropertyNotWritable", k)
+			continue
+		}
+
+		if meta, ok := rrp.Meta["PATCH"].(map[string]interface{}); ok {
+			if _, ok := meta["plugin"]; ok {
+				if valueTypeMismatch(rrp.Value, v) {
+					add("PropertyValueTypeError", jsonString(v), k)
+					continue
+				}
+				if !isAllowableValue(props[k+allowableValuesSuffix], v) {
+					add("PropertyValueNotInList", jsonString(v), k)
+					continue
+				}
+				request[k] = v
+				continue
+			}
+		}
+
+		// objects may have writable properties inside
+		if sub, ok := rrp.Value.(map[string]interface{}); ok {
+			subBody, ok := v.(map[string]interface{})
+			if !ok {
+				add("PropertyValueTypeError", jsonString(v), k)
+				continue
+			}
+			if subRequest := patchRequest(sub, subBody, path+"/"+k, messages); len(subRequest) > 0 {
+				request[k] = subRequest
+			}
+			continue
+		}
+
+		add("PropertyNotWritable", k)
+	}
+
+	return request
+}
+
+// isAllowableValue checks a value from a request body against the
+// @Redfish.AllowableValues annotation for the property. Everything is allowed
+// when there's no annotation.
+func isAllowableValue(allowable interface{}, v interface{}) bool {
+	if allowable == nil {
+		return true
+	}
+	generic, err := normalizeResults(allowable)
+	if err != nil {
+		return true
+	}
+	list, ok := generic.([]interface{})
+	if !ok {
+		return true
+	}
+	for _, a := range list {
+		if reflect.DeepEqual(a, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkActionParameters validates the parameters for the action at uri. The
+// action is found in the Actions property of the resource that the action
+// uri is under, by its "target".
+func (rh *RedfishHandler) checkActionParameters(ctx context.Context, uri string, params map[string]interface{}) *RedfishError {
+	i := strings.LastIndex(uri, "/Actions/")
+	if i < 0 {
+		return nil
+	}
+	aggID, ok := rh.d.GetAggregateIDOK(uri[:i])
+	if !ok {
+		return nil
+	}
+	agg, _ := rh.d.AggregateStore.Load(ctx, AggregateType, aggID)
+	redfishResource, ok := agg.(*RedfishResourceAggregate)
+	if !ok {
+		return nil
+	}
+
+	name, action := redfishResource.findAction(uri)
+	if action == nil {
+		return nil
+	}
+
+	rerr := &RedfishError{StatusCode: http.StatusBadRequest}
+	for k, allowable := range action {
+		if !strings.HasSuffix(k, allowableValuesSuffix) {
+			continue
+		}
+		param := strings.TrimSuffix(k, allowableValuesSuffix)
+		v, ok := params[param]
+		if ok && !isAllowableValue(allowable, v) {
+			rerr.AddExtendedInfo(NewExtendedInfo("ActionParameterValueNotInList", jsonString(v), param, name).WithRelatedProperties("#/" + param))
+		}
+	}
+	if len(rerr.ExtendedInfo) > 0 {
+		return rerr
+	}
+	return nil
+}
+
+// findAction returns the name (without the leading #) and the entry in the
+// Actions property for the action with the given target.
+func (a *RedfishResourceAggregate) findAction(target string) (string, map[string]interface{}) {
+	a.propertiesMu.RLock()
+	defer a.propertiesMu.RUnlock()
+
+	props, _ := a.properties.Value.(map[string]interface{})
+	actions, ok := props["Actions"].(RedfishResourceProperty)
+	if !ok {
+		return "", nil
+	}
+	actionMap, _ := actions.Value.(map[string]interface{})
+	for name, v := range actionMap {
+		rrp, ok := v.(RedfishResourceProperty)
+		if !ok {
+			continue
+		}
+		action, ok := rrp.Value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if t, ok := action["target"].(RedfishResourceProperty); ok && t.Value == target {
+			return strings.TrimPrefix(name, "#"), action
+		}
+	}
+	return "", nil
+}
+
+// withExtendedInfo adds messages to the top level of results without
+// touching the properties stored in the aggregate.
+func withExtendedInfo(results interface{}, messages []ExtendedInfo) interface{} {
+	rrp, ok := results.(RedfishResourceProperty)
+	if !ok {
+		return results
+	}
+	m, ok := rrp.Value.(map[string]interface{})
+	if !ok {
+		return results
+	}
+	out := map[string]interface{}{}
+	for k, v := range m {
+		out[k] = v
+	}
+	out["@Message.ExtendedInfo"] = messages
+	return RedfishResourceProperty{Value: out, Meta: rrp.Meta}
+}
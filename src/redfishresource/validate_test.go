@@ -0,0 +1,230 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestDecodeJSONBody(t *testing.T) {
+	defer func(size int64) { MaxRequestBodySize = size }(MaxRequestBodySize)
+	MaxRequestBodySize = 32
+
+	tests := []struct {
+		name   string
+		body   io.Reader
+		status int
+		id     string
+		value  map[string]interface{}
+	}{
+		{"no body", nil, 0, "", nil},
+		{"empty", strings.NewReader(""), 0, "", nil},
+		{"whitespace", strings.NewReader(" \r\n\t"), 0, "", nil},
+		{"object", strings.NewReader(`{"Name": "x"}`), 0, "", map[string]interface{}{"Name": "x"}},
+		{"at the limit", strings.NewReader(`{"Name": "` + strings.Repeat("x", 20) + `"}`), 0, "",
+			map[string]interface{}{"Name": strings.Repeat("x", 20)}},
+		{"over the limit", strings.NewReader(`{"Name": "` + strings.Repeat("x", 21) + `"}`),
+			http.StatusRequestEntityTooLarge, "GeneralError", nil},
+		{"way over the limit", strings.NewReader(strings.Repeat(" ", 1<<20)),
+			http.StatusRequestEntityTooLarge, "GeneralError", nil},
+		{"array", strings.NewReader(`[{"Name": "x"}]`), http.StatusBadRequest, "UnrecognizedRequestBody", nil},
+		{"string", strings.NewReader(`"x"`), http.StatusBadRequest, "UnrecognizedRequestBody", nil},
+		{"truncated", strings.NewReader(`{"Name": "x"`), http.StatusBadRequest, "MalformedJSON", nil},
+		{"not json", strings.NewReader(`Name=x`), http.StatusBadRequest, "MalformedJSON", nil},
+		{"trailing garbage", strings.NewReader(`{"Name": "x"} x`), http.StatusBadRequest, "MalformedJSON", nil},
+		{"read error", errReader{}, http.StatusBadRequest, "UnrecognizedRequestBody", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/redfish/v1", nil)
+			r.Body = nil
+			if tc.body != nil {
+				r.Body = ioutil.NopCloser(tc.body)
+			}
+			var v map[string]interface{}
+			err := DecodeJSONBody(r, &v)
+			if tc.status == 0 {
+				if err != nil {
+					t.Fatalf("error %s", err)
+				}
+				if !reflect.DeepEqual(v, tc.value) {
+					t.Errorf("decoded %v, expected %v", v, tc.value)
+				}
+				return
+			}
+			rerr, ok := err.(*RedfishError)
+			if !ok {
+				t.Fatalf("error %#v isn't a RedfishError", err)
+			}
+			if rerr.StatusCode != tc.status || len(rerr.ExtendedInfo) != 1 || rerr.ExtendedInfo[0].MessageID != tc.id {
+				t.Errorf("error %d %v, expected %d %s", rerr.StatusCode, rerr.ExtendedInfo, tc.status, tc.id)
+			}
+		})
+	}
+}
+
+func TestPatchValidation(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/Patch"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{
+		"Id":            "Patch",
+		"AssetTag":      "tag",
+		"AssetTag@meta": testPatchMeta,
+		"Boot": map[string]interface{}{
+			"Target":                         "None",
+			"Target@meta":                    testPatchMeta,
+			"Target@Redfish.AllowableValues": []interface{}{"None", "Pxe"},
+			"Mode":                           "UEFI",
+		},
+	}})
+
+	// each step runs after the ones before it
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		messages []string
+		assetTag string
+		target   string
+	}{
+		{"writable", `{"AssetTag": "one", "Boot": {"Target": "Pxe"}}`,
+			http.StatusOK, []string{"ResourceChanged"}, "one", "Pxe"},
+		{"annotations are ignored", `{"AssetTag": "two", "AssetTag@odata.type": "x"}`,
+			http.StatusOK, []string{"ResourceChanged"}, "two", "Pxe"},
+		{"partly", `{"AssetTag": "three", "Id": "Other", "Nope": 1}`,
+			http.StatusOK, []string{"PropertyNotWritable", "PropertyUnknown", "ResourceChanged"}, "three", "Pxe"},
+		{"read-only", `{"Id": "Other"}`,
+			http.StatusBadRequest, []string{"PropertyNotWritable"}, "three", "Pxe"},
+		{"read-only inside", `{"Boot": {"Mode": "Legacy"}}`,
+			http.StatusBadRequest, []string{"PropertyNotWritable"}, "three", "Pxe"},
+		{"immutable", `{"@odata.id": "/redfish/v1/Other"}`,
+			http.StatusBadRequest, []string{"PropertyNotWritable"}, "three", "Pxe"},
+		{"unknown", `{"Nope": 1}`,
+			http.StatusBadRequest, []string{"PropertyUnknown"}, "three", "Pxe"},
+		{"wrong type", `{"AssetTag": 1, "Boot": "Pxe"}`,
+			http.StatusBadRequest, []string{"PropertyValueTypeError", "PropertyValueTypeError"}, "three", "Pxe"},
+		{"not allowed", `{"Boot": {"Target": "Usb"}}`,
+			http.StatusBadRequest, []string{"PropertyValueNotInList"}, "three", "Pxe"},
+		{"malformed", `{"AssetTag": "four"`,
+			http.StatusBadRequest, []string{"MalformedJSON"}, "three", "Pxe"},
+		{"no body", ``,
+			http.StatusBadRequest, []string{"MalformedJSON"}, "three", "Pxe"},
+	}
+	for _, tc := range tests {
+		w, body := request(t, d, "PATCH", uri, tc.body)
+		if w.Code != tc.status {
+			t.Fatalf("%s: status %d, expected %d\n%s", tc.name, w.Code, tc.status, w.Body)
+		}
+		ids := messageIDs(body)
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, tc.messages) {
+			t.Errorf("%s: messages %v, expected %v", tc.name, ids, tc.messages)
+		}
+		_, body = request(t, d, "GET", uri, "")
+		if body["AssetTag"] != tc.assetTag || lookup(body, "Boot/Target") != tc.target {
+			t.Errorf("%s: AssetTag %v, Boot/Target %v", tc.name, body["AssetTag"], lookup(body, "Boot/Target"))
+		}
+	}
+}
+
+func TestIsAllowableValue(t *testing.T) {
+	tests := []struct {
+		allowable interface{}
+		v         interface{}
+		ok        bool
+	}{
+		{nil, "anything", true},
+		{"not a list", "anything", true},
+		{[]interface{}{"On", "Off"}, "On", true},
+		{[]string{"On", "Off"}, "Off", true},
+		{[]string{"On", "Off"}, "Blinking", false},
+		{[]string{"On", "Off"}, 1.0, false},
+		{[]interface{}{1, 2}, 2.0, true},
+		{[]interface{}{}, "On", false},
+	}
+	for _, tc := range tests {
+		if ok := isAllowableValue(tc.allowable, tc.v); ok != tc.ok {
+			t.Errorf("%v in %v: %t", tc.v, tc.allowable, ok)
+		}
+	}
+}
+
+func init() {
+	eh.RegisterCommand(func() eh.Command { return &testAction{} })
+}
+
+const testActionCommand = eh.CommandType("TestAction:POST")
+
+// testAction is an action that answers with its parameters
+type testAction struct {
+	ID     eh.UUID `json:"id"`
+	CmdID  eh.UUID `json:"cmdid"`
+	Params map[string]interface{}
+}
+
+func (c *testAction) AggregateType() eh.AggregateType { return AggregateType }
+func (c *testAction) AggregateID() eh.UUID            { return c.ID }
+func (c *testAction) CommandType() eh.CommandType     { return testActionCommand }
+func (c *testAction) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *testAction) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *testAction) ParseHTTPRequest(r *http.Request) error {
+	return DecodeJSONBody(r, &c.Params)
+}
+func (c *testAction) ActionParameters() map[string]interface{} { return c.Params }
+func (c *testAction) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, HTTPCmdProcessedData{CommandID: c.CmdID, Results: c.Params}, time.Now()))
+	return nil
+}
+
+func TestActionParameters(t *testing.T) {
+	d := newTestDomain(t)
+	uri := "/redfish/v1/Act"
+	target := uri + "/Actions/Test.Reset"
+	createResource(t, d, &CreateRedfishResource{ResourceURI: uri, Properties: map[string]interface{}{
+		"Actions": map[string]interface{}{
+			"#Test.Reset": map[string]interface{}{
+				"target":                            target,
+				"ResetType@Redfish.AllowableValues": []interface{}{"On", "ForceOff"},
+			},
+		},
+	}})
+	createResource(t, d, &CreateRedfishResource{ResourceURI: target, Plugin: "TestAction",
+		Privileges: map[string]interface{}{"POST": []string{"ConfigureManager"}}})
+
+	tests := []struct {
+		body     string
+		status   int
+		messages []string
+	}{
+		{`{"ResetType": "On"}`, http.StatusOK, []string{}},
+		{`{"Other": "x"}`, http.StatusOK, []string{}},
+		{`{"ResetType": "GracefulRestart"}`, http.StatusBadRequest, []string{"ActionParameterValueNotInList"}},
+		{`{"ResetType": 1}`, http.StatusBadRequest, []string{"ActionParameterValueNotInList"}},
+	}
+	for _, tc := range tests {
+		w, body := request(t, d, "POST", target, tc.body)
+		if ids := messageIDs(body); w.Code != tc.status || !reflect.DeepEqual(ids, tc.messages) {
+			t.Errorf("%s: status %d, messages %v", tc.body, w.Code, ids)
+		}
+	}
+
+	_, body := request(t, d, "POST", target, `{"ResetType": "Off"}`)
+	if related := lookup(body, "error/@Message.ExtendedInfo/0/RelatedProperties/0"); related != "#/ResetType" {
+		t.Errorf("related property %v", related)
+	}
+}
@@ -0,0 +1,100 @@
+package registries
+
+// Base is the subset of the DMTF Base message registry that we emit.
+var Base = &Registry{
+	ID:              "Base.1.0.0",
+	Name:            "Base Message Registry",
+	Description:     "This registry defines the base messages for Redfish",
+	Language:        "en",
+	RegistryPrefix:  "Base",
+	RegistryVersion: "1.0.0",
+	OwningEntity:    "DMTF",
+	Messages: messages(map[string]msg{
+		"Success": {
+			"Successfully Completed Request", "OK", "None"},
+		"GeneralError": {
+			"A general error has occurred. See ExtendedInfo for more information.", "Critical", "See ExtendedInfo for more information."},
+		"Created": {
+			"The resource has been created successfully", "OK", "None"},
+		"PropertyUnknown": {
+			"The property %1 is not in the list of valid properties for the resource.", "Warning",
+			"Remove the unknown property from the request body and resubmit the request if the operation failed."},
+		"PropertyValueTypeError": {
+			"The value %1 for the property %2 is of a different type than the property can accept.", "Warning",
+			"Correct the value for the property in the request body and resubmit the request if the operation failed."},
+		"PropertyValueNotInList": {
+			"The value %1 for the property %2 is not in the list of acceptable values.", "Warning",
+			"Choose a value from the enumeration list that the implementation can support and resubmit the request if the operation failed."},
+		"PropertyValueFormatError": {
+			"The value %1 for the property %2 is of a different format than the property can accept.", "Warning",
+			"Correct the value for the property in the request body and resubmit the request if the operation failed."},
+		"PropertyNotWritable": {
+			"The property %1 is a read only property and cannot be assigned a value.", "Warning",
+			"Remove the property from the request body and resubmit the request if the operation failed."},
+		"PropertyMissing": {
+			"The property %1 is a required property and must be included in the request.", "Warning",
+			"Ensure that the property is in the request body and has a valid value and resubmit the request if the operation failed."},
+		"MalformedJSON": {
+			"The request body submitted was malformed JSON and could not be parsed by the receiving service.", "Critical",
+			"Ensure that the request body is valid JSON and resubmit the request."},
+		"ActionNotSupported": {
+			"The action %1 is not supported by the resource.", "Critical",
+			"The action supplied cannot be resubmitted to the implementation.  Perhaps the action was invalid, the wrong resource was the target or the implementation documentation may be of assistance."},
+		"ActionParameterMissing": {
+			"The action %1 requires the parameter %2 to be present in the request body.", "Critical",
+			"Supply the action with the required parameter in the request body when the request is resubmitted."},
+		"ActionParameterValueNotInList": {
+			"The value %1 for the parameter %2 in the action %3 is not in the list of acceptable values.", "Warning",
+			"Choose a value from the enumeration list that the implementation can support and resubmit the request if the operation failed."},
+		"PreconditionFailed": {
+			"The ETag supplied did not match the ETag required to change this resource.", "Critical",
+			"Try the operation again using the appropriate ETag."},
+		"QueryParameterValueTypeError": {
+			"The value %1 for the query parameter %2 is of a different type than the parameter can accept.", "Warning",
+			"Correct the value for the query parameter in the request and resubmit the request if the operation failed."},
+		"QueryParameterValueFormatError": {
+			"The value %1 for the parameter %2 is of a different format than the parameter can accept.", "Warning",
+			"Correct the value for the query parameter in the request and resubmit the request if the operation failed."},
+		"QueryParameterOutOfRange": {
+			"The value %1 for the query parameter %2 is out of range %3.", "Warning",
+			"Reduce the value for the query parameter to a value that is within range, such as a start or count value that is within bounds of the number of resources in a collection or a page that is within the range of valid pages."},
+		"QueryCombinationInvalid": {
+			"Two or more query parameters in the request cannot be used together.", "Warning",
+			"Remove one or more of the query parameters and resubmit the request if the operation failed."},
+		"ResourceAlreadyExists": {
+			"The requested resource of type %1 with the property %2 with the value %3 already exists.", "Critical",
+			"Do not repeat the create operation as the resource has already been created."},
+		"ResourceCannotBeDeleted": {
+			"The delete request failed because the resource requested cannot be deleted.", "Critical",
+			"Do not attempt to delete a non-deletable resource."},
+		"ResourceInUse": {
+			"The change to the requested resource failed because the resource is in use or in transition.", "Warning",
+			"Remove the condition and resubmit the request if the operation failed."},
+		"ResourceMissingAtURI": {
+			"The resource at the URI %1 was not found.", "Critical",
+			"Place a valid resource at the URI or correct the URI and resubmit the request."},
+		"ResourceAtUriUnauthorized": {
+			"While accessing the resource at %1, the service received an authorization error %2.", "Critical",
+			"Ensure that the appropriate access is provided for the service in order for it to access the URI."},
+		"NoValidSession": {
+			"There is no valid session established with the implementation.", "Critical",
+			"Establish as session before attempting any operations."},
+		"SessionLimitExceeded": {
+			"The session establishment failed due to the number of simultaneous sessions exceeding the limit of the implementation.", "Critical",
+			"Reduce the number of other sessions before trying to establish the session or increase the limit of simultaneous sessions (if supported)."},
+		"InsufficientPrivilege": {
+			"There are insufficient privileges for the account or credentials associated with the current session to perform the requested operation.", "Critical",
+			"Either abandon the operation or change the associated access rights and resubmit the request if the operation failed."},
+		"UnrecognizedRequestBody": {
+			"The service detected a malformed request body that it was unable to interpret.", "Warning",
+			"Correct the request body and resubmit the request if it failed."},
+		"InternalError": {
+			"The request failed due to an internal service error.  The service is still operational.", "Critical",
+			"Resubmit the request.  If the problem persists, consider resetting the service."},
+	}),
+}
+
+func init() {
+	Register(Base)
+	Register(BaseDE)
+}
@@ -0,0 +1,168 @@
+package registries
+
+// German translations of the DMTF registries. Only the text is translated,
+// the message IDs, arguments and severities are the same as in English. They
+// are registered right after the English ones, which stay the default.
+
+// BaseDE is the German translation of Base
+var BaseDE = &Registry{
+	ID:              "Base.1.0.0",
+	Name:            "Basis-Nachrichtenregistrierung",
+	Description:     "Diese Registrierung definiert die Basisnachrichten für Redfish",
+	Language:        "de",
+	RegistryPrefix:  "Base",
+	RegistryVersion: "1.0.0",
+	OwningEntity:    "DMTF",
+	Messages: messages(map[string]msg{
+		"Success": {
+			"Anforderung erfolgreich abgeschlossen", "OK", "Keine"},
+		"GeneralError": {
+			"Ein allgemeiner Fehler ist aufgetreten. Weitere Informationen finden Sie in ExtendedInfo.", "Critical", "Weitere Informationen finden Sie in ExtendedInfo."},
+		"Created": {
+			"Die Ressource wurde erfolgreich erstellt", "OK", "Keine"},
+		"PropertyUnknown": {
+			"Die Eigenschaft %1 ist keine gültige Eigenschaft der Ressource.", "Warning",
+			"Entfernen Sie die unbekannte Eigenschaft aus dem Anforderungstext und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"PropertyValueTypeError": {
+			"Der Wert %1 für die Eigenschaft %2 hat einen anderen Typ, als die Eigenschaft annehmen kann.", "Warning",
+			"Korrigieren Sie den Wert der Eigenschaft im Anforderungstext und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"PropertyValueNotInList": {
+			"Der Wert %1 für die Eigenschaft %2 ist nicht in der Liste der zulässigen Werte.", "Warning",
+			"Wählen Sie einen Wert aus der Liste, den die Implementierung unterstützt, und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"PropertyValueFormatError": {
+			"Der Wert %1 für die Eigenschaft %2 hat ein anderes Format, als die Eigenschaft annehmen kann.", "Warning",
+			"Korrigieren Sie den Wert der Eigenschaft im Anforderungstext und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"PropertyNotWritable": {
+			"Die Eigenschaft %1 ist schreibgeschützt und kann keinen Wert zugewiesen bekommen.", "Warning",
+			"Entfernen Sie die Eigenschaft aus dem Anforderungstext und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"PropertyMissing": {
+			"Die Eigenschaft %1 ist erforderlich und muss in der Anforderung enthalten sein.", "Warning",
+			"Stellen Sie sicher, dass die Eigenschaft mit einem gültigen Wert im Anforderungstext enthalten ist, und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"MalformedJSON": {
+			"Der gesendete Anforderungstext war fehlerhaftes JSON und konnte vom Dienst nicht verarbeitet werden.", "Critical",
+			"Stellen Sie sicher, dass der Anforderungstext gültiges JSON ist, und senden Sie die Anforderung erneut."},
+		"ActionNotSupported": {
+			"Die Aktion %1 wird von der Ressource nicht unterstützt.", "Critical",
+			"Die Aktion kann nicht erneut an die Implementierung gesendet werden. Möglicherweise war die Aktion ungültig, das Ziel die falsche Ressource, oder die Dokumentation der Implementierung hilft weiter."},
+		"ActionParameterMissing": {
+			"Die Aktion %1 erfordert den Parameter %2 im Anforderungstext.", "Critical",
+			"Geben Sie den erforderlichen Parameter im Anforderungstext an, wenn die Anforderung erneut gesendet wird."},
+		"ActionParameterValueNotInList": {
+			"Der Wert %1 für den Parameter %2 der Aktion %3 ist nicht in der Liste der zulässigen Werte.", "Warning",
+			"Wählen Sie einen Wert aus der Liste, den die Implementierung unterstützt, und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"PreconditionFailed": {
+			"Das angegebene ETag stimmt nicht mit dem ETag überein, das zum Ändern dieser Ressource erforderlich ist.", "Critical",
+			"Wiederholen Sie den Vorgang mit dem passenden ETag."},
+		"QueryParameterValueTypeError": {
+			"Der Wert %1 für den Abfrageparameter %2 hat einen anderen Typ, als der Parameter annehmen kann.", "Warning",
+			"Korrigieren Sie den Wert des Abfrageparameters und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"QueryParameterValueFormatError": {
+			"Der Wert %1 für den Parameter %2 hat ein anderes Format, als der Parameter annehmen kann.", "Warning",
+			"Korrigieren Sie den Wert des Abfrageparameters und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"QueryParameterOutOfRange": {
+			"Der Wert %1 für den Abfrageparameter %2 liegt außerhalb des Bereichs %3.", "Warning",
+			"Verringern Sie den Wert des Abfrageparameters auf einen Wert innerhalb des Bereichs, etwa einen Start- oder Zählwert innerhalb der Anzahl der Ressourcen einer Sammlung oder eine gültige Seite."},
+		"QueryCombinationInvalid": {
+			"Zwei oder mehr Abfrageparameter der Anforderung können nicht zusammen verwendet werden.", "Warning",
+			"Entfernen Sie einen oder mehrere der Abfrageparameter und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"ResourceAlreadyExists": {
+			"Die angeforderte Ressource vom Typ %1 mit der Eigenschaft %2 mit dem Wert %3 existiert bereits.", "Critical",
+			"Wiederholen Sie den Erstellvorgang nicht, da die Ressource bereits erstellt wurde."},
+		"ResourceCannotBeDeleted": {
+			"Die Löschanforderung ist fehlgeschlagen, da die angeforderte Ressource nicht gelöscht werden kann.", "Critical",
+			"Versuchen Sie nicht, eine nicht löschbare Ressource zu löschen."},
+		"ResourceInUse": {
+			"Die Änderung der angeforderten Ressource ist fehlgeschlagen, da die Ressource verwendet wird oder sich im Übergang befindet.", "Warning",
+			"Beheben Sie den Zustand und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"ResourceMissingAtURI": {
+			"Die Ressource unter dem URI %1 wurde nicht gefunden.", "Critical",
+			"Legen Sie eine gültige Ressource unter dem URI an oder korrigieren Sie den URI und senden Sie die Anforderung erneut."},
+		"ResourceAtUriUnauthorized": {
+			"Beim Zugriff auf die Ressource unter %1 hat der Dienst einen Autorisierungsfehler %2 erhalten.", "Critical",
+			"Stellen Sie sicher, dass der Dienst den nötigen Zugriff auf den URI hat."},
+		"NoValidSession": {
+			"Es besteht keine gültige Sitzung mit der Implementierung.", "Critical",
+			"Bauen Sie eine Sitzung auf, bevor Sie Vorgänge ausführen."},
+		"SessionLimitExceeded": {
+			"Die Sitzung konnte nicht aufgebaut werden, da die Anzahl gleichzeitiger Sitzungen das Limit der Implementierung überschreitet.", "Critical",
+			"Verringern Sie die Anzahl anderer Sitzungen, bevor Sie die Sitzung aufbauen, oder erhöhen Sie das Limit gleichzeitiger Sitzungen (falls unterstützt)."},
+		"InsufficientPrivilege": {
+			"Das Konto oder die Anmeldedaten der aktuellen Sitzung haben nicht die nötigen Berechtigungen für den angeforderten Vorgang.", "Critical",
+			"Brechen Sie den Vorgang ab oder ändern Sie die Zugriffsrechte und senden Sie die Anforderung erneut, falls der Vorgang fehlgeschlagen ist."},
+		"UnrecognizedRequestBody": {
+			"Der Dienst hat einen fehlerhaften Anforderungstext erkannt, den er nicht interpretieren konnte.", "Warning",
+			"Korrigieren Sie den Anforderungstext und senden Sie die Anforderung erneut, falls sie fehlgeschlagen ist."},
+		"InternalError": {
+			"Die Anforderung ist wegen eines internen Fehlers des Dienstes fehlgeschlagen. Der Dienst ist weiterhin betriebsbereit.", "Critical",
+			"Senden Sie die Anforderung erneut. Wenn das Problem weiter besteht, setzen Sie den Dienst zurück."},
+	}),
+}
+
+// ResourceEventDE is the German translation of ResourceEvent
+var ResourceEventDE = &Registry{
+	ID:              "ResourceEvent.1.0.0",
+	Name:            "Nachrichtenregistrierung für Ressourcenereignisse",
+	Description:     "Diese Registrierung definiert die Nachrichten für Ressourcenereignisse.",
+	Language:        "de",
+	RegistryPrefix:  "ResourceEvent",
+	RegistryVersion: "1.0.0",
+	OwningEntity:    "DMTF",
+	Messages: messages(map[string]msg{
+		"ResourceCreated": {
+			"Die Ressource wurde erfolgreich erstellt.", "OK", "Keine"},
+		"ResourceRemoved": {
+			"Die Ressource wurde erfolgreich entfernt.", "OK", "Keine"},
+		"ResourceChanged": {
+			"Eine oder mehrere Eigenschaften der Ressource wurden geändert.", "OK", "Keine"},
+		"ResourceStatusChangedOK": {
+			"Der Zustand der Ressource '%1' hat sich zu %2 geändert.", "OK", "Keine"},
+		"ResourceStatusChangedWarning": {
+			"Der Zustand der Ressource '%1' hat sich zu %2 geändert.", "Warning", "Keine"},
+		"ResourceStatusChangedCritical": {
+			"Der Zustand der Ressource '%1' hat sich zu %2 geändert.", "Critical", "Keine"},
+		"ResourceErrorsDetected": {
+			"Die Ressourceneigenschaft %1 hat Fehler vom Typ '%2' erkannt.", "Warning",
+			"Die Lösung hängt vom Fehlertyp ab."},
+		"ResourceErrorsCorrected": {
+			"Die Ressourceneigenschaft %1 hat Fehler vom Typ '%2' korrigiert.", "OK", "Keine"},
+		"ResourceErrorThresholdExceeded": {
+			"Die Ressourceneigenschaft %1 hat den Fehlerschwellenwert %2 überschritten.", "Critical", "Keine"},
+		"ResourceErrorThresholdCleared": {
+			"Die Ressourceneigenschaft %1 liegt wieder unter dem Fehlerschwellenwert %2.", "OK", "Keine"},
+		"ResourceWarningThresholdExceeded": {
+			"Die Ressourceneigenschaft %1 hat ihren Warnschwellenwert %2 überschritten.", "Warning", "Keine"},
+		"ResourceWarningThresholdCleared": {
+			"Die Ressourceneigenschaft %1 liegt wieder unter dem Warnschwellenwert %2.", "OK", "Keine"},
+	}),
+}
+
+// TaskEventDE is the German translation of TaskEvent
+var TaskEventDE = &Registry{
+	ID:              "TaskEvent.1.0.0",
+	Name:            "Nachrichtenregistrierung für Aufgabenereignisse",
+	Description:     "Diese Registrierung definiert die Nachrichten für Ereignisse von Aufgaben.",
+	Language:        "de",
+	RegistryPrefix:  "TaskEvent",
+	RegistryVersion: "1.0.0",
+	OwningEntity:    "DMTF",
+	Messages: messages(map[string]msg{
+		"TaskStarted": {
+			"Die Aufgabe mit der ID %1 wurde gestartet.", "OK", "Keine."},
+		"TaskCompletedOK": {
+			"Die Aufgabe mit der ID %1 wurde abgeschlossen.", "OK", "Keine."},
+		"TaskCompletedWarning": {
+			"Die Aufgabe mit der ID %1 wurde mit Warnungen abgeschlossen.", "Warning", "Handeln Sie entsprechend den Warnungen der Aufgabe."},
+		"TaskAborted": {
+			"Die Aufgabe mit der ID %1 wurde abgebrochen.", "Critical", "Keine."},
+		"TaskCancelled": {
+			"Die Aufgabe mit der ID %1 wurde storniert.", "Warning", "Keine."},
+		"TaskRemoved": {
+			"Die Aufgabe mit der ID %1 wurde entfernt.", "Warning", "Keine."},
+		"TaskPaused": {
+			"Die Aufgabe mit der ID %1 wurde angehalten.", "Warning", "Keine."},
+		"TaskResumed": {
+			"Die Aufgabe mit der ID %1 wurde fortgesetzt.", "OK", "Keine."},
+		"TaskProgressChanged": {
+			"Der Fortschritt der Aufgabe mit der ID %1 hat sich auf %2 Prozent geändert.", "OK", "Keine."},
+	}),
+}
@@ -0,0 +1,77 @@
+package registries
+
+// ResourceEvent is the DMTF registry for resource life cycle events
+var ResourceEvent = &Registry{
+	ID:              "ResourceEvent.1.0.0",
+	Name:            "Resource Event Message Registry",
+	Description:     "This registry defines the messages to use for resource events.",
+	Language:        "en",
+	RegistryPrefix:  "ResourceEvent",
+	RegistryVersion: "1.0.0",
+	OwningEntity:    "DMTF",
+	Messages: messages(map[string]msg{
+		"ResourceCreated": {
+			"The resource has been created successfully.", "OK", "None"},
+		"ResourceRemoved": {
+			"The resource has been removed successfully.", "OK", "None"},
+		"ResourceChanged": {
+			"One or more resource properties have changed.", "OK", "None"},
+		"ResourceStatusChangedOK": {
+			"The health of resource '%1' has changed to %2.", "OK", "None"},
+		"ResourceStatusChangedWarning": {
+			"The health of resource '%1' has changed to %2.", "Warning", "None"},
+		"ResourceStatusChangedCritical": {
+			"The health of resource '%1' has changed to %2.", "Critical", "None"},
+		"ResourceErrorsDetected": {
+			"The resource property %1 has detected errors of type '%2'.", "Warning",
+			"Resolution dependent upon error type."},
+		"ResourceErrorsCorrected": {
+			"The resource property %1 has corrected errors of type '%2'.", "OK", "None"},
+		"ResourceErrorThresholdExceeded": {
+			"The resource property %1 has exceeded error threshold of value %2.", "Critical", "None"},
+		"ResourceErrorThresholdCleared": {
+			"The resource property %1 has cleared the error threshold of value %2.", "OK", "None"},
+		"ResourceWarningThresholdExceeded": {
+			"The resource property %1 has exceeded its warning threshold of value %2.", "Warning", "None"},
+		"ResourceWarningThresholdCleared": {
+			"The resource property %1 has cleared the warning threshold of value %2.", "OK", "None"},
+	}),
+}
+
+// TaskEvent is the DMTF registry for task events
+var TaskEvent = &Registry{
+	ID:              "TaskEvent.1.0.0",
+	Name:            "Task Event Message Registry",
+	Description:     "This registry defines the messages for task related events.",
+	Language:        "en",
+	RegistryPrefix:  "TaskEvent",
+	RegistryVersion: "1.0.0",
+	OwningEntity:    "DMTF",
+	Messages: messages(map[string]msg{
+		"TaskStarted": {
+			"The task with id %1 has started.", "OK", "None."},
+		"TaskCompletedOK": {
+			"The task with id %1 has completed.", "OK", "None."},
+		"TaskCompletedWarning": {
+			"The task with id %1 has completed with warnings.", "Warning", "Take action based on the warnings in the task."},
+		"TaskAborted": {
+			"The task with id %1 has been aborted.", "Critical", "None."},
+		"TaskCancelled": {
+			"The task with id %1 has been cancelled.", "Warning", "None."},
+		"TaskRemoved": {
+			"The task with id %1 has been removed.", "Warning", "None."},
+		"TaskPaused": {
+			"The task with id %1 has been paused.", "Warning", "None."},
+		"TaskResumed": {
+			"The task with id %1 has been resumed.", "OK", "None."},
+		"TaskProgressChanged": {
+			"The task with id %1 has changed to progress %2 percent complete.", "OK", "None."},
+	}),
+}
+
+func init() {
+	Register(ResourceEvent)
+	Register(TaskEvent)
+	Register(ResourceEventDE)
+	Register(TaskEventDE)
+}
@@ -0,0 +1,105 @@
+package registries
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Formatted is a message from a registry with the arguments filled in, in
+// the language that was picked for it.
+type Formatted struct {
+	MessageID   string // qualified, ie. Base.1.0.PropertyUnknown
+	Message     string
+	MessageArgs []string
+	Severity    string
+	Resolution  string
+	Language    string
+}
+
+// Format builds the message with the given qualified ID, ie.
+// "Base.1.0.PropertyValueNotInList". acceptLanguage is an Accept-Language
+// header value, it picks which translation of the registry the text comes
+// from. Empty picks the default language. Arguments that aren't given are
+// left as %n in the text.
+func Format(messageID, acceptLanguage string, args ...string) (Formatted, error) {
+	f := Formatted{MessageID: messageID, Message: messageID, MessageArgs: args}
+	if f.MessageArgs == nil {
+		f.MessageArgs = []string{}
+	}
+
+	i := strings.LastIndex(messageID, ".")
+	if i < 0 {
+		return f, fmt.Errorf("message id %q is not qualified with a registry", messageID)
+	}
+	name, key := messageID[:i], messageID[i+1:]
+
+	reg, ok := find(name)
+	if !ok {
+		return f, fmt.Errorf("no registry %q for message %q", name, messageID)
+	}
+	m, ok := reg.Messages[key]
+	if !ok {
+		return f, fmt.Errorf("no message %q in registry %s", key, reg.ID)
+	}
+	f.Severity = m.Severity
+	f.Resolution = m.Resolution
+	f.Language = reg.Language
+
+	// translations only replace the text, severity isn't translated
+	if t := translation(reg, acceptLanguage); t != nil {
+		if tm, ok := t.Messages[key]; ok {
+			m = tm
+			f.Resolution = tm.Resolution
+			f.Language = t.Language
+		}
+	}
+
+	text := m.Message
+	// substitute from the highest index down so that %1 doesn't eat %10
+	for n := len(args); n > 0; n-- {
+		text = strings.Replace(text, fmt.Sprintf("%%%d", n), args[n-1], -1)
+	}
+	f.Message = text
+	return f, nil
+}
+
+// find looks up a registry by the name that messages are qualified with (ie.
+// Base.1.0), the newest one wins if more than one matches.
+func find(name string) (*Registry, bool) {
+	var found *Registry
+	for _, r := range All() {
+		if r.Registry() == name {
+			found = r
+		}
+	}
+	return found, found != nil
+}
+
+// translation picks the best translation of the registry for the
+// Accept-Language header, or nil if that is the default language.
+func translation(reg *Registry, acceptLanguage string) *Registry {
+	if acceptLanguage == "" {
+		return nil
+	}
+	translations := Translations(reg.ID)
+	if len(translations) == 0 {
+		return nil
+	}
+	want, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(want) == 0 {
+		return nil
+	}
+
+	// the default language goes first so that it's the fallback
+	tags := []language.Tag{language.Make(reg.Language)}
+	for _, t := range translations {
+		tags = append(tags, language.Make(t.Language))
+	}
+	_, index, confidence := language.NewMatcher(tags).Match(want...)
+	if confidence == language.No || index == 0 {
+		return nil
+	}
+	return translations[index-1]
+}
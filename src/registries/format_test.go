@@ -0,0 +1,136 @@
+package registries
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// a registry with a translation, for the language tests
+var (
+	testRegistry = &Registry{
+		ID:              "Test.1.0.0",
+		Name:            "Test Message Registry",
+		Language:        "en",
+		RegistryPrefix:  "Test",
+		RegistryVersion: "1.0.0",
+		Messages: messages(map[string]msg{
+			"Hello":   {"Hello %1.", "OK", "None"},
+			"Ten":     {"%1 %2 %3 %4 %5 %6 %7 %8 %9 %10", "Warning", "Count."},
+			"English": {"Only in English.", "OK", "None"},
+		}),
+	}
+	testRegistryDE = &Registry{
+		ID:              "Test.1.0.0",
+		Name:            "Test Message Registry",
+		Language:        "de",
+		RegistryPrefix:  "Test",
+		RegistryVersion: "1.0.0",
+		Messages: messages(map[string]msg{
+			"Hello": {"Hallo %1.", "Critical", "Nichts."},
+		}),
+	}
+)
+
+func init() {
+	Register(testRegistry)
+	Register(testRegistryDE)
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		id       string
+		lang     string
+		args     []string
+		expected Formatted
+	}{
+		{"Base.1.0.PropertyValueNotInList", "", []string{"On", "IndicatorLED"}, Formatted{
+			MessageID:   "Base.1.0.PropertyValueNotInList",
+			Message:     "The value On for the property IndicatorLED is not in the list of acceptable values.",
+			MessageArgs: []string{"On", "IndicatorLED"},
+			Severity:    "Warning",
+			Resolution:  "Choose a value from the enumeration list that the implementation can support and resubmit the request if the operation failed.",
+			Language:    "en",
+		}},
+		{"Test.1.0.Hello", "", []string{"world"}, Formatted{"Test.1.0.Hello", "Hello world.", []string{"world"}, "OK", "None", "en"}},
+		// missing arguments are left alone
+		{"Test.1.0.Hello", "", nil, Formatted{"Test.1.0.Hello", "Hello %1.", []string{}, "OK", "None", "en"}},
+		{"Test.1.0.Ten", "", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "ten"},
+			Formatted{"Test.1.0.Ten", "1 2 3 4 5 6 7 8 9 ten", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "ten"}, "Warning", "Count.", "en"}},
+
+		// the text comes from the translation, the severity doesn't
+		{"Test.1.0.Hello", "de", []string{"Welt"}, Formatted{"Test.1.0.Hello", "Hallo Welt.", []string{"Welt"}, "OK", "Nichts.", "de"}},
+		{"Test.1.0.Hello", "de-AT", []string{"Welt"}, Formatted{"Test.1.0.Hello", "Hallo Welt.", []string{"Welt"}, "OK", "Nichts.", "de"}},
+		{"Test.1.0.Hello", "fr-FR, de;q=0.8", []string{"Welt"}, Formatted{"Test.1.0.Hello", "Hallo Welt.", []string{"Welt"}, "OK", "Nichts.", "de"}},
+		{"Test.1.0.Hello", "de;q=0.5, en;q=0.9", []string{"world"}, Formatted{"Test.1.0.Hello", "Hello world.", []string{"world"}, "OK", "None", "en"}},
+		// languages we don't have, and messages that aren't translated, fall back to the default
+		{"Test.1.0.Hello", "fr", []string{"monde"}, Formatted{"Test.1.0.Hello", "Hello monde.", []string{"monde"}, "OK", "None", "en"}},
+		{"Test.1.0.Hello", "not a language", []string{"x"}, Formatted{"Test.1.0.Hello", "Hello x.", []string{"x"}, "OK", "None", "en"}},
+		{"Test.1.0.English", "de", nil, Formatted{"Test.1.0.English", "Only in English.", []string{}, "OK", "None", "en"}},
+		// the German translations that are shipped
+		{"Base.1.0.Success", "de", nil, Formatted{"Base.1.0.Success", "Anforderung erfolgreich abgeschlossen", []string{}, "OK", "Keine", "de"}},
+	}
+	for _, tc := range tests {
+		f, err := Format(tc.id, tc.lang, tc.args...)
+		if err != nil {
+			t.Errorf("%s %q: %s", tc.id, tc.lang, err)
+			continue
+		}
+		if !reflect.DeepEqual(f, tc.expected) {
+			t.Errorf("%s %q: %+v", tc.id, tc.lang, f)
+		}
+	}
+
+	for _, id := range []string{"Hello", "Nope.1.0.Hello", "Test.1.0.Nope", "Test.2.0.Hello"} {
+		f, err := Format(id, "")
+		if err == nil {
+			t.Errorf("%s: no error", id)
+		}
+		if f.Message != id {
+			t.Errorf("%s: message %q", id, f.Message)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if r, ok := Get("Test.1.0.0"); !ok || r != testRegistry {
+		t.Errorf("Get returned %v", r)
+	}
+	if r, ok := GetLanguage("Test.1.0.0", "de"); !ok || r != testRegistryDE {
+		t.Errorf("GetLanguage returned %v", r)
+	}
+	if _, ok := GetLanguage("Test.1.0.0", "fr"); ok {
+		t.Errorf("found a translation that doesn't exist")
+	}
+	if translations := Translations("Test.1.0.0"); len(translations) != 1 || translations[0] != testRegistryDE {
+		t.Errorf("translations are %v", translations)
+	}
+
+	// registering the same language again replaces it
+	replacement := *testRegistryDE
+	Register(&replacement)
+	defer Register(testRegistryDE)
+	if r, _ := GetLanguage("Test.1.0.0", "de"); r != &replacement || len(Translations("Test.1.0.0")) != 1 {
+		t.Errorf("translation wasn't replaced")
+	}
+}
+
+func TestHandlerTranslations(t *testing.T) {
+	tests := []struct {
+		uri      string
+		status   int
+		language string
+	}{
+		{"/registries/Test.1.0.0.json", http.StatusOK, "en"},
+		{testRegistryDE.LanguageURI(), http.StatusOK, "de"},
+		{"/registries/fr/Test.1.0.0.json", http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest("GET", tc.uri, nil))
+		if w.Code != tc.status || w.Header().Get("Content-Language") != tc.language {
+			t.Errorf("%s: status %d, Content-Language %q", tc.uri, w.Code, w.Header().Get("Content-Language"))
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package registries holds the message registries that we emit messages
+// from. The registry documents are served so that clients can look up the
+// messages, see Handler().
+//
+// Plugins register their own (OEM) registries with Register() from init(),
+// the same way the DMTF ones here are. Translations are registered the same
+// way: a registry with the same ID in another Language. They only need to
+// have the messages that are translated, the rest come from the registry
+// that was registered first.
+//
+// Messages are built with Format(), by qualified ID (ie.
+// "Base.1.0.PropertyValueNotInList") and arguments.
+package registries
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// URIPrefix is where the registry documents are served from
+const URIPrefix = "/registries/"
+
+// Message is a single message in a registry. Arguments in the message are
+// substituted for %1, %2, ...
+type Message struct {
+	Description  string
+	Message      string
+	Severity     string
+	NumberOfArgs int
+	ParamTypes   []string
+	Resolution   string
+}
+
+// Registry is a message registry, ie. Base.1.0.0
+type Registry struct {
+	ID              string
+	Name            string
+	Description     string
+	Language        string
+	RegistryPrefix  string
+	RegistryVersion string
+	OwningEntity    string
+	Messages        map[string]Message
+}
+
+// Registry returns the name that clients use to find the registry: the
+// prefix plus the major and minor version, ie. Base.1.0
+func (r *Registry) Registry() string {
+	parts := strings.Split(r.RegistryVersion, ".")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return r.RegistryPrefix + "." + strings.Join(parts, ".")
+}
+
+// URI is where the registry document is served
+func (r *Registry) URI() string {
+	return URIPrefix + r.ID + ".json"
+}
+
+// MarshalJSON renders the registry as a #MessageRegistry document
+func (r *Registry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"@odata.type":     "#MessageRegistry.v1_0_0.MessageRegistry",
+		"Id":              r.ID,
+		"Name":            r.Name,
+		"Description":     r.Description,
+		"Language":        r.Language,
+		"RegistryPrefix":  r.RegistryPrefix,
+		"RegistryVersion": r.RegistryVersion,
+		"OwningEntity":    r.OwningEntity,
+		"Messages":        r.Messages,
+	})
+}
+
+// registries by ID, the first one registered for an ID is the default
+// language, the rest are translations
+var registries = map[string][]*Registry{}
+var registriesMu sync.RWMutex
+
+// Register makes a registry available, by ID. Registering the same ID and
+// language again replaces the registry.
+func Register(r *Registry) {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	for i, existing := range registries[r.ID] {
+		if existing.Language == r.Language {
+			registries[r.ID][i] = r
+			return
+		}
+	}
+	registries[r.ID] = append(registries[r.ID], r)
+}
+
+// Get looks up a registry by ID, in its default language
+func Get(id string) (*Registry, bool) {
+	registriesMu.RLock()
+	defer registriesMu.RUnlock()
+	if len(registries[id]) == 0 {
+		return nil, false
+	}
+	return registries[id][0], true
+}
+
+// GetLanguage looks up a registry by ID, in the given language
+func GetLanguage(id, lang string) (*Registry, bool) {
+	registriesMu.RLock()
+	defer registriesMu.RUnlock()
+	for _, r := range registries[id] {
+		if r.Language == lang {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// All returns all of the registries in their default language, sorted by ID
+func All() []*Registry {
+	registriesMu.RLock()
+	defer registriesMu.RUnlock()
+	ret := make([]*Registry, 0, len(registries))
+	for _, r := range registries {
+		ret = append(ret, r[0])
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// Translations returns the languages other than the default that a
+// registry is available in
+func Translations(id string) []*Registry {
+	registriesMu.RLock()
+	defer registriesMu.RUnlock()
+	if len(registries[id]) < 2 {
+		return nil
+	}
+	return append([]*Registry{}, registries[id][1:]...)
+}
+
+// LanguageURI is where the translation of the registry is served
+func (r *Registry) LanguageURI() string {
+	return URIPrefix + r.Language + "/" + r.ID + ".json"
+}
+
+// Handler serves the registry documents under URIPrefix: the default
+// language at <ID>.json, translations at <Language>/<ID>.json
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, URIPrefix), ".json")
+		var reg *Registry
+		var ok bool
+		if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+			reg, ok = GetLanguage(parts[1], parts[0])
+		} else {
+			reg, ok = Get(name)
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		b, err := json.MarshalIndent(reg, "", "    ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Language", reg.Language)
+		w.Write(b)
+	})
+}
+
+// msg is the short form that the registries here are written in
+type msg struct {
+	Message    string
+	Severity   string
+	Resolution string
+}
+
+var argRE = regexp.MustCompile(`%([0-9]+)`)
+
+// messages fills in the rest of the Message fields from the short form. All
+// of our message arguments are strings.
+func messages(short map[string]msg) map[string]Message {
+	ret := map[string]Message{}
+	for id, m := range short {
+		n := 0
+		for _, match := range argRE.FindAllStringSubmatch(m.Message, -1) {
+			if i, _ := strconv.Atoi(match[1]); i > n {
+				n = i
+			}
+		}
+		paramTypes := []string{}
+		for i := 0; i < n; i++ {
+			paramTypes = append(paramTypes, "string")
+		}
+		ret[id] = Message{
+			Description:  m.Message,
+			Message:      m.Message,
+			Severity:     m.Severity,
+			NumberOfArgs: n,
+			ParamTypes:   paramTypes,
+			Resolution:   m.Resolution,
+		}
+	}
+	return ret
+}
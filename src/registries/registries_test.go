@@ -0,0 +1,96 @@
+package registries
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistryNames(t *testing.T) {
+	tests := []struct {
+		r        *Registry
+		registry string
+		uri      string
+	}{
+		{Base, "Base.1.0", "/registries/Base.1.0.0.json"},
+		{&Registry{ID: "Oem.2.1.3", RegistryPrefix: "Oem", RegistryVersion: "2.1.3"}, "Oem.2.1", "/registries/Oem.2.1.3.json"},
+		{&Registry{ID: "Short", RegistryPrefix: "Short", RegistryVersion: "1"}, "Short.1", "/registries/Short.json"},
+	}
+	for _, tc := range tests {
+		if r := tc.r.Registry(); r != tc.registry {
+			t.Errorf("%s: Registry() is %q", tc.r.ID, r)
+		}
+		if uri := tc.r.URI(); uri != tc.uri {
+			t.Errorf("%s: URI() is %q", tc.r.ID, uri)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	m, ok := Base.Messages["ActionParameterValueNotInList"]
+	if !ok {
+		t.Fatal("no ActionParameterValueNotInList")
+	}
+	if m.NumberOfArgs != 3 || len(m.ParamTypes) != 3 || m.Severity != "Warning" {
+		t.Errorf("message is %+v", m)
+	}
+	if m := Base.Messages["Success"]; m.NumberOfArgs != 0 || len(m.ParamTypes) != 0 {
+		t.Errorf("Success is %+v", m)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	for _, r := range All() {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest("GET", r.URI(), nil))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("%s: status %d, Content-Type %q", r.URI(), w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Errorf("%s: %s", r.URI(), err)
+			continue
+		}
+		if doc["@odata.type"] != "#MessageRegistry.v1_0_0.MessageRegistry" || doc["Id"] != r.ID || doc["RegistryPrefix"] != r.RegistryPrefix {
+			t.Errorf("%s: document is %v", r.URI(), doc)
+		}
+		messages, _ := doc["Messages"].(map[string]interface{})
+		if len(messages) != len(r.Messages) {
+			t.Errorf("%s: %d messages, expected %d", r.URI(), len(messages), len(r.Messages))
+		}
+	}
+
+	for _, uri := range []string{"/registries/Nope.1.0.0.json", "/registries/"} {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest("GET", uri, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d", uri, w.Code)
+		}
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	for _, r := range []*Registry{Base, ResourceEvent, TaskEvent} {
+		de, ok := GetLanguage(r.ID, "de")
+		if !ok {
+			t.Errorf("%s: no German translation", r.ID)
+			continue
+		}
+		// the same messages, only the text is different
+		for id, m := range r.Messages {
+			tm, ok := de.Messages[id]
+			if !ok {
+				t.Errorf("%s: %s isn't translated", r.ID, id)
+				continue
+			}
+			if tm.Severity != m.Severity || tm.NumberOfArgs != m.NumberOfArgs {
+				t.Errorf("%s: %s is %+v in German and %+v in English", r.ID, id, tm, m)
+			}
+		}
+		if len(de.Messages) != len(r.Messages) {
+			t.Errorf("%s: %d messages in German and %d in English", r.ID, len(de.Messages), len(r.Messages))
+		}
+	}
+}
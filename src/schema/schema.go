@@ -0,0 +1,276 @@
+// Package schema reads the redfish CSDL schema files and checks resources
+// against them.
+package schema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// the parts of CSDL that we use
+type edmxDocument struct {
+	DataServices struct {
+		Schemas []csdlSchema `xml:"Schema"`
+	} `xml:"DataServices"`
+}
+
+type csdlSchema struct {
+	Namespace       string           `xml:"Namespace,attr"`
+	EntityTypes     []csdlType       `xml:"EntityType"`
+	ComplexTypes    []csdlType       `xml:"ComplexType"`
+	EnumTypes       []csdlEnum       `xml:"EnumType"`
+	TypeDefinitions []csdlDefinition `xml:"TypeDefinition"`
+}
+
+type csdlType struct {
+	Name                 string           `xml:"Name,attr"`
+	BaseType             string           `xml:"BaseType,attr"`
+	Abstract             bool             `xml:"Abstract,attr"`
+	Properties           []csdlProperty   `xml:"Property"`
+	NavigationProperties []csdlProperty   `xml:"NavigationProperty"`
+	Annotations          []csdlAnnotation `xml:"Annotation"`
+}
+
+type csdlProperty struct {
+	Name        string           `xml:"Name,attr"`
+	Type        string           `xml:"Type,attr"`
+	Nullable    string           `xml:"Nullable,attr"`
+	Annotations []csdlAnnotation `xml:"Annotation"`
+}
+
+type csdlAnnotation struct {
+	Term       string `xml:"Term,attr"`
+	EnumMember string `xml:"EnumMember,attr"`
+	Bool       string `xml:"Bool,attr"`
+}
+
+type csdlEnum struct {
+	Name    string `xml:"Name,attr"`
+	Members []struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"Member"`
+}
+
+type csdlDefinition struct {
+	Name           string `xml:"Name,attr"`
+	UnderlyingType string `xml:"UnderlyingType,attr"`
+}
+
+// structuredType is an EntityType or ComplexType
+type structuredType struct {
+	name       string
+	baseType   string
+	abstract   bool
+	entity     bool
+	additional bool
+	properties map[string]*property
+}
+
+type property struct {
+	name       string
+	typeName   string // without Collection()
+	collection bool
+	navigation bool
+	nullable   bool
+	required   bool
+	readOnly   bool
+}
+
+// Schemas is an index of all of the types in a set of CSDL files, by
+// qualified name (ie. "ComputerSystem.v1_0_0.ComputerSystem").
+type Schemas struct {
+	types       map[string]*structuredType
+	derived     map[string][]*structuredType
+	enums       map[string]map[string]bool
+	definitions map[string]string
+}
+
+// Load parses all of the CSDL (*.xml) files in dir.
+func Load(dir string) (*Schemas, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no schema files found in %s", dir)
+	}
+
+	s := &Schemas{
+		types:       map[string]*structuredType{},
+		derived:     map[string][]*structuredType{},
+		enums:       map[string]map[string]bool{},
+		definitions: map[string]string{},
+	}
+	for _, f := range files {
+		if err := s.loadFile(f); err != nil {
+			return nil, fmt.Errorf("could not load %s: %s", f, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Schemas) loadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	doc := edmxDocument{}
+	if err := xml.NewDecoder(f).Decode(&doc); err != nil {
+		return err
+	}
+
+	for _, schema := range doc.DataServices.Schemas {
+		for _, t := range schema.EntityTypes {
+			s.addType(schema.Namespace, t, true)
+		}
+		for _, t := range schema.ComplexTypes {
+			s.addType(schema.Namespace, t, false)
+		}
+		for _, e := range schema.EnumTypes {
+			members := map[string]bool{}
+			for _, m := range e.Members {
+				members[m.Name] = true
+			}
+			s.enums[schema.Namespace+"."+e.Name] = members
+		}
+		for _, d := range schema.TypeDefinitions {
+			s.definitions[schema.Namespace+"."+d.Name] = d.UnderlyingType
+		}
+	}
+	return nil
+}
+
+func (s *Schemas) addType(namespace string, t csdlType, entity bool) {
+	st := &structuredType{
+		name:       namespace + "." + t.Name,
+		baseType:   t.BaseType,
+		abstract:   t.Abstract,
+		entity:     entity,
+		properties: map[string]*property{},
+	}
+	for _, a := range t.Annotations {
+		if a.Term == "OData.AdditionalProperties" && a.Bool == "true" {
+			st.additional = true
+		}
+	}
+	for _, p := range t.Properties {
+		st.properties[p.Name] = newProperty(p, false)
+	}
+	for _, p := range t.NavigationProperties {
+		st.properties[p.Name] = newProperty(p, true)
+	}
+	s.types[st.name] = st
+	if st.baseType != "" {
+		s.derived[st.baseType] = append(s.derived[st.baseType], st)
+	}
+}
+
+func newProperty(p csdlProperty, navigation bool) *property {
+	prop := &property{
+		name:       p.Name,
+		typeName:   p.Type,
+		navigation: navigation,
+		nullable:   p.Nullable != "false",
+	}
+	if strings.HasPrefix(p.Type, "Collection(") && strings.HasSuffix(p.Type, ")") {
+		prop.collection = true
+		prop.typeName = strings.TrimSuffix(strings.TrimPrefix(p.Type, "Collection("), ")")
+	}
+	for _, a := range p.Annotations {
+		switch {
+		case a.Term == "Redfish.Required":
+			prop.required = true
+		case a.Term == "OData.Permissions" && a.EnumMember == "OData.Permission/Read":
+			prop.readOnly = true
+		}
+	}
+	return prop
+}
+
+// HasType returns true if there is a schema for the @odata.type
+func (s *Schemas) HasType(odataType string) bool {
+	_, ok := s.types[strings.TrimPrefix(odataType, "#")]
+	return ok
+}
+
+// allProperties returns the properties of a type and all of its base types,
+// the most derived definition wins.
+func (s *Schemas) allProperties(t *structuredType) map[string]*property {
+	props := map[string]*property{}
+	for ; t != nil; t = s.types[t.baseType] {
+		for k, p := range t.properties {
+			if _, ok := props[k]; !ok {
+				props[k] = p
+			}
+		}
+	}
+	return props
+}
+
+// version returns the version part of a qualified name: "v1_1_0" for
+// "ComputerSystem.v1_1_0.Boot", or "" for unversioned names.
+func version(name string) string {
+	parts := strings.Split(name, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// olderVersion compares versions like "v1_2_0" numerically
+func olderVersion(a, b string) bool {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), "_")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), "_")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if na != nb {
+			return na < nb
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// newest finds the newest type with the same name that derives from t and
+// is no newer than maxVersion. Properties are declared with the type from
+// the version they were added in, and later versions of the schema extend
+// that type. ie. ComputerSystem.v1_1_0.ComputerSystem has a Boot of type
+// ComputerSystem.v1_0_0.Boot, but it's really a ComputerSystem.v1_1_0.Boot.
+func (s *Schemas) newest(t *structuredType, maxVersion string) *structuredType {
+	if maxVersion == "" {
+		return t
+	}
+	best := t
+	for _, d := range s.derived[t.name] {
+		if path.Ext(d.name) != path.Ext(t.name) || olderVersion(maxVersion, version(d.name)) {
+			continue
+		}
+		if n := s.newest(d, maxVersion); version(best.name) == "" || olderVersion(version(best.name), version(n.name)) {
+			best = n
+		}
+	}
+	return best
+}
+
+// allowsAdditional returns true if a type, or any of its base types, allows
+// properties that aren't in the schema. Abstract complex types have their
+// properties defined in versioned types that we can't pick from the
+// resource, so we can't tell what's unknown either.
+func (s *Schemas) allowsAdditional(t *structuredType) bool {
+	if t.abstract && !t.entity {
+		return true
+	}
+	for ; t != nil; t = s.types[t.baseType] {
+		if t.additional {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// the schemas that ship with the server
+const schemaDir = "../../v1/schemas"
+
+var (
+	testSchemas     *Schemas
+	testSchemasOnce sync.Once
+)
+
+func loadSchemas(t *testing.T) *Schemas {
+	testSchemasOnce.Do(func() {
+		s, err := Load(schemaDir)
+		if err != nil {
+			t.Fatalf("loading %s: %s", schemaDir, err)
+		}
+		testSchemas = s
+	})
+	if testSchemas == nil {
+		t.Fatal("schemas didn't load")
+	}
+	return testSchemas
+}
+
+func TestLoad(t *testing.T) {
+	s := loadSchemas(t)
+	for _, odataType := range []string{"#Chassis.v1_0_0.Chassis", "ComputerSystem.v1_1_0.ComputerSystem", "#ServiceRoot.v1_0_0.ServiceRoot"} {
+		if !s.HasType(odataType) {
+			t.Errorf("no %s", odataType)
+		}
+	}
+	if s.HasType("#Nope.v1_0_0.Nope") {
+		t.Errorf("has a type that doesn't exist")
+	}
+	if _, err := Load("testdata/nope"); err == nil {
+		t.Errorf("loaded a directory without schemas")
+	}
+}
+
+func TestOlderVersion(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		older bool
+	}{
+		{"v1_0_0", "v1_1_0", true},
+		{"v1_1_0", "v1_0_0", false},
+		{"v1_2_0", "v1_10_0", true},
+		{"v1_0_0", "v1_0_0", false},
+		{"v1_0", "v1_0_0", true},
+	}
+	for _, tc := range tests {
+		if older := olderVersion(tc.a, tc.b); older != tc.older {
+			t.Errorf("%s older than %s: %t", tc.a, tc.b, older)
+		}
+	}
+}
+
+// chassis returns a valid Chassis.v1_0_0 resource with changes applied and
+// the properties in remove taken out
+func chassis(changes map[string]interface{}, remove ...string) map[string]interface{} {
+	r := map[string]interface{}{
+		"@odata.id":   "/redfish/v1/Chassis/1",
+		"@odata.type": "#Chassis.v1_0_0.Chassis",
+		"Id":          "1",
+		"Name":        "Chassis",
+		"ChassisType": "RackMount",
+		"AssetTag":    "tag",
+		"Status":      map[string]interface{}{"State": "Enabled", "Health": "OK"},
+		"Links":       map[string]interface{}{},
+		"Oem":         map[string]interface{}{"Anything": 1.0},
+	}
+	for k, v := range changes {
+		r[k] = v
+	}
+	for _, k := range remove {
+		delete(r, k)
+	}
+	return r
+}
+
+func TestValidateResource(t *testing.T) {
+	s := loadSchemas(t)
+
+	tests := []struct {
+		name     string
+		resource map[string]interface{}
+		dynamic  []string
+		writable []string
+		problems []string
+	}{
+		{"valid", chassis(nil), nil, nil, nil},
+		{"wrong type", chassis(map[string]interface{}{"AssetTag": 1.0}), nil, nil,
+			[]string{"/AssetTag: expected a string"}},
+		{"wrong type inside", chassis(map[string]interface{}{"Status": map[string]interface{}{"Health": true}}), nil, nil,
+			[]string{"/Status/Health: expected a string"}},
+		{"not an object", chassis(map[string]interface{}{"Status": "OK"}), nil, nil,
+			[]string{"/Status: expected an object"}},
+		{"missing required", chassis(nil, "ChassisType"), nil, nil,
+			[]string{"/ChassisType: required property is missing"}},
+		{"required filled in later", chassis(nil, "ChassisType"), []string{"/ChassisType"}, nil, nil},
+		{"bad enum", chassis(map[string]interface{}{"ChassisType": "Spaceship"}), nil, nil,
+			[]string{`/ChassisType: "Spaceship" is not a value of Chassis.v1_0_0.ChassisType`}},
+		{"bad enum inside", chassis(map[string]interface{}{"Status": map[string]interface{}{"Health": "Meh"}}), nil, nil,
+			[]string{`/Status/Health: "Meh" is not a value of Resource.Health`}},
+		{"unknown", chassis(map[string]interface{}{"Nope": 1.0}), nil, nil,
+			[]string{"/Nope: unknown property for Chassis.v1_0_0.Chassis"}},
+		{"null", chassis(map[string]interface{}{"Name": nil}), nil, nil,
+			[]string{"/Name: property can't be null"}},
+		{"read only is writable", chassis(nil), nil, []string{"/ChassisType", "/AssetTag"},
+			[]string{"/ChassisType: read only property is writable"}},
+	}
+	for _, tc := range tests {
+		dynamic, writable := map[string]bool{}, map[string]bool{}
+		for _, p := range tc.dynamic {
+			dynamic[p] = true
+		}
+		for _, p := range tc.writable {
+			writable[p] = true
+		}
+		problems := s.ValidateResource(tc.resource["@odata.type"].(string), tc.resource, dynamic, writable)
+		if !reflect.DeepEqual(problems, tc.problems) {
+			t.Errorf("%s: problems\n%s\nexpected\n%s", tc.name, strings.Join(problems, "\n"), strings.Join(tc.problems, "\n"))
+		}
+	}
+
+	if problems := s.ValidateResource("#Nope.v1_0_0.Nope", map[string]interface{}{"Nope": 1}, nil, nil); problems != nil {
+		t.Errorf("type without a schema: %v", problems)
+	}
+}
@@ -0,0 +1,169 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidateResource checks a resource, rendered as plain json types, against
+// the schema for its @odata.type. Paths (ie. "/Status/Health") in dynamic
+// are filled in later by plugins, so they count as present but can't be
+// checked. Paths in writable can be changed with PATCH. It returns a
+// description of each problem found. Types we don't have a schema for are
+// not checked.
+func (s *Schemas) ValidateResource(odataType string, resource map[string]interface{}, dynamic, writable map[string]bool) []string {
+	t, ok := s.types[strings.TrimPrefix(odataType, "#")]
+	if !ok {
+		return nil
+	}
+	v := &validation{
+		schemas:   s,
+		dynamic:   dynamic,
+		writable:  writable,
+		namespace: strings.SplitN(t.name, ".", 2)[0],
+		version:   version(t.name),
+	}
+	v.object(t, resource, "")
+	sort.Strings(v.errors)
+	return v.errors
+}
+
+type validation struct {
+	schemas  *Schemas
+	dynamic  map[string]bool
+	writable map[string]bool
+	errors   []string
+
+	// of the resource type, for picking versions of the complex types in its schema
+	namespace string
+	version   string
+}
+
+func (v *validation) add(path string, format string, args ...interface{}) {
+	if path == "" {
+		path = "/"
+	}
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validation) object(t *structuredType, obj map[string]interface{}, path string) {
+	props := v.schemas.allProperties(t)
+
+	for name, value := range obj {
+		// annotations and actions
+		if strings.Contains(name, "@") || strings.HasPrefix(name, "#") {
+			continue
+		}
+		// anything goes in Oem
+		if name == "Oem" {
+			continue
+		}
+		p, ok := props[name]
+		if !ok {
+			if !v.schemas.allowsAdditional(t) {
+				v.add(path+"/"+name, "unknown property for %s", t.name)
+			}
+			continue
+		}
+		v.property(p, value, path+"/"+name)
+	}
+
+	for name, p := range props {
+		if v.writable[path+"/"+name] && p.readOnly {
+			v.add(path+"/"+name, "read only property is writable")
+		}
+		if !p.required {
+			continue
+		}
+		if _, ok := obj[name]; !ok && !v.dynamic[path+"/"+name] {
+			v.add(path+"/"+name, "required property is missing")
+		}
+	}
+}
+
+func (v *validation) property(p *property, value interface{}, path string) {
+	if value == nil {
+		if !p.nullable && !v.dynamic[path] {
+			v.add(path, "property can't be null")
+		}
+		return
+	}
+
+	if !p.collection {
+		v.value(p, p.typeName, value, path)
+		return
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		v.add(path, "expected an array")
+		return
+	}
+	for i, item := range list {
+		if item == nil {
+			continue
+		}
+		v.value(p, p.typeName, item, fmt.Sprintf("%s/%d", path, i))
+	}
+}
+
+func (v *validation) value(p *property, typeName string, value interface{}, path string) {
+	// type definitions are just another name for a primitive type
+	if underlying, ok := v.schemas.definitions[typeName]; ok {
+		typeName = underlying
+	}
+
+	switch typeName {
+	case "Edm.String", "Edm.DateTimeOffset", "Edm.Duration", "Edm.Guid", "Edm.TimeOfDay", "Edm.Date":
+		if _, ok := value.(string); !ok {
+			v.add(path, "expected a string")
+		}
+		return
+	case "Edm.Boolean":
+		if _, ok := value.(bool); !ok {
+			v.add(path, "expected a boolean")
+		}
+		return
+	case "Edm.Int64", "Edm.Int32", "Edm.Int16", "Edm.Byte", "Edm.SByte":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			v.add(path, "expected an integer")
+		}
+		return
+	case "Edm.Decimal", "Edm.Double", "Edm.Single":
+		if _, ok := value.(float64); !ok {
+			v.add(path, "expected a number")
+		}
+		return
+	}
+
+	if members, ok := v.schemas.enums[typeName]; ok {
+		s, ok := value.(string)
+		if !ok {
+			v.add(path, "expected a string")
+		} else if !members[s] {
+			v.add(path, "%q is not a value of %s", s, typeName)
+		}
+		return
+	}
+
+	t, ok := v.schemas.types[typeName]
+	if !ok {
+		// from a schema we don't have (ie. the odata ones)
+		return
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		v.add(path, "expected an object")
+		return
+	}
+	if p.navigation || t.entity {
+		// links to other resources are checked when those resources are
+		return
+	}
+	if strings.HasPrefix(t.name, v.namespace+".") {
+		t = v.schemas.newest(t, v.version)
+	}
+	v.object(t, obj, path)
+}
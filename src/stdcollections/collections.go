@@ -110,37 +110,37 @@ func newService(ctx context.Context, rootID eh.UUID, ch eh.CommandHandler) {
 			},
 		})
 
-	// Add Accounts collection
+	// Add Roles collection, the accounts plugin handles POST of custom roles
 	ch.HandleCommand(
 		ctx,
 		&domain.CreateRedfishResource{
 			ID:         eh.NewUUID(),
 			Collection: true,
 
-			ResourceURI: "/redfish/v1/AccountService/Accounts",
-			Type:        "#ManagerAccountCollection.ManagerAccountCollection",
-			Context:     "/redfish/v1/$metadata#ManagerAccountCollection.ManagerAccountCollection",
+			ResourceURI: "/redfish/v1/AccountService/Roles",
+			Type:        "#RoleCollection.RoleCollection",
+			Context:     "/redfish/v1/$metadata#RoleCollection.RoleCollection",
 			Privileges: map[string]interface{}{
 				"GET":    []string{"Login"},
-				"POST":   []string{}, // Read Only
+				"POST":   []string{"ConfigureUsers"},
 				"PUT":    []string{}, // Read Only
 				"PATCH":  []string{}, // Read Only
 				"DELETE": []string{}, // can't be deleted
 			},
 			Properties: map[string]interface{}{
-				"Name": "Accounts Collection",
+				"Name": "Roles Collection",
 			}})
 
-	// Add Roles collection
+	// add standard DMTF roles: Admin
 	ch.HandleCommand(
 		ctx,
 		&domain.CreateRedfishResource{
 			ID:         eh.NewUUID(),
-			Collection: true,
+			Collection: false,
 
-			ResourceURI: "/redfish/v1/AccountService/Roles",
-			Type:        "#RoleCollection.RoleCollection",
-			Context:     "/redfish/v1/$metadata#RoleCollection.RoleCollection",
+			ResourceURI: "/redfish/v1/AccountService/Roles/Admin",
+			Type:        "#Role.v1_2_0.Role",
+			Context:     "/redfish/v1/$metadata#Role.Role",
 			Privileges: map[string]interface{}{
 				"GET":    []string{"Login"},
 				"POST":   []string{}, // Read Only
@@ -149,61 +149,61 @@ func newService(ctx context.Context, rootID eh.UUID, ch eh.CommandHandler) {
 				"DELETE": []string{}, // can't be deleted
 			},
 			Properties: map[string]interface{}{
-				"Name": "Roles Collection",
+				"Name":          "User Role",
+				"Id":            "Admin",
+				"RoleId":        "Admin",
+				"Description":   "Admin User Role",
+				"IsPredefined":  true,
+				"OemPrivileges": []string{},
+				"AssignedPrivileges": []string{
+					"Login",
+					"ConfigureManager",
+					"ConfigureUsers",
+					"ConfigureSelf",
+					"ConfigureComponents",
+				},
 			}})
 
-	// Create Computer System Collection
+	// add standard DMTF roles: Operator
 	ch.HandleCommand(
 		ctx,
 		&domain.CreateRedfishResource{
 			ID:         eh.NewUUID(),
 			Collection: false,
 
-			ResourceURI: "/redfish/v1/AccountService",
-			Type:        "#AccountService.v1_0_2.AccountService",
-			Context:     "/redfish/v1/$metadata#AccountService.AccountService",
+			ResourceURI: "/redfish/v1/AccountService/Roles/Operator",
+			Type:        "#Role.v1_2_0.Role",
+			Context:     "/redfish/v1/$metadata#Role.Role",
 			Privileges: map[string]interface{}{
 				"GET":    []string{"Login"},
-				"POST":   []string{"ConfigureManager"}, // cannot create sub objects
-				"PUT":    []string{"ConfigureManager"},
-				"PATCH":  []string{"ConfigureManager"},
+				"POST":   []string{}, // Read Only
+				"PUT":    []string{}, // Read Only
+				"PATCH":  []string{}, // Read Only
 				"DELETE": []string{}, // can't be deleted
 			},
 			Properties: map[string]interface{}{
-				"Id":          "AccountService",
-				"Name":        "Account Service",
-				"Description": "Account Service",
-				"Status": map[string]interface{}{
-					"State":  "Enabled",
-					"Health": "OK",
+				"Name":          "User Role",
+				"Id":            "Operator",
+				"RoleId":        "Operator",
+				"Description":   "Operator User Role",
+				"IsPredefined":  true,
+				"OemPrivileges": []string{},
+				"AssignedPrivileges": []string{
+					"Login",
+					"ConfigureSelf",
+					"ConfigureComponents",
 				},
-				"ServiceEnabled":                  true,
-				"AuthFailureLoggingThreshold":     3,
-				"MinPasswordLength":               8,
-				"AccountLockoutThreshold":         5,
-				"AccountLockoutDuration":          30,
-				"AccountLockoutCounterResetAfter": 30,
-				"Accounts":                        map[string]string{"@odata.id": "/redfish/v1/AccountService/Accounts"},
-				"Roles":                           map[string]string{"@odata.id": "/redfish/v1/AccountService/Roles"},
 			}})
 
-	ch.HandleCommand(ctx,
-		&domain.UpdateRedfishResourceProperties{
-			ID: rootID,
-			Properties: map[string]interface{}{
-				"AccountService": map[string]interface{}{"@odata.id": "/redfish/v1/AccountService"},
-			},
-		})
-
-	// add standard DMTF roles: Admin
+	// add standard DMTF roles: Read-only
 	ch.HandleCommand(
 		ctx,
 		&domain.CreateRedfishResource{
 			ID:         eh.NewUUID(),
 			Collection: false,
 
-			ResourceURI: "/redfish/v1/AccountService/Roles/Admin",
-			Type:        "#Role.v1_0_2.Role",
+			ResourceURI: "/redfish/v1/AccountService/Roles/ReadOnlyUser",
+			Type:        "#Role.v1_2_0.Role",
 			Context:     "/redfish/v1/$metadata#Role.Role",
 			Privileges: map[string]interface{}{
 				"GET":    []string{"Login"},
@@ -213,29 +213,27 @@ func newService(ctx context.Context, rootID eh.UUID, ch eh.CommandHandler) {
 				"DELETE": []string{}, // can't be deleted
 			},
 			Properties: map[string]interface{}{
-				"Name":         "User Role",
-				"Id":           "Admin",
-				"Description":  "Admin User Role",
-				"IsPredefined": true,
+				"Name":          "User Role",
+				"Id":            "ReadOnlyUser",
+				"RoleId":        "ReadOnlyUser",
+				"Description":   "ReadOnlyUser User Role",
+				"IsPredefined":  true,
+				"OemPrivileges": []string{},
 				"AssignedPrivileges": []string{
 					"Login",
-					"ConfigureManager",
-					"ConfigureUsers",
-					"ConfigureSelf",
-					"ConfigureComponents",
 				},
 			}})
 
-	// add standard DMTF roles: Operator
+	// Task service, the tasks themselves are created by the redfish handler for long running commands
 	ch.HandleCommand(
 		ctx,
 		&domain.CreateRedfishResource{
 			ID:         eh.NewUUID(),
 			Collection: false,
 
-			ResourceURI: "/redfish/v1/AccountService/Roles/Operator",
-			Type:        "#Role.v1_0_2.Role",
-			Context:     "/redfish/v1/$metadata#Role.Role",
+			ResourceURI: "/redfish/v1/TaskService",
+			Type:        "#TaskService.v1_0_0.TaskService",
+			Context:     "/redfish/v1/$metadata#TaskService.TaskService",
 			Privileges: map[string]interface{}{
 				"GET":    []string{"Login"},
 				"POST":   []string{}, // Read Only
@@ -244,27 +242,28 @@ func newService(ctx context.Context, rootID eh.UUID, ch eh.CommandHandler) {
 				"DELETE": []string{}, // can't be deleted
 			},
 			Properties: map[string]interface{}{
-				"Name":         "User Role",
-				"Id":           "Operator",
-				"Description":  "Operator User Role",
-				"IsPredefined": true,
-				"AssignedPrivileges": []string{
-					"Login",
-					"ConfigureSelf",
-					"ConfigureComponents",
+				"Id":          "TaskService",
+				"Name":        "Task Service",
+				"Description": "Task Service",
+				"Status": map[string]interface{}{
+					"State":  "Enabled",
+					"Health": "OK",
 				},
+				"ServiceEnabled":                  true,
+				"CompletedTaskOverWritePolicy":    "Oldest",
+				"LifeCycleEventOnTaskStateChange": false,
+				"Tasks":                           map[string]string{"@odata.id": domain.TaskCollectionURI},
 			}})
 
-	// add standard DMTF roles: Read-only
 	ch.HandleCommand(
 		ctx,
 		&domain.CreateRedfishResource{
 			ID:         eh.NewUUID(),
-			Collection: false,
+			Collection: true,
 
-			ResourceURI: "/redfish/v1/AccountService/Roles/ReadOnlyUser",
-			Type:        "#Role.v1_0_2.Role",
-			Context:     "/redfish/v1/$metadata#Role.Role",
+			ResourceURI: domain.TaskCollectionURI,
+			Type:        "#TaskCollection.TaskCollection",
+			Context:     "/redfish/v1/$metadata#TaskCollection.TaskCollection",
 			Privileges: map[string]interface{}{
 				"GET":    []string{"Login"},
 				"POST":   []string{}, // Read Only
@@ -273,13 +272,16 @@ func newService(ctx context.Context, rootID eh.UUID, ch eh.CommandHandler) {
 				"DELETE": []string{}, // can't be deleted
 			},
 			Properties: map[string]interface{}{
-				"Name":         "User Role",
-				"Id":           "ReadOnlyUser",
-				"Description":  "ReadOnlyUser User Role",
-				"IsPredefined": true,
-				"AssignedPrivileges": []string{
-					"Login",
-				},
+				"Name": "Task Collection",
 			}})
 
+	ch.HandleCommand(ctx,
+		&domain.UpdateRedfishResourceProperties{
+			ID: rootID,
+			Properties: map[string]interface{}{
+				"Tasks": map[string]interface{}{"@odata.id": "/redfish/v1/TaskService"},
+			},
+		})
+
+	newSchemaCollections(ctx, rootID, ch)
 }
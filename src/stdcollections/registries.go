@@ -0,0 +1,152 @@
+package stdcollections
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	domain "github.com/superchalupa/go-redfish/src/redfishresource"
+	"github.com/superchalupa/go-redfish/src/registries"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// SchemaDir is where the CSDL schema files that are listed in JsonSchemas live
+var SchemaDir = "v1/schemas"
+
+// newSchemaCollections creates the JsonSchemas and Registries collections,
+// with one member per schema file and one per message registry.
+func newSchemaCollections(ctx context.Context, rootID eh.UUID, ch eh.CommandHandler) {
+	ch.HandleCommand(
+		ctx,
+		&domain.CreateRedfishResource{
+			ID:         eh.NewUUID(),
+			Collection: true,
+
+			ResourceURI: "/redfish/v1/JsonSchemas",
+			Type:        "#JsonSchemaFileCollection.JsonSchemaFileCollection",
+			Context:     "/redfish/v1/$metadata#JsonSchemaFileCollection.JsonSchemaFileCollection",
+			Privileges: map[string]interface{}{
+				"GET":    []string{"Login"},
+				"POST":   []string{}, // Read Only
+				"PUT":    []string{}, // Read Only
+				"PATCH":  []string{}, // Read Only
+				"DELETE": []string{}, // can't be deleted
+			},
+			Properties: map[string]interface{}{
+				"Name": "JSON Schema File Collection",
+			}})
+
+	files, _ := filepath.Glob(filepath.Join(SchemaDir, "*.xml"))
+	for _, f := range files {
+		file := filepath.Base(f)
+		id := strings.TrimSuffix(file, ".xml")
+		name := strings.SplitN(id, "_", 2)[0]
+		ch.HandleCommand(
+			ctx,
+			&domain.CreateRedfishResource{
+				ID: eh.NewUUID(),
+
+				ResourceURI: "/redfish/v1/JsonSchemas/" + id,
+				Type:        "#JsonSchemaFile.v1_0_2.JsonSchemaFile",
+				Context:     "/redfish/v1/$metadata#JsonSchemaFile.JsonSchemaFile",
+				Privileges: map[string]interface{}{
+					"GET":    []string{"Login"},
+					"POST":   []string{}, // Read Only
+					"PUT":    []string{}, // Read Only
+					"PATCH":  []string{}, // Read Only
+					"DELETE": []string{}, // can't be deleted
+				},
+				Properties: map[string]interface{}{
+					"Id":          id,
+					"Name":        name + " Schema File",
+					"Description": name + " Schema File Location",
+					"Languages":   []string{"en"},
+					"Schema":      "#" + name + "." + name,
+					"Location": []interface{}{
+						map[string]interface{}{
+							"Language":       "en",
+							"Uri":            domain.SchemaURIPrefix + file,
+							"PublicationUri": "http://redfish.dmtf.org/schemas/v1/" + file,
+						},
+					},
+				}})
+	}
+
+	ch.HandleCommand(ctx,
+		&domain.UpdateRedfishResourceProperties{
+			ID: rootID,
+			Properties: map[string]interface{}{
+				"JsonSchemas": map[string]interface{}{"@odata.id": "/redfish/v1/JsonSchemas"},
+			},
+		})
+
+	ch.HandleCommand(
+		ctx,
+		&domain.CreateRedfishResource{
+			ID:         eh.NewUUID(),
+			Collection: true,
+
+			ResourceURI: "/redfish/v1/Registries",
+			Type:        "#MessageRegistryFileCollection.MessageRegistryFileCollection",
+			Context:     "/redfish/v1/$metadata#MessageRegistryFileCollection.MessageRegistryFileCollection",
+			Privileges: map[string]interface{}{
+				"GET":    []string{"Login"},
+				"POST":   []string{}, // Read Only
+				"PUT":    []string{}, // Read Only
+				"PATCH":  []string{}, // Read Only
+				"DELETE": []string{}, // can't be deleted
+			},
+			Properties: map[string]interface{}{
+				"Name": "Registry File Collection",
+			}})
+
+	for _, r := range registries.All() {
+		languages := []string{r.Language}
+		locations := []interface{}{
+			map[string]interface{}{
+				"Language":       r.Language,
+				"Uri":            r.URI(),
+				"PublicationUri": "http://redfish.dmtf.org/registries/" + r.ID + ".json",
+			},
+		}
+		for _, t := range registries.Translations(r.ID) {
+			languages = append(languages, t.Language)
+			locations = append(locations, map[string]interface{}{
+				"Language": t.Language,
+				"Uri":      t.LanguageURI(),
+			})
+		}
+		ch.HandleCommand(
+			ctx,
+			&domain.CreateRedfishResource{
+				ID: eh.NewUUID(),
+
+				ResourceURI: "/redfish/v1/Registries/" + r.ID,
+				Type:        "#MessageRegistryFile.v1_0_2.MessageRegistryFile",
+				Context:     "/redfish/v1/$metadata#MessageRegistryFile.MessageRegistryFile",
+				Privileges: map[string]interface{}{
+					"GET":    []string{"Login"},
+					"POST":   []string{}, // Read Only
+					"PUT":    []string{}, // Read Only
+					"PATCH":  []string{}, // Read Only
+					"DELETE": []string{}, // can't be deleted
+				},
+				Properties: map[string]interface{}{
+					"Id":          r.ID,
+					"Name":        r.Name + " File",
+					"Description": r.Name + " File Location",
+					"Languages":   languages,
+					"Registry":    r.Registry(),
+					"Location":    locations,
+				}})
+	}
+
+	ch.HandleCommand(ctx,
+		&domain.UpdateRedfishResourceProperties{
+			ID: rootID,
+			Properties: map[string]interface{}{
+				"Registries": map[string]interface{}{"@odata.id": "/redfish/v1/Registries"},
+			},
+		})
+}
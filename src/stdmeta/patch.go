@@ -25,9 +25,10 @@ func (t *patch) PropertyPatch(
 	method string,
 	meta map[string]interface{},
 	body interface{},
+	present bool,
 ) {
-	// wow, how can it be this simple?
-	// ... we need to add a way to add validation... so I guess it can't stay this simple for long.
-
-	rrp.Value = body
+	// validation of the request is done before we get here
+	if present {
+		rrp.Value = body
+	}
 }